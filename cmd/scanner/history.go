@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"netscanner/internal/history"
+	"netscanner/internal/reporter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultHistoryDB 历史库的默认文件名
+const defaultHistoryDB = "netscanner-history.db"
+
+// newHistoryCmd 创建 history 子命令
+func newHistoryCmd() *cobra.Command {
+	var dbPath string
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "查询扫描历史（扫描时使用 --history 记录）",
+	}
+	historyCmd.PersistentFlags().StringVar(&dbPath, "db", defaultHistoryDB, "历史库文件")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "列出所有扫描记录",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			withHistory(dbPath, historyList)
+		},
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show <ID>",
+		Short: "显示一次扫描的参数、开放端口和检测结果",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, ok := parseScanID(args[0])
+			if !ok {
+				return
+			}
+			withHistory(dbPath, func(store *history.Store) { historyShow(store, id) })
+		},
+	}
+
+	var exportFormat, exportOutput string
+	exportCmd := &cobra.Command{
+		Use:   "export <ID>",
+		Short: "把一次扫描导出为报告文件",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, ok := parseScanID(args[0])
+			if !ok {
+				return
+			}
+			withHistory(dbPath, func(store *history.Store) { historyExport(store, id, exportFormat, exportOutput) })
+		},
+	}
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "json", "报告格式: json, html, xml")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "输出文件（必填）")
+	exportCmd.MarkFlagRequired("output")
+
+	var protocol string
+	portCmd := &cobra.Command{
+		Use:   "port <主机> <端口>",
+		Short: "查询端口首次出现的时间及各次扫描中的状态",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			port, err := strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Printf("❌ 错误：无效的端口: %s\n", args[1])
+				return
+			}
+			withHistory(dbPath, func(store *history.Store) { historyPort(store, args[0], port, protocol) })
+		},
+	}
+	portCmd.Flags().StringVar(&protocol, "protocol", "tcp", "协议: tcp, udp")
+
+	findingCmd := &cobra.Command{
+		Use:   "finding <主机> <端口> <插件>",
+		Short: "跟踪某个插件检测结果随时间的变化",
+		Args:  cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			port, err := strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Printf("❌ 错误：无效的端口: %s\n", args[1])
+				return
+			}
+			withHistory(dbPath, func(store *history.Store) { historyFinding(store, args[0], port, protocol, args[2]) })
+		},
+	}
+	findingCmd.Flags().StringVar(&protocol, "protocol", "tcp", "协议: tcp, udp")
+
+	historyCmd.AddCommand(listCmd, showCmd, exportCmd, portCmd, findingCmd)
+	return historyCmd
+}
+
+// withHistory 打开历史库并执行查询
+func withHistory(path string, fn func(store *history.Store)) {
+	store, err := history.Open(path)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	defer store.Close()
+	fn(store)
+}
+
+// parseScanID 解析扫描ID
+func parseScanID(s string) (uint64, bool) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		fmt.Printf("❌ 错误：无效的扫描ID: %s\n", s)
+		return 0, false
+	}
+	return id, true
+}
+
+// historyList 列出扫描记录
+func historyList(store *history.Store) {
+	summaries, err := store.List()
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	if len(summaries) == 0 {
+		fmt.Println("📭 历史库中还没有扫描记录")
+		return
+	}
+
+	fmt.Println("ID\t开始时间\t\t模式\t\t主机\t开放\t问题\t目标")
+	fmt.Println("--\t--------\t\t----\t\t----\t----\t----\t----")
+	for _, s := range summaries {
+		mode := s.Mode + "/" + s.ScanType
+		if s.Incomplete {
+			mode += "⚠️"
+		}
+		fmt.Printf("%d\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			s.ID, s.StartTime.Local().Format("2006-01-02 15:04:05"), limitString(mode, 15), s.Hosts, s.OpenPorts, s.Findings, s.Target)
+	}
+}
+
+// historyShow 显示一次扫描
+func historyShow(store *history.Store, id uint64) {
+	record, err := store.Get(id)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	doc := record.Report
+
+	fmt.Printf("🗄️ 扫描 #%d\n", record.ID)
+	fmt.Printf("  目标: %s\n", record.Params.Target)
+	if record.Params.Source != "" {
+		fmt.Printf("  来源: %s（nmap XML 导入）\n", record.Params.Source)
+	}
+	if record.Params.Ports != "" {
+		fmt.Printf("  端口: %s\n", record.Params.Ports)
+	}
+	fmt.Printf("  模式: %s, 类型: %s", record.Params.Mode, record.Params.ScanType)
+	if record.Params.Timing != "" {
+		fmt.Printf(", 时序: %s", record.Params.Timing)
+	}
+	fmt.Println()
+	fmt.Printf("  时间: %s，耗时 %v\n", doc.Scan.StartTime.Local().Format("2006-01-02 15:04:05"), time.Duration(doc.Scan.DurationMs)*time.Millisecond)
+	if doc.Scan.Incomplete {
+		fmt.Println("  ⚠️ 扫描被中断，结果不完整")
+	}
+	fmt.Printf("  统计: %d 台主机，%d 个端口，开放 %d，过滤 %d，关闭 %d\n",
+		doc.Summary.Hosts, doc.Summary.TotalPorts, doc.Summary.Open, doc.Summary.Filtered, doc.Summary.Closed)
+
+	for _, h := range doc.Hosts {
+		var open []reporter.JSONPort
+		for _, p := range h.Ports {
+			if p.State == "open" || p.State == "open|filtered" {
+				open = append(open, p)
+			}
+		}
+		if len(open) == 0 {
+			continue
+		}
+
+		fmt.Printf("\n🖥️  主机: %s\n", h.Host)
+		for _, p := range open {
+			fmt.Printf("  %d/%s\t%s\t%s\t%s\n", p.Port, p.Protocol, p.State, p.Service, strings.TrimSpace(p.Product+" "+p.Version))
+			for _, f := range p.Findings {
+				if f.Vulnerable {
+					fmt.Printf("    ⚠️ [%s] %s: %s\n", f.Severity, f.Plugin, limitString(f.Details, 60))
+				} else {
+					fmt.Printf("    ✓ %s: %s\n", f.Plugin, limitString(f.Details, 60))
+				}
+			}
+		}
+	}
+}
+
+// historyExport 导出一次扫描
+func historyExport(store *history.Store, id uint64, format, outputFile string) {
+	record, err := store.Get(id)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	report := reporter.FromJSON(record.Report)
+	report.Ports = record.Params.Ports
+
+	switch format {
+	case "json":
+		err = reporter.GenerateJSONReport(report, outputFile)
+	case "html":
+		err = reporter.GenerateHTMLReport(report, outputFile)
+	case "xml":
+		err = reporter.GenerateNmapXML(report, outputFile)
+	default:
+		fmt.Printf("❌ 错误：不支持的报告格式: %s\n", format)
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ 生成报告失败: %v\n", err)
+		return
+	}
+	fmt.Printf("📄 扫描 #%d 已导出: %s\n", id, outputFile)
+}
+
+// historyPort 显示端口的历史
+func historyPort(store *history.Store, host string, port int, protocol string) {
+	sightings, err := store.PortHistory(host, port, protocol)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	if len(sightings) == 0 {
+		fmt.Printf("📭 历史记录中 %s %d/%s 从未开放\n", host, port, protocol)
+		return
+	}
+
+	first := sightings[0]
+	fmt.Printf("🕒 %s %d/%s 首次出现于扫描 #%d（%s），共在 %d 次扫描中开放\n",
+		host, port, protocol, first.ScanID, first.Time.Local().Format("2006-01-02 15:04:05"), len(sightings))
+	fmt.Println("扫描\t时间\t\t\t状态\t服务\t问题\t版本")
+	for _, s := range sightings {
+		fmt.Printf("#%d\t%s\t%s\t%s\t%d\t%s\n",
+			s.ScanID, s.Time.Local().Format("2006-01-02 15:04:05"), s.State, s.Service, s.Findings, strings.TrimSpace(s.Product+" "+s.Version))
+	}
+}
+
+// historyFinding 显示检测结果的历史
+func historyFinding(store *history.Store, host string, port int, protocol, plugin string) {
+	sightings, err := store.FindingHistory(host, port, protocol, plugin)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	if len(sightings) == 0 {
+		fmt.Printf("📭 历史记录中没有 %s 对 %s %d/%s 的检测结果\n", plugin, host, port, protocol)
+		return
+	}
+
+	fmt.Printf("🕒 %s 对 %s %d/%s 的 %d 次检测：\n", plugin, host, port, protocol, len(sightings))
+	for _, s := range sightings {
+		status := "✓ 通过"
+		if s.Vulnerable {
+			status = "⚠️ " + s.Severity
+		}
+		details := s.Details
+		if s.ID != "" {
+			details = "[" + s.ID + "] " + details
+		}
+		fmt.Printf("  #%d\t%s\t%s\t%s\n", s.ScanID, s.Time.Local().Format("2006-01-02 15:04:05"), status, limitString(details, 60))
+	}
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"netscanner/internal/diff"
+	"netscanner/internal/history"
 	"netscanner/internal/plugin"
 	"netscanner/internal/reporter" // 添加reporter包导入
 	"netscanner/internal/scanner"
+	"netscanner/internal/server"
+	"netscanner/internal/target"
+	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -16,13 +26,33 @@ import (
 func main() {
 	// 定义命令行参数变量
 	var (
-		host      string
-		ports     string
-		timeout   int
-		workers   int
-		pluginArg string // 改为pluginArg避免与包名冲突
-		scanMode  string
-		report    string // 添加报告文件参数
+		host        string
+		targetFile  string // 目标文件，每行一个表达式
+		exclude     string // 排除的目标
+		excludeFile string // 排除列表文件
+		ports       string
+		timeout     int
+		workers     int
+		pluginArg   string // 改为pluginArg避免与包名冲突
+		scanMode    string
+		scanType    string // tcp 或 udp
+		report      string // 添加报告文件参数
+		format      string // 报告格式：html、json、jsonl、xml
+		importNmap  string // nmap XML 文件，对其中的开放端口运行插件
+		historyDB   string // 历史库文件
+		skipPing    bool   // 跳过主机发现，视所有主机为在线
+		timingName  string // 时序模板
+		rate        int    // 每秒最多探测数
+		hostProbes  int    // 单主机最大并发探测数
+		scanDelay   int    // 每次探测前的延迟（毫秒）
+		jitter      int    // 随机附加延迟上限（毫秒）
+		retries     int    // 超时重试次数
+		probeDB     string // nmap-service-probes 格式的探测库文件
+		intensity   int    // 服务探测强度
+		noTLS       bool   // 关闭TLS检测
+		pluginDir   string // 插件目录，其中的 YAML/JSON 模板、Starlark 脚本（以及开启后的外部可执行文件）注册为插件
+		pluginOpts  pluginOptions
+		pluginCats  string // 安全扫描模式下自动运行的插件类别
 	)
 
 	// 创建根命令
@@ -32,69 +62,330 @@ func main() {
 		Long: `一个快速的TCP端口扫描器，支持IPv4/IPv6双栈
 支持并发扫描、服务指纹识别、安全插件检测`,
 		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+
+			// 解析时序模板，显式指定的参数覆盖模板中的值
+			timing, err := scanner.GetTimingProfile(timingName)
+			if err != nil {
+				fmt.Printf("❌ 错误：%v\n", err)
+				return
+			}
+			flags := cmd.Flags()
+			if flags.Changed("timeout") {
+				timing.Timeout = time.Duration(timeout) * time.Second
+			}
+			if flags.Changed("workers") {
+				timing.Workers = workers
+			}
+			if flags.Changed("rate") {
+				timing.Rate = rate
+			}
+			if flags.Changed("max-host-probes") {
+				timing.MaxPerHost = hostProbes
+			}
+			if flags.Changed("scan-delay") {
+				timing.ScanDelay = time.Duration(scanDelay) * time.Millisecond
+			}
+			if flags.Changed("jitter") {
+				timing.Jitter = time.Duration(jitter) * time.Millisecond
+			}
+			if flags.Changed("retries") {
+				timing.Retries = retries
+			}
+			timeout = int(timing.Timeout / time.Second)
+
+			// 展开扫描目标
+			hosts, err := resolveTargets(host, targetFile, exclude, excludeFile)
+			if err != nil {
+				fmt.Printf("❌ 错误：%v\n", err)
+				return
+			}
+
+			categories, err := plugin.ParseCategories(pluginCats)
+			if err != nil {
+				fmt.Printf("❌ 错误：%v\n", err)
+				return
+			}
+
 			// 初始化插件管理器
-			pluginManager := initializePlugins()
+			pluginManager := initializePlugins(pluginDir, pluginOpts)
+
+			// 导入 nmap 结果时不重新扫描，直接运行插件
+			if importNmap != "" {
+				runImportedScan(ctx, importNmap, pluginManager, categories, timeout, outputSettings{file: report, format: format, history: historyDB})
+				return
+			}
 
-			// 如果指定了插件，运行插件扫描模式
+			// 如果指定了插件，运行插件扫描模式，未指定 -p 时使用插件的默认端口
 			if pluginArg != "" {
-				runPluginScan(host, pluginArg, pluginManager, timeout)
+				var pluginPorts []int
+				if flags.Changed("ports") {
+					pluginPorts = scanner.ParsePorts(ports)
+				}
+				for _, h := range hosts {
+					if ctx.Err() != nil {
+						break
+					}
+					runPluginScan(ctx, h, pluginArg, pluginManager, pluginPorts, timeout)
+				}
 				return
 			}
 
 			// 正常端口扫描模式
-			runPortScan(host, ports, timeout, workers, scanMode, pluginManager, report)
+			targetSpec := host
+			if targetFile != "" {
+				targetSpec = targetFile
+			}
+			// 加载服务探测库
+			serviceDB := scanner.DefaultServiceDB()
+			if probeDB != "" {
+				serviceDB, err = scanner.LoadServiceDB(probeDB)
+				if err != nil {
+					fmt.Printf("❌ 错误：%v\n", err)
+					return
+				}
+				fmt.Printf("📚 已加载探测库 %s：%d 个探测（跳过 %d 条不兼容的规则）\n", probeDB, len(serviceDB.Probes), serviceDB.Skipped)
+			}
+			probing := probeSettings{db: serviceDB, intensity: intensity, tls: !noTLS}
+			output := outputSettings{file: report, format: format, history: historyDB}
+
+			runPortScan(ctx, targetSpec, hosts, ports, timing, probing, scanMode, scanType, pluginManager, categories, output, skipPing)
 		},
 	}
 
 	// 定义命令行标志
-	rootCmd.Flags().StringVarP(&host, "host", "H", "localhost", "要扫描的主机名或IP地址")
+	rootCmd.Flags().StringVarP(&host, "host", "H", "localhost", "扫描目标，支持主机名、IP、CIDR、范围（10.0.0.1-50）及逗号列表")
+	rootCmd.Flags().StringVarP(&targetFile, "target-file", "i", "", "从文件读取扫描目标（类似nmap -iL）")
+	rootCmd.Flags().StringVar(&exclude, "exclude", "", "排除的目标，写法同 --host")
+	rootCmd.Flags().StringVar(&excludeFile, "exclude-file", "", "从文件读取排除的目标")
 	rootCmd.Flags().StringVarP(&ports, "ports", "p", "1-100", "端口范围，如：80,443 或 1-1000")
 	rootCmd.Flags().IntVarP(&timeout, "timeout", "t", 2, "连接超时时间（秒）")
 	rootCmd.Flags().IntVarP(&workers, "workers", "w", 100, "并发工作线程数")
 	rootCmd.Flags().StringVarP(&pluginArg, "plugin", "P", "", "运行指定插件扫描")
 	rootCmd.Flags().StringVarP(&scanMode, "mode", "m", "normal", "扫描模式: normal（普通）, security（安全扫描）")
-	rootCmd.Flags().StringVarP(&report, "report", "r", "", "生成HTML报告文件")
+	rootCmd.Flags().StringVar(&pluginCats, "plugin-categories", plugin.CategorySafe, "安全扫描模式下自动运行的插件类别，逗号分隔: safe, intrusive, bruteforce（口令猜测可能触发账号锁定）")
+	rootCmd.Flags().StringVarP(&scanType, "scan-type", "s", "tcp", "扫描类型: tcp, udp")
+	rootCmd.Flags().StringVarP(&timingName, "timing", "T", "normal", "时序模板: paranoid, polite, normal, aggressive")
+	rootCmd.Flags().IntVar(&rate, "rate", 0, "每秒最多发起的探测数，0 表示不限制（覆盖时序模板）")
+	rootCmd.Flags().IntVar(&hostProbes, "max-host-probes", 0, "单台主机的最大并发探测数，0 表示不限制（覆盖时序模板）")
+	rootCmd.Flags().IntVar(&scanDelay, "scan-delay", 0, "每次探测前的延迟（毫秒，覆盖时序模板）")
+	rootCmd.Flags().IntVar(&jitter, "jitter", 0, "随机附加延迟上限（毫秒，覆盖时序模板）")
+	rootCmd.Flags().IntVar(&retries, "retries", 0, "探测超时后的重试次数（覆盖时序模板）")
+	rootCmd.Flags().StringVar(&probeDB, "probe-db", "", "服务探测库文件（nmap-service-probes 格式），默认使用内置探测库")
+	rootCmd.Flags().IntVar(&intensity, "version-intensity", 7, "服务探测强度 0-9，越大发送的探测越多")
+	rootCmd.Flags().BoolVar(&noTLS, "no-tls", false, "不对开放端口进行TLS握手和证书收集")
+	rootCmd.Flags().StringVarP(&report, "report", "r", "", "生成报告文件")
+	rootCmd.Flags().StringVarP(&format, "format", "f", "html", "报告格式: html, json（含所有端口的单个文档）, jsonl（扫描过程中逐行写出）, xml（nmap XML）")
+	rootCmd.Flags().StringVar(&historyDB, "history", "", "把扫描结果保存到历史库文件（如 "+defaultHistoryDB+"），可用 'netscanner history' 查询")
+	rootCmd.Flags().StringVar(&importNmap, "import-nmap", "", "读取 nmap XML 结果（nmap -oX），对其中的开放端口运行安全插件而不重新扫描")
+	rootCmd.PersistentFlags().StringVar(&pluginDir, "plugin-dir", defaultPluginDir, "插件目录，其中的 .yaml、.yml、.json 检测模板和 .star 脚本会注册为插件")
+	rootCmd.PersistentFlags().BoolVar(&pluginOpts.externalPlugins, "external-plugins", false, "同时把插件目录中的可执行文件注册为外部插件（stdin/stdout JSON 协议）；启动时会执行这些文件，只对可信目录开启")
+	rootCmd.PersistentFlags().BoolVar(&pluginOpts.sshCreds, "ssh-credentials", false, "SSH 审计插件额外测试常见口令（可能触发账号锁定或告警，需显式开启；安全扫描模式下还需 --plugin-categories 包含 bruteforce）")
+	rootCmd.PersistentFlags().BoolVar(&pluginOpts.redisCreds, "redis-credentials", false, "Redis 插件在服务器要求认证时测试常见口令（需显式开启；安全扫描模式下还需 --plugin-categories 包含 bruteforce）")
+	rootCmd.PersistentFlags().BoolVar(&pluginOpts.mysqlCreds, "mysql-credentials", false, "MySQL 插件额外测试常见账号口令（可能触发账号锁定或告警，需显式开启；安全扫描模式下还需 --plugin-categories 包含 bruteforce）")
+	rootCmd.PersistentFlags().BoolVar(&pluginOpts.postgresCreds, "postgres-credentials", false, "PostgreSQL 插件额外测试常见账号口令（可能触发账号锁定或告警，需显式开启；安全扫描模式下还需 --plugin-categories 包含 bruteforce）")
+	rootCmd.PersistentFlags().BoolVar(&pluginOpts.httpSkipVerify, "http-skip-verify", false, "HTTP 安全插件访问 HTTPS 时不验证证书（自签名或内网证书）")
+	rootCmd.Flags().BoolVar(&skipPing, "skip-discovery", false, "跳过主机发现，视所有主机为在线（类似nmap -Pn）")
 
 	// 添加插件子命令
 	pluginCmd := &cobra.Command{
 		Use:   "plugins",
 		Short: "管理插件",
 		Run: func(cmd *cobra.Command, args []string) {
-			pluginManager := initializePlugins()
+			pluginManager := initializePlugins(pluginDir, pluginOpts)
 			listPlugins(pluginManager)
 		},
 	}
 	rootCmd.AddCommand(pluginCmd)
 
+	// 输出JSON报告的 JSON Schema
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: fmt.Sprintf("输出JSON报告的 JSON Schema（版本 %s）", reporter.JSONSchemaVersion),
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Stdout.Write(reporter.JSONSchema())
+		},
+	}
+	rootCmd.AddCommand(schemaCmd)
+
+	// 对比两次扫描结果
+	var diffFormat, diffOutput string
+	diffCmd := &cobra.Command{
+		Use:   "diff <旧结果> <新结果>",
+		Short: "对比两次扫描结果（JSON报告或 nmap XML）",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runDiff(args[0], args[1], diffFormat, diffOutput)
+		},
+	}
+	diffCmd.Flags().StringVarP(&diffFormat, "format", "f", "text", "输出格式: text, json, html")
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "", "输出文件，html 格式必须指定，其他格式默认输出到终端")
+	rootCmd.AddCommand(diffCmd)
+
+	rootCmd.AddCommand(newHistoryCmd())
+
+	// REST API 服务
+	var serveConfig server.Config
+	var listenAddr string
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "以HTTP JSON API和Web控制台的方式提供扫描服务",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !cmd.Flags().Changed("token") {
+				serveConfig.Token = os.Getenv("NETSCANNER_TOKEN")
+			}
+			runServer(cmd.Context(), listenAddr, serveConfig, pluginDir, pluginOpts)
+		},
+	}
+	serveCmd.Flags().StringVarP(&listenAddr, "listen", "l", "127.0.0.1:8080", "监听地址")
+	serveCmd.Flags().StringVar(&serveConfig.Token, "token", "", "API令牌，默认读取环境变量 NETSCANNER_TOKEN，都未设置时随机生成")
+	serveCmd.Flags().IntVar(&serveConfig.QueueSize, "queue-size", 16, "排队任务的上限，队列满时拒绝新任务")
+	serveCmd.Flags().IntVar(&serveConfig.Concurrency, "concurrency", 2, "同时执行的任务数")
+	serveCmd.Flags().IntVar(&serveConfig.MaxJobs, "max-jobs", 200, "内存中保留的任务数")
+	rootCmd.AddCommand(serveCmd)
+
+	// Ctrl-C / SIGTERM 时取消扫描，已收集的结果仍会输出；
+	// 第一次中断后恢复默认信号处理，再次按下 Ctrl-C 可直接退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
 	// 执行命令
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println("错误:", err)
 	}
 }
 
-// initializePlugins 初始化插件系统
-func initializePlugins() *plugin.PluginManager {
+// defaultPluginDir 默认的插件目录
+const defaultPluginDir = "plugins"
+
+// pluginOptions 内置插件的可选行为
+type pluginOptions struct {
+	sshCreds       bool // SSH 审计插件测试常见口令，需显式开启
+	redisCreds     bool // Redis 插件测试常见口令，需显式开启
+	mysqlCreds     bool // MySQL 插件测试常见口令，需显式开启
+	postgresCreds  bool // PostgreSQL 插件测试常见口令，需显式开启
+	httpSkipVerify bool // HTTP 安全插件不验证 HTTPS 证书
+
+	// externalPlugins 执行插件目录中的可执行文件并注册为外部插件，需显式开启，
+	// 否则从不可信的工作目录运行时会执行其中的任意程序
+	externalPlugins bool
+}
+
+// initializePlugins 初始化插件系统，opts 设置内置插件的可选行为
+func initializePlugins(pluginDir string, opts pluginOptions) *plugin.PluginManager {
 	pm := plugin.NewPluginManager()
 
 	// 注册插件
 	pm.RegisterPlugin(&plugin.FTPWeakPassPlugin{})
-	pm.RegisterPlugin(&plugin.HTTPSecurityPlugin{})
+	pm.RegisterPlugin(&plugin.HTTPSecurityPlugin{InsecureSkipVerify: opts.httpSkipVerify})
+	pm.RegisterPlugin(&plugin.SSHAuditPlugin{TestCredentials: opts.sshCreds})
+	pm.RegisterPlugin(&plugin.RedisPlugin{TestCredentials: opts.redisCreds})
+	pm.RegisterPlugin(&plugin.MySQLPlugin{TestCredentials: opts.mysqlCreds})
+	pm.RegisterPlugin(&plugin.PostgreSQLPlugin{TestCredentials: opts.postgresCreds})
+	pm.RegisterPlugin(&plugin.MongoDBPlugin{})
+	pm.RegisterPlugin(&plugin.ElasticsearchPlugin{})
+	pm.RegisterPlugin(&plugin.CouchDBPlugin{})
+	pm.RegisterPlugin(&plugin.MemcachedPlugin{})
+
+	loadPluginDir(pm, pluginDir, opts.externalPlugins)
 
 	return pm
 }
 
+// loadPluginDir 加载插件目录中的模板、脚本并注册，external 为 true 时还加载外部插件；
+// 默认目录不存在时跳过
+func loadPluginDir(pm *plugin.PluginManager, dir string, external bool) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) && dir == defaultPluginDir {
+		return
+	}
+
+	templates, err := plugin.LoadTemplates(dir)
+	printLoadErrors("模板", err)
+	for _, t := range templates {
+		registerLoaded(pm, t, t.Path)
+	}
+
+	scripts, err := plugin.LoadScripts(dir)
+	printLoadErrors("脚本", err)
+	for _, s := range scripts {
+		registerLoaded(pm, s, s.Path)
+	}
+
+	if !external {
+		return
+	}
+	externals, err := plugin.LoadExternalPlugins(context.Background(), dir)
+	printLoadErrors("外部插件", err)
+	for _, p := range externals {
+		registerLoaded(pm, p, p.Path)
+	}
+}
+
+// printLoadErrors 输出加载插件目录时的错误，单个文件的错误不影响其他插件
+func printLoadErrors(kind string, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			fmt.Printf("⚠️ 跳过%s：%v\n", kind, e)
+		}
+	} else if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+	}
+}
+
+// registerLoaded 注册从文件加载的插件，与已注册的插件重名时跳过
+func registerLoaded(pm *plugin.PluginManager, p plugin.Plugin, path string) {
+	if _, exists := pm.GetPlugin(p.Name()); exists {
+		fmt.Printf("⚠️ %s 与已注册的插件 %s 重名，已跳过\n", path, p.Name())
+		return
+	}
+	pm.RegisterPlugin(p)
+}
+
 // listPlugins 列出所有插件
 func listPlugins(pm *plugin.PluginManager) {
 	fmt.Println("📦 可用插件：")
-	for _, name := range pm.ListPlugins() {
-		if p, exists := pm.GetPlugin(name); exists {
-			fmt.Printf("  • %s: %s\n", p.Name(), p.Description())
+	names := pm.ListPlugins()
+	sort.Strings(names)
+	for _, name := range names {
+		p, exists := pm.GetPlugin(name)
+		if !exists {
+			continue
 		}
+		meta := p.Metadata()
+		fmt.Printf("  • %s [%s]: %s\n", p.Name(), meta.Category, p.Description())
+		if len(meta.Services) > 0 || len(meta.Ports) > 0 {
+			fmt.Printf("      服务: %s  端口: %s\n", joinOrDash(meta.Services), joinOrDash(intStrings(meta.Ports)))
+		}
+		if meta.Author != "" {
+			fmt.Printf("      作者: %s\n", meta.Author)
+		}
+		for _, ref := range meta.References {
+			fmt.Printf("      参考: %s\n", ref)
+		}
+	}
+}
+
+// joinOrDash 用逗号连接，为空时返回 -
+func joinOrDash(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ",")
+}
+
+// intStrings 把整数列表转换为字符串列表
+func intStrings(values []int) []string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = strconv.Itoa(v)
 	}
+	return items
 }
 
-// runPluginScan 运行插件扫描
-func runPluginScan(host, pluginName string, pm *plugin.PluginManager, timeout int) {
+// runPluginScan 运行插件扫描，未指定端口时使用插件声明的第一个默认端口
+func runPluginScan(ctx context.Context, host, pluginName string, pm *plugin.PluginManager, ports []int, timeout int) {
 	p, exists := pm.GetPlugin(pluginName)
 	if !exists {
 		fmt.Printf("❌ 插件不存在: %s\n", pluginName)
@@ -102,20 +393,33 @@ func runPluginScan(host, pluginName string, pm *plugin.PluginManager, timeout in
 		return
 	}
 
-	// 根据插件类型确定默认端口
-	defaultPort := 21 // FTP
-	if pluginName == "http-security" {
-		defaultPort = 80
+	if len(ports) == 0 {
+		if defaults := p.Metadata().Ports; len(defaults) > 0 {
+			ports = defaults[:1]
+		}
+	}
+	if len(ports) == 0 {
+		fmt.Printf("❌ 插件 %s 没有默认端口，请使用 -p 指定\n", pluginName)
+		return
 	}
 
-	fmt.Printf("🔍 使用插件 %s 扫描 %s:%d\n", pluginName, host, defaultPort)
+	for _, port := range ports {
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("🔍 使用插件 %s 扫描 %s:%d\n", pluginName, host, port)
 
-	result, err := p.Scan(host, defaultPort, time.Duration(timeout)*time.Second)
-	if err != nil {
-		fmt.Printf("❌ 扫描失败: %v\n", err)
-		return
+		result, err := plugin.ScanContext(ctx, p, host, port, time.Duration(timeout)*time.Second)
+		if err != nil {
+			fmt.Printf("❌ 扫描失败: %v\n", err)
+			continue
+		}
+		displayPluginResult(result)
 	}
+}
 
+// displayPluginResult 显示插件扫描结果和其中的各个问题
+func displayPluginResult(result plugin.Result) {
 	fmt.Println("📊 扫描结果：")
 	if result.Vulnerable {
 		fmt.Printf("  状态: 🔴 存在风险\n")
@@ -125,156 +429,446 @@ func runPluginScan(host, pluginName string, pm *plugin.PluginManager, timeout in
 		fmt.Printf("  状态: 🟢 安全\n")
 		fmt.Printf("  详情: %s\n", result.Details)
 	}
+	if len(result.Findings) == 0 {
+		if result.Vulnerable && result.Evidence != "" {
+			fmt.Printf("  证据: %s\n", strings.ReplaceAll(result.Evidence, "\n", "\n        "))
+		}
+		if result.Vulnerable && result.Remediation != "" {
+			fmt.Printf("  建议: %s\n", strings.ReplaceAll(result.Remediation, "\n", "\n        "))
+		}
+		return
+	}
+
+	for _, f := range result.Findings {
+		score := ""
+		if f.CVSS > 0 {
+			score = fmt.Sprintf(" CVSS %.1f", f.CVSS)
+		}
+		fmt.Printf("  • [%s%s] %s: %s\n", f.Severity, score, f.ID, f.Title)
+		if f.Evidence != "" {
+			fmt.Printf("      证据: %s\n", strings.ReplaceAll(f.Evidence, "\n", "\n            "))
+		}
+		if f.Remediation != "" {
+			fmt.Printf("      建议: %s\n", strings.ReplaceAll(f.Remediation, "\n", "\n            "))
+		}
+	}
+}
+
+// runServer 启动API服务，ctx 取消时优雅退出
+func runServer(ctx context.Context, addr string, config server.Config, pluginDir string, opts pluginOptions) {
+	if config.Token == "" {
+		config.Token = server.GenerateToken()
+		fmt.Printf("🔑 未指定令牌，已随机生成: %s\n", config.Token)
+	}
+
+	srv := server.New(config, initializePlugins(pluginDir, opts), nil)
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// 退出时结束仍在推送进度的 SSE 连接
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go srv.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("🌐 API服务已启动: http://%s/api/v1/scans（队列 %d，并发 %d）\n", addr, config.QueueSize, config.Concurrency)
+	fmt.Printf("🖥️  Web控制台: http://%s/\n", addr)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	fmt.Println("👋 API服务已停止")
+}
+
+// runDiff 对比两次保存的扫描结果
+func runDiff(oldPath, newPath, format, outputFile string) {
+	oldReport, err := reporter.ReadReport(oldPath)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	newReport, err := reporter.ReadReport(newPath)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	result := diff.Compare(oldReport, newReport)
+
+	if format == "html" {
+		if outputFile == "" {
+			fmt.Println("❌ 错误：html 格式需要用 --output 指定输出文件")
+			return
+		}
+		if err := diff.GenerateHTML(result, outputFile); err != nil {
+			fmt.Printf("❌ 生成报告失败: %v\n", err)
+			return
+		}
+		fmt.Printf("📄 HTML对比报告已生成: %s\n", outputFile)
+		return
+	}
+
+	out := os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			fmt.Printf("❌ 错误：创建文件失败: %v\n", err)
+			return
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch format {
+	case "text":
+		diff.WriteText(out, result)
+	case "json":
+		if err := diff.WriteJSON(out, result); err != nil {
+			fmt.Printf("❌ 错误：%v\n", err)
+		}
+	default:
+		fmt.Printf("❌ 错误：不支持的输出格式: %s\n", format)
+	}
+}
+
+// resolveTargets 展开目标表达式和目标文件，并剔除排除项
+func resolveTargets(host, targetFile, exclude, excludeFile string) ([]string, error) {
+	specs := []string{host}
+	if targetFile != "" {
+		fileSpecs, err := target.ReadFile(targetFile)
+		if err != nil {
+			return nil, err
+		}
+		specs = fileSpecs
+	}
+
+	var excludes []string
+	if exclude != "" {
+		excludes = append(excludes, exclude)
+	}
+	if excludeFile != "" {
+		fileSpecs, err := target.ReadFile(excludeFile)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(excludes, fileSpecs...)
+	}
+
+	hosts, err := target.Expand(specs, excludes)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("没有有效的扫描目标")
+	}
+	return hosts, nil
 }
 
 // runPortScan 运行端口扫描
-func runPortScan(host, ports string, timeout, workers int, scanMode string, pm *plugin.PluginManager, report string) {
+func runPortScan(ctx context.Context, targetSpec string, hosts []string, ports string, timing scanner.TimingProfile, probing probeSettings, scanMode, scanType string, pm *plugin.PluginManager, categories []string, output outputSettings, skipPing bool) {
+	if !output.valid() {
+		fmt.Printf("❌ 错误：不支持的报告格式: %s\n", output.format)
+		return
+	}
+
 	// 解析端口范围
-	portList := parsePorts(ports)
+	portList := scanner.ParsePorts(ports)
 	if len(portList) == 0 {
 		fmt.Println("❌ 错误：没有有效的端口可扫描")
 		return
 	}
 
-	// 清理主机地址
-	host = normalizeHost(host)
+	// 创建扫描器
+	var portScanner scanner.PortScanner
+	switch scanType {
+	case "tcp":
+		tcpScanner := scanner.NewTCPScanner(timing.Timeout, timing.Workers)
+		tcpScanner.ServiceDB = probing.db
+		tcpScanner.ProbeIntensity = probing.intensity
+		tcpScanner.DetectTLS = probing.tls
+		portScanner = tcpScanner
+	case "udp":
+		portScanner = scanner.NewUDPScanner(timing.Timeout, timing.Workers)
+	default:
+		fmt.Printf("❌ 错误：不支持的扫描类型: %s\n", scanType)
+		return
+	}
+	portScanner.ApplyTiming(timing)
+	timeout := int(timing.Timeout / time.Second)
+
+	start := time.Now()
+
+	// 主机发现，只扫描在线的主机
+	var statuses []scanner.HostStatus
+	if skipPing {
+		statuses = scanner.AssumeAlive(hosts)
+	} else {
+		statuses = discoverHosts(ctx, hosts, timeout, timing.Workers)
+	}
+	hosts = liveHosts(statuses)
+	if len(hosts) == 0 && ctx.Err() != nil {
+		fmt.Println("⚠️ 扫描在主机发现阶段被中断")
+		return
+	}
+	if len(hosts) == 0 {
+		fmt.Println("❌ 没有发现在线主机，可使用 --skip-discovery 跳过主机发现")
+		return
+	}
 
 	// 显示扫描信息
-	fmt.Printf("🚀 开始扫描 %s 的 %d 个端口...\n", host, len(portList))
-	fmt.Printf("  模式: %s, 超时: %ds, 并发数: %d\n\n", scanMode, timeout, workers)
+	if len(hosts) == 1 {
+		fmt.Printf("🚀 开始扫描 %s 的 %d 个%s端口...\n", hosts[0], len(portList), strings.ToUpper(scanType))
+	} else {
+		fmt.Printf("🚀 开始扫描 %d 台主机的 %d 个%s端口...\n", len(hosts), len(portList), strings.ToUpper(scanType))
+	}
+	fmt.Printf("  模式: %s, 时序: %s, 超时: %ds, 并发数: %d, 重试: %d\n", scanMode, timing.Name, timeout, timing.Workers, timing.Retries)
+	if timing.Rate > 0 || timing.MaxPerHost > 0 || timing.ScanDelay > 0 || timing.Jitter > 0 {
+		fmt.Printf("  限速: %d 次/秒, 单主机并发: %d, 延迟: %v, 抖动: %v\n", timing.Rate, timing.MaxPerHost, timing.ScanDelay, timing.Jitter)
+	}
+	fmt.Println()
 
-	// 创建扫描器
-	tcpScanner := scanner.NewTCPScanner(time.Duration(timeout)*time.Second, workers)
+	// JSON Lines 报告在扫描过程中逐行写出
+	var jsonl *reporter.JSONLWriter
+	if output.file != "" && output.format == "jsonl" {
+		w, err := reporter.CreateJSONLReport(output.file)
+		if err != nil {
+			fmt.Printf("❌ 生成报告失败: %v\n", err)
+			return
+		}
+		defer w.Close()
+		w.WriteStart(reporter.ScanReport{Target: targetSpec, Mode: scanMode, ScanType: scanType, StartTime: start})
+		jsonl = w
+	}
+	// JSON和XML报告包含所有端口，其他情况下关闭端口只计数不保留
+	keepAll := output.file != "" && (output.format == "json" || output.format == "xml")
 
-	start := time.Now()
-	results := tcpScanner.ScanPorts(host, portList)
+	// 流式读取结果：开放端口即时输出
+	var results, allResults []scanner.ScanResult
+	var stats reporter.PortCounts
+	for result := range portScanner.StreamTargets(ctx, hosts, portList) {
+		stats.Add(result.State, result.IPVersion)
+		if jsonl != nil {
+			jsonl.WritePort(reporter.FromScanResult(result))
+		}
+		if keepAll {
+			allResults = append(allResults, result)
+		}
+		if !isReportable(result) {
+			continue
+		}
+		results = append(results, result)
+		fmt.Printf("  [+] %s\t%d/%s\t%s\t%s\t%s\n", result.Host, result.Port, result.Protocol, result.State, result.Service, productVersion(result))
+	}
 	elapsed := time.Since(start)
+	if len(results) > 0 {
+		fmt.Println()
+	}
+
+	// 被中断时仍输出已完成的部分
+	incomplete := ctx.Err() != nil
+	if incomplete {
+		fmt.Printf("\n⚠️ 扫描被中断，以下结果不完整（已完成 %d/%d 个探测）\n\n", stats.Total, len(hosts)*len(portList))
+	}
+
+	// 按主机和端口排序，便于分组显示
+	sortResults(hosts, results)
 
 	// 显示结果
-	displayResults(results, scanMode, pm, host, timeout)
+	findings := displayResults(ctx, hosts, results, stats, scanMode, pm, categories, timeout)
 
-	// 生成HTML报告
-	if report != "" {
-		generateHTMLReport(host, start, time.Now(), results, report)
+	// 生成报告并保存到历史库
+	if output.file != "" || output.history != "" {
+		report := buildReport(targetSpec, hosts, statuses, start, time.Now(), stats, incomplete)
+		report.Mode, report.ScanType, report.Ports = scanMode, scanType, ports
+		if keepAll {
+			sortResults(hosts, allResults)
+			report.Hosts = groupResults(hosts, allResults, findings)
+		} else {
+			report.Hosts = groupResults(hosts, results, findings)
+		}
+		if output.file != "" {
+			writeReport(output, report, jsonl)
+		}
+		if output.history != "" {
+			saveHistory(output.history, history.Params{
+				Target:   targetSpec,
+				Hosts:    hosts,
+				Ports:    ports,
+				Mode:     scanMode,
+				ScanType: scanType,
+				Timing:   timing.Name,
+			}, report)
+		}
 	}
 
+	if incomplete {
+		fmt.Printf("\n⚠️ 扫描未完成（已中断）！耗时: %v\n", elapsed)
+		return
+	}
 	fmt.Printf("\n✅ 扫描完成！耗时: %v\n", elapsed)
 }
 
-// normalizeHost 规范化主机地址
-func normalizeHost(host string) string {
-	host = strings.TrimSpace(host)
-	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
-		return strings.Trim(host, "[]")
+// probeSettings 服务探测设置
+type probeSettings struct {
+	db        *scanner.ServiceDB
+	intensity int
+	tls       bool
+}
+
+// outputSettings 报告输出设置
+type outputSettings struct {
+	file    string // 报告文件，为空时不生成报告
+	format  string // html、json、jsonl 或 xml
+	history string // 历史库文件，为空时不记录
+}
+
+// valid 报告格式是否受支持
+func (o outputSettings) valid() bool {
+	switch o.format {
+	case "html", "json", "jsonl", "xml":
+		return true
 	}
-	return host
+	return false
 }
 
-// parsePorts 解析端口字符串
-func parsePorts(portStr string) []int {
-	var ports []int
-	portMap := make(map[int]bool) // 使用map去重
+// isReportable 是否需要保留并展示该结果
+func isReportable(result scanner.ScanResult) bool {
+	return result.State == "open" || result.State == "open|filtered"
+}
 
-	// 分割逗号分隔的部分
-	parts := strings.Split(portStr, ",")
+// discoverHosts 运行主机发现并输出结果
+func discoverHosts(ctx context.Context, hosts []string, timeout, workers int) []scanner.HostStatus {
+	fmt.Printf("🔎 正在进行主机发现（%d 台主机）...\n", len(hosts))
 
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
+	discoverer := scanner.NewHostDiscoverer(time.Duration(timeout)*time.Second, workers)
+	statuses := discoverer.DiscoverContext(ctx, hosts)
+
+	alive := 0
+	for _, st := range statuses {
+		if st.Alive {
+			alive++
+			fmt.Printf("  ✓ %s\t%s\t%v\n", st.Host, st.Method, st.Latency.Round(time.Microsecond))
 		}
+	}
+	fmt.Printf("  在线主机: %d/%d\n\n", alive, len(hosts))
 
-		// 判断是否是范围
-		if strings.Contains(part, "-") {
-			rangeParts := strings.Split(part, "-")
-			if len(rangeParts) == 2 {
-				start, err1 := strconv.Atoi(strings.TrimSpace(rangeParts[0]))
-				end, err2 := strconv.Atoi(strings.TrimSpace(rangeParts[1]))
+	return statuses
+}
 
-				if err1 == nil && err2 == nil && start > 0 && end <= 65535 && start <= end {
-					for port := start; port <= end; port++ {
-						if port > 0 && port <= 65535 {
-							portMap[port] = true
-						}
-					}
-				}
-			}
-		} else {
-			// 单个端口
-			if port, err := strconv.Atoi(part); err == nil && port > 0 && port <= 65535 {
-				portMap[port] = true
-			}
+// liveHosts 返回在线主机列表
+func liveHosts(statuses []scanner.HostStatus) []string {
+	var hosts []string
+	for _, st := range statuses {
+		if st.Alive {
+			hosts = append(hosts, st.Host)
 		}
 	}
+	return hosts
+}
 
-	// 将map转换为切片
-	for port := range portMap {
-		ports = append(ports, port)
+// sortResults 按主机在目标列表中的顺序和端口号排序
+func sortResults(hosts []string, results []scanner.ScanResult) {
+	order := make(map[string]int, len(hosts))
+	for i, h := range hosts {
+		order[h] = i
 	}
-
-	// 排序（可选，便于阅读）
-	sort.Ints(ports)
-
-	return ports
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return order[results[i].Host] < order[results[j].Host]
+		}
+		return results[i].Port < results[j].Port
+	})
 }
 
-// displayResults 显示扫描结果
-func displayResults(results []scanner.ScanResult, scanMode string, pm *plugin.PluginManager, host string, timeout int) {
-	openCount := 0
-	ipv6Count := 0
-
-	fmt.Println("端口\t状态\t服务\t\tIP版本\tBanner")
-	fmt.Println("----\t----\t----\t\t------\t------")
+// displayResults 按主机分组显示扫描结果，results 只含开放端口且需已按主机排序
+// 返回安全扫描模式下的插件检测结果，按 resultKey 索引；只自动运行 categories 中类别的插件
+func displayResults(ctx context.Context, hosts []string, results []scanner.ScanResult, stats reporter.PortCounts, scanMode string, pm *plugin.PluginManager, categories []string, timeout int) map[string][]reporter.Finding {
+	liveHosts := 0
+	findings := make(map[string][]reporter.Finding)
 
+	byHost := make(map[string][]scanner.ScanResult, len(hosts))
 	for _, result := range results {
-		if result.State == "open" {
-			openCount++
-			if result.IPVersion == "IPv6" {
-				ipv6Count++
+		byHost[result.Host] = append(byHost[result.Host], result)
+	}
+
+	for _, host := range hosts {
+		hostResults := byHost[host]
+		if len(hostResults) == 0 {
+			// 单主机扫描时仍输出空表，保持原有输出格式
+			if len(hosts) > 1 {
+				continue
 			}
+		}
+		liveHosts++
+
+		if len(hosts) > 1 {
+			fmt.Printf("🖥️  主机: %s（%d 个开放端口）\n", host, len(hostResults))
+		}
+		fmt.Println("端口\t状态\t服务\t\tIP版本\t版本\t\tBanner")
+		fmt.Println("----\t----\t----\t\t------\t----\t\t------")
 
+		for _, result := range hostResults {
 			// 截断过长的banner
 			banner := result.Banner
 			if len(banner) > 30 {
 				banner = banner[:27] + "..."
 			}
 
-			fmt.Printf("%d\t%s\t%s\t\t%s\t%s\n",
-				result.Port, result.State, result.Service, result.IPVersion, banner)
+			fmt.Printf("%d/%s\t%s\t%s\t\t%s\t%s\t\t%s\n",
+				result.Port, result.Protocol, result.State, result.Service, result.IPVersion, limitString(productVersion(result), 30), banner)
+			if result.TLS != nil {
+				displayTLS(result.TLS)
+			}
 
-			// 如果是安全扫描模式，运行相关插件
-			if scanMode == "security" {
-				runSecurityPlugins(pm, host, result.Port, result.Service, timeout)
+			// 如果是安全扫描模式，运行相关插件（UDP服务暂无插件），中断后不再运行
+			if scanMode == "security" && result.Protocol == "tcp" && ctx.Err() == nil {
+				if found := runSecurityPlugins(ctx, pm, categories, host, result.Port, result.Service, timeout); len(found) > 0 {
+					findings[resultKey(host, result.Port, result.Protocol)] = found
+				}
 			}
 		}
+		if len(hosts) > 1 {
+			fmt.Println()
+		}
 	}
 
 	fmt.Printf("\n📊 统计信息：\n")
-	fmt.Printf("  总端口数: %d\n", len(results))
-	fmt.Printf("  开放端口: %d\n", openCount)
-	fmt.Printf("  关闭端口: %d\n", len(results)-openCount)
-	if ipv6Count > 0 {
-		fmt.Printf("  IPv6端口: %d ✅\n", ipv6Count)
-	}
-}
-
-// runSecurityPlugins 运行安全插件
-func runSecurityPlugins(pm *plugin.PluginManager, host string, port int, service string, timeout int) {
-	// 根据服务类型选择插件
-	var pluginName string
-	switch service {
-	case "ftp":
-		pluginName = "ftp-weakpass"
-	case "http", "https":
-		pluginName = "http-security"
-	default:
-		return
+	if len(hosts) > 1 {
+		fmt.Printf("  主机数: %d（有开放端口: %d）\n", len(hosts), liveHosts)
+	}
+	fmt.Printf("  总端口数: %d\n", stats.Total)
+	fmt.Printf("  开放端口: %d\n", stats.Open)
+	if stats.OpenFiltered > 0 {
+		fmt.Printf("  开放|过滤: %d\n", stats.OpenFiltered)
+	}
+	if stats.Filtered > 0 {
+		fmt.Printf("  过滤端口: %d（无响应或被防火墙拒绝）\n", stats.Filtered)
+	}
+	if stats.Unreachable > 0 {
+		fmt.Printf("  不可达: %d（主机或网络不可达）\n", stats.Unreachable)
 	}
+	fmt.Printf("  关闭端口: %d\n", stats.Closed)
+	if stats.IPv6 > 0 {
+		fmt.Printf("  IPv6端口: %d ✅\n", stats.IPv6)
+	}
+
+	return findings
+}
 
-	if p, exists := pm.GetPlugin(pluginName); exists {
+// runSecurityPlugins 运行 categories 中类别的安全插件，返回成功完成的检查结果
+func runSecurityPlugins(ctx context.Context, pm *plugin.PluginManager, categories []string, host string, port int, service string, timeout int) []reporter.Finding {
+	var findings []reporter.Finding
+	for _, p := range pm.ForService(service, port, categories) {
+		pluginName := p.Name()
 		fmt.Printf("  🔍 对 %s:%d 运行 %s 检查...\n", host, port, pluginName)
 
-		result, err := p.Scan(host, port, time.Duration(timeout)*time.Second)
+		result, err := plugin.ScanContext(ctx, p, host, port, time.Duration(timeout)*time.Second)
 		if err == nil {
 			if result.Vulnerable {
 				fmt.Printf("    ⚠️ 风险等级: %s\n", result.Severity)
@@ -282,10 +876,56 @@ func runSecurityPlugins(pm *plugin.PluginManager, host string, port int, service
 			} else {
 				fmt.Printf("    ✓ %s\n", result.Details)
 			}
+			for _, f := range result.Findings {
+				if f.Severity != "info" {
+					fmt.Printf("      • [%s] %s\n", f.Severity, limitString(f.Title, 60))
+				}
+			}
+			findings = append(findings, reporter.FromPluginResult(pluginName, result)...)
 		} else {
 			fmt.Printf("    ⚠️ 检查失败: %v\n", err)
 		}
 	}
+	return findings
+}
+
+// resultKey 端口结果的唯一标识，用于关联插件检测结果
+func resultKey(host string, port int, protocol string) string {
+	return net.JoinHostPort(host, strconv.Itoa(port)) + "/" + protocol
+}
+
+// displayTLS 显示TLS握手结果和服务器证书的问题
+func displayTLS(info *scanner.TLSInfo) {
+	fmt.Printf("  🔒 %s %s", info.Version, info.CipherSuite)
+	if info.ALPN != "" {
+		fmt.Printf(" ALPN=%s", info.ALPN)
+	}
+	fmt.Println()
+	if len(info.Certificates) == 0 {
+		return
+	}
+
+	cert := info.Certificates[0]
+	fmt.Printf("     证书: %s（%s %d位，有效期至 %s）\n", cert.Subject, cert.KeyType, cert.KeyBits, cert.NotAfter.Format("2006-01-02"))
+
+	daysLeft := int(time.Until(cert.NotAfter).Hours() / 24)
+	switch {
+	case daysLeft < 0:
+		fmt.Printf("     ⚠️ 证书已过期\n")
+	case time.Until(cert.NotAfter) < reporter.CertExpiryWarning:
+		fmt.Printf("     ⚠️ 证书将在 %d 天后过期\n", daysLeft)
+	}
+	if cert.SelfSigned {
+		fmt.Printf("     ⚠️ 自签名证书\n")
+	}
+	if !info.HostnameMatch {
+		fmt.Printf("     ⚠️ 证书与主机名不匹配\n")
+	}
+}
+
+// productVersion 组合产品名和版本号
+func productVersion(result scanner.ScanResult) string {
+	return strings.TrimSpace(result.Product + " " + result.Version)
 }
 
 // limitString 限制字符串长度
@@ -296,52 +936,197 @@ func limitString(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
-// generateHTMLReport 生成HTML报告
-func generateHTMLReport(host string, startTime, endTime time.Time, results []scanner.ScanResult, reportFile string) {
-	// 统计信息
-	openCount := 0
-	ipv6Count := 0
-	var openResults []scanner.ScanResult
+// buildReport 准备报告的元数据和统计信息，Hosts 由调用方填充
+func buildReport(targetSpec string, hosts []string, statuses []scanner.HostStatus, startTime, endTime time.Time, stats reporter.PortCounts, incomplete bool) reporter.ScanReport {
+	report := reporter.ScanReport{
+		Target:     targetSpec,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Duration:   endTime.Sub(startTime),
+		TotalHosts: len(hosts),
+		Incomplete: incomplete,
+	}
+	report.SetPortCounts(stats)
+
+	// 主机发现结果，跳过发现时不输出
+	for _, st := range statuses {
+		if st.Method == "assumed" {
+			continue
+		}
+		report.Discovery = append(report.Discovery, reporter.HostDiscovery{
+			Host:    st.Host,
+			Alive:   st.Alive,
+			Method:  st.Method,
+			Latency: st.Latency,
+		})
+	}
+
+	return report
+}
+
+// groupResults 按目标顺序把端口结果和插件检测结果分组到主机，results 需已按主机排序
+func groupResults(hosts []string, results []scanner.ScanResult, findings map[string][]reporter.Finding) []reporter.HostResult {
+	byHost := make(map[string]*reporter.HostResult, len(hosts))
 
 	for _, result := range results {
+		hr, ok := byHost[result.Host]
+		if !ok {
+			hr = &reporter.HostResult{Host: result.Host}
+			byHost[result.Host] = hr
+		}
 		if result.State == "open" {
-			openCount++
-			openResults = append(openResults, result)
-			if result.IPVersion == "IPv6" {
-				ipv6Count++
-			}
+			hr.OpenPorts++
 		}
+		rr := reporter.FromScanResult(result)
+		rr.Findings = findings[resultKey(result.Host, result.Port, result.Protocol)]
+		hr.Results = append(hr.Results, rr)
 	}
 
-	// 准备报告数据
-	report := reporter.ScanReport{
-		Target:      host,
-		StartTime:   startTime,
-		EndTime:     endTime,
-		Duration:    endTime.Sub(startTime),
-		TotalPorts:  len(results),
-		OpenPorts:   openCount,
-		ClosedPorts: len(results) - openCount,
-		IPv6Ports:   ipv6Count,
-		HasIPv6:     ipv6Count > 0,
-	}
-
-	// 转换结果格式
-	for _, r := range openResults {
-		report.Results = append(report.Results, reporter.ScanResult{
-			Port:      r.Port,
-			State:     r.State,
-			Service:   r.Service,
-			Banner:    r.Banner,
-			IPVersion: r.IPVersion,
-		})
+	var grouped []reporter.HostResult
+	for _, h := range hosts {
+		if hr, ok := byHost[h]; ok {
+			grouped = append(grouped, *hr)
+		}
+	}
+	return grouped
+}
+
+// writeReport 按格式生成报告，jsonl 格式的端口记录已在扫描过程中写出
+func writeReport(output outputSettings, report reporter.ScanReport, jsonl *reporter.JSONLWriter) {
+	var err error
+	switch output.format {
+	case "json":
+		err = reporter.GenerateJSONReport(report, output.file)
+	case "xml":
+		err = reporter.GenerateNmapXML(report, output.file)
+	case "jsonl":
+		for _, hr := range report.Hosts {
+			for _, r := range hr.Results {
+				for _, f := range r.Findings {
+					if err == nil {
+						err = jsonl.WriteFinding(r, f)
+					}
+				}
+			}
+		}
+		if err == nil {
+			err = jsonl.WriteEnd(report)
+		}
+	default:
+		err = reporter.GenerateHTMLReport(report, output.file)
 	}
 
-	// 生成报告
-	err := reporter.GenerateHTMLReport(report, reportFile)
 	if err != nil {
 		fmt.Printf("❌ 生成报告失败: %v\n", err)
 	} else {
-		fmt.Printf("📄 HTML报告已生成: %s\n", reportFile)
+		fmt.Printf("📄 %s报告已生成: %s\n", strings.ToUpper(output.format), output.file)
+	}
+}
+
+// runImportedScan 对 nmap XML 中的开放端口运行 categories 中类别的安全插件，不重新扫描
+func runImportedScan(ctx context.Context, path string, pm *plugin.PluginManager, categories []string, timeout int, output outputSettings) {
+	if !output.valid() {
+		fmt.Printf("❌ 错误：不支持的报告格式: %s\n", output.format)
+		return
+	}
+
+	imported, err := reporter.ReadNmapXML(path)
+	if err != nil {
+		fmt.Printf("❌ 错误：%v\n", err)
+		return
+	}
+	fmt.Printf("📥 已从 %s 导入 %d 台主机、%d 个开放端口\n\n", path, imported.TotalHosts, imported.OpenPorts)
+
+	start := time.Now()
+	report := buildReport(path, nil, nil, start, start, reporter.PortCounts{}, false)
+	report.Mode, report.ScanType = "security", imported.ScanType
+
+	var jsonl *reporter.JSONLWriter
+	if output.file != "" && output.format == "jsonl" {
+		jsonl, err = reporter.CreateJSONLReport(output.file)
+		if err != nil {
+			fmt.Printf("❌ 生成报告失败: %v\n", err)
+			return
+		}
+		defer jsonl.Close()
+		jsonl.WriteStart(report)
+	}
+
+	// 统计沿用导入的结果，nmap 只给出数量的 extraports 也计算在内
+	var hosts []string
+	var results, allResults []scanner.ScanResult
+	stats := imported.PortCounts()
+	for _, hr := range imported.Hosts {
+		hosts = append(hosts, hr.Host)
+		for _, r := range hr.Results {
+			result := fromReportResult(r)
+			allResults = append(allResults, result)
+			if jsonl != nil {
+				jsonl.WritePort(r)
+			}
+			if isReportable(result) {
+				results = append(results, result)
+			}
+		}
+	}
+	sortResults(hosts, results)
+
+	findings := displayResults(ctx, hosts, results, stats, "security", pm, categories, timeout)
+
+	if output.file != "" || output.history != "" {
+		report = buildReport(path, hosts, nil, start, time.Now(), stats, ctx.Err() != nil)
+		report.Mode, report.ScanType = "security", imported.ScanType
+		if output.format == "json" || output.format == "xml" {
+			report.Hosts = groupResults(hosts, allResults, findings)
+		} else {
+			report.Hosts = groupResults(hosts, results, findings)
+		}
+		if output.file != "" {
+			writeReport(output, report, jsonl)
+		}
+		if output.history != "" {
+			saveHistory(output.history, history.Params{
+				Target:   path,
+				Hosts:    hosts,
+				Mode:     "security",
+				ScanType: imported.ScanType,
+				Source:   path,
+			}, report)
+		}
+	}
+}
+
+// saveHistory 把扫描结果保存到历史库
+func saveHistory(path string, params history.Params, report reporter.ScanReport) {
+	store, err := history.Open(path)
+	if err != nil {
+		fmt.Printf("❌ 保存历史记录失败: %v\n", err)
+		return
+	}
+	defer store.Close()
+
+	id, err := store.Save(params, report)
+	if err != nil {
+		fmt.Printf("❌ 保存历史记录失败: %v\n", err)
+		return
+	}
+	fmt.Printf("🗄️ 扫描记录已保存到 %s（ID: %d）\n", path, id)
+}
+
+// fromReportResult 把导入的报告结果转换为扫描结果
+func fromReportResult(r reporter.ScanResult) scanner.ScanResult {
+	return scanner.ScanResult{
+		Host:      r.Host,
+		Port:      r.Port,
+		Protocol:  r.Protocol,
+		State:     r.State,
+		Reason:    r.Reason,
+		Service:   r.Service,
+		Banner:    r.Banner,
+		IPVersion: r.IPVersion,
+		Product:   r.Product,
+		Version:   r.Version,
+		ExtraInfo: r.ExtraInfo,
+		CPE:       r.CPE,
 	}
 }
@@ -0,0 +1,527 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"netscanner/internal/reporter"
+	"netscanner/internal/scanner"
+)
+
+// Report 两次扫描结果的差异
+type Report struct {
+	Old              ScanInfo        `json:"old"`
+	New              ScanInfo        `json:"new"`
+	NewPorts         []PortChange    `json:"new_ports"`
+	ClosedPorts      []PortChange    `json:"closed_ports"`
+	ChangedServices  []ServiceChange `json:"changed_services"`
+	ChangedBanners   []BannerChange  `json:"changed_banners"` // 服务识别结果相同、但去掉随机部分后banner仍不同的端口
+	NotScanned       []PortChange    `json:"not_scanned"`     // 旧扫描中开放、但新扫描没有探测的端口，不计入 closed_ports
+	NewFindings      []FindingChange `json:"new_findings"`
+	ResolvedFindings []FindingChange `json:"resolved_findings"`
+}
+
+// ScanInfo 参与对比的一次扫描
+type ScanInfo struct {
+	Target     string    `json:"target"`
+	ScanType   string    `json:"scan_type,omitempty"`
+	Ports      string    `json:"ports,omitempty"` // 端口范围表达式，旧版本报告中没有
+	StartTime  time.Time `json:"start_time"`
+	Hosts      int       `json:"hosts"`
+	OpenPorts  int       `json:"open_ports"`
+	Incomplete bool      `json:"incomplete"`
+}
+
+// PortChange 新开放或已关闭的端口
+type PortChange struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	Service  string `json:"service,omitempty"`
+	Product  string `json:"product,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// ServiceChange 两次扫描中都开放、但服务、产品或版本不同的端口
+type ServiceChange struct {
+	Host     string      `json:"host"`
+	Port     int         `json:"port"`
+	Protocol string      `json:"protocol"`
+	Old      Fingerprint `json:"old"`
+	New      Fingerprint `json:"new"`
+}
+
+// BannerChange 两次扫描中服务识别结果相同、但banner不同的端口
+type BannerChange struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	Old      string `json:"old"`
+	New      string `json:"new"`
+}
+
+// Fingerprint 端口的服务指纹，Banner 只用于显示，banner 的变化单独比较
+type Fingerprint struct {
+	Service string `json:"service,omitempty"`
+	Product string `json:"product,omitempty"`
+	Version string `json:"version,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+}
+
+// FindingChange 新出现或已修复的插件检测问题
+type FindingChange struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	Plugin   string `json:"plugin"`
+	ID       string `json:"id,omitempty"` // 插件给出的问题标识
+	Severity string `json:"severity,omitempty"`
+	Details  string `json:"details"`
+}
+
+// Empty 两次扫描没有差异
+func (r *Report) Empty() bool {
+	return len(r.NewPorts) == 0 && len(r.ClosedPorts) == 0 && len(r.ChangedServices) == 0 &&
+		len(r.ChangedBanners) == 0 && len(r.NewFindings) == 0 && len(r.ResolvedFindings) == 0
+}
+
+// portKey 端口的唯一标识
+type portKey struct {
+	host     string
+	port     int
+	protocol string
+}
+
+// findingKey 检测问题的唯一标识，插件返回多个问题时按问题标识区分
+type findingKey struct {
+	portKey
+	plugin string
+	id     string
+}
+
+// coverage 一次扫描探测过的端口
+type coverage struct {
+	listed   map[portKey]bool // 报告中列出的端口，不论状态
+	complete bool             // 报告列出了所有探测过的端口
+	alive    map[string]bool  // 主机发现判定存活的主机，没有发现结果时为 nil
+	ports    map[int]bool     // 端口范围表达式展开后的端口，未知时为 nil
+}
+
+// Compare 对比两次扫描结果
+// 只比较开放（含 open|filtered）的端口；旧扫描开放的端口只有在新扫描也探测过时才算关闭；
+// 服务变化比较服务、产品和版本；服务相同时再比较去掉数字、时间戳和随机串后的banner，单独列出；
+// 检测问题只比较存在风险的插件结果
+func Compare(oldReport, newReport reporter.ScanReport) *Report {
+	oldPorts := openPorts(oldReport)
+	newPorts := openPorts(newReport)
+	scanned := scanCoverage(newReport)
+
+	r := &Report{
+		Old:              scanInfo(oldReport, len(oldPorts)),
+		New:              scanInfo(newReport, len(newPorts)),
+		NewPorts:         []PortChange{},
+		ClosedPorts:      []PortChange{},
+		ChangedServices:  []ServiceChange{},
+		ChangedBanners:   []BannerChange{},
+		NotScanned:       []PortChange{},
+		NewFindings:      []FindingChange{},
+		ResolvedFindings: []FindingChange{},
+	}
+
+	for key, res := range newPorts {
+		old, existed := oldPorts[key]
+		if !existed {
+			r.NewPorts = append(r.NewPorts, portChange(res))
+			continue
+		}
+		if !sameService(old, res) {
+			r.ChangedServices = append(r.ChangedServices, ServiceChange{
+				Host:     res.Host,
+				Port:     res.Port,
+				Protocol: res.Protocol,
+				Old:      fingerprint(old),
+				New:      fingerprint(res),
+			})
+		} else if !sameBanner(old.Banner, res.Banner) {
+			r.ChangedBanners = append(r.ChangedBanners, BannerChange{
+				Host:     res.Host,
+				Port:     res.Port,
+				Protocol: res.Protocol,
+				Old:      old.Banner,
+				New:      res.Banner,
+			})
+		}
+	}
+	for key, res := range oldPorts {
+		if _, ok := newPorts[key]; ok {
+			continue
+		}
+		if scanned.covers(key) {
+			r.ClosedPorts = append(r.ClosedPorts, portChange(res))
+		} else {
+			r.NotScanned = append(r.NotScanned, portChange(res))
+		}
+	}
+
+	oldFindings := vulnerableFindings(oldReport)
+	newFindings := vulnerableFindings(newReport)
+	for key, f := range newFindings {
+		if _, ok := oldFindings[key]; !ok {
+			r.NewFindings = append(r.NewFindings, f)
+		}
+	}
+	for key, f := range oldFindings {
+		if _, ok := newFindings[key]; !ok {
+			r.ResolvedFindings = append(r.ResolvedFindings, f)
+		}
+	}
+
+	sortPorts(r.NewPorts)
+	sortPorts(r.ClosedPorts)
+	sortPorts(r.NotScanned)
+	sort.Slice(r.ChangedServices, func(i, j int) bool {
+		a, b := r.ChangedServices[i], r.ChangedServices[j]
+		return less(portKey{a.Host, a.Port, a.Protocol}, portKey{b.Host, b.Port, b.Protocol})
+	})
+	sort.Slice(r.ChangedBanners, func(i, j int) bool {
+		a, b := r.ChangedBanners[i], r.ChangedBanners[j]
+		return less(portKey{a.Host, a.Port, a.Protocol}, portKey{b.Host, b.Port, b.Protocol})
+	})
+	sortFindings(r.NewFindings)
+	sortFindings(r.ResolvedFindings)
+
+	return r
+}
+
+// openPorts 按端口索引开放端口
+func openPorts(report reporter.ScanReport) map[portKey]reporter.ScanResult {
+	ports := make(map[portKey]reporter.ScanResult)
+	for _, h := range report.Hosts {
+		for _, res := range h.Results {
+			if res.State == "open" || res.State == "open|filtered" {
+				ports[portKey{res.Host, res.Port, res.Protocol}] = res
+			}
+		}
+	}
+	return ports
+}
+
+// scanCoverage 根据报告推断扫描探测过哪些端口
+// 命令行的JSON和XML报告列出所有端口；只含开放端口的报告（如API任务结果）按主机发现结果和端口范围推断
+func scanCoverage(report reporter.ScanReport) coverage {
+	c := coverage{listed: make(map[portKey]bool)}
+	for _, h := range report.Hosts {
+		for _, res := range h.Results {
+			c.listed[portKey{res.Host, res.Port, res.Protocol}] = true
+		}
+	}
+	c.complete = report.TotalPorts > 0 && len(c.listed) == report.TotalPorts
+
+	if len(report.Discovery) > 0 {
+		c.alive = make(map[string]bool)
+		for _, d := range report.Discovery {
+			c.alive[d.Host] = d.Alive
+		}
+		for _, h := range report.Hosts {
+			c.alive[h.Host] = true
+		}
+	}
+	if report.Ports != "" {
+		c.ports = make(map[int]bool)
+		for _, p := range scanner.ParsePorts(report.Ports) {
+			c.ports[p] = true
+		}
+	}
+	return c
+}
+
+// covers 扫描是否探测过该端口，无法确定时视为探测过
+func (c coverage) covers(key portKey) bool {
+	if c.listed[key] {
+		return true
+	}
+	if c.complete {
+		return false
+	}
+	if c.alive != nil && !c.alive[key.host] {
+		return false
+	}
+	return c.ports == nil || c.ports[key.port]
+}
+
+// vulnerableFindings 按端口和插件索引存在风险的检测结果
+func vulnerableFindings(report reporter.ScanReport) map[findingKey]FindingChange {
+	findings := make(map[findingKey]FindingChange)
+	for _, h := range report.Hosts {
+		for _, res := range h.Results {
+			for _, f := range res.Findings {
+				if !f.Vulnerable {
+					continue
+				}
+				findings[findingKey{portKey{res.Host, res.Port, res.Protocol}, f.Plugin, f.ID}] = FindingChange{
+					Host:     res.Host,
+					Port:     res.Port,
+					Protocol: res.Protocol,
+					Plugin:   f.Plugin,
+					ID:       f.ID,
+					Severity: f.Severity,
+					Details:  f.Details,
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// scanInfo 提取扫描概要
+func scanInfo(report reporter.ScanReport, openPorts int) ScanInfo {
+	return ScanInfo{
+		Target:     report.Target,
+		ScanType:   report.ScanType,
+		Ports:      report.Ports,
+		StartTime:  report.StartTime,
+		Hosts:      report.TotalHosts,
+		OpenPorts:  openPorts,
+		Incomplete: report.Incomplete,
+	}
+}
+
+// portChange 提取端口信息
+func portChange(res reporter.ScanResult) PortChange {
+	return PortChange{
+		Host:     res.Host,
+		Port:     res.Port,
+		Protocol: res.Protocol,
+		Service:  res.Service,
+		Product:  res.Product,
+		Version:  res.Version,
+	}
+}
+
+// fingerprint 提取服务指纹
+func fingerprint(res reporter.ScanResult) Fingerprint {
+	return Fingerprint{
+		Service: res.Service,
+		Product: res.Product,
+		Version: res.Version,
+		Banner:  res.Banner,
+	}
+}
+
+// sameService 两次识别的服务、产品和版本是否相同
+func sameService(a, b reporter.ScanResult) bool {
+	return a.Service == b.Service && a.Product == b.Product && a.Version == b.Version
+}
+
+// sameBanner 两次的banner去掉随机部分后是否相同，一方没有banner（如读取超时）时不比较
+func sameBanner(a, b string) bool {
+	return a == "" || b == "" || normalizeBanner(a) == normalizeBanner(b)
+}
+
+var (
+	// bannerDate 日期，如 2024-05-01、Wed, 01 May 2024、May  1 2024
+	bannerDate = regexp.MustCompile(`\d{4}-\d{2}-\d{2}|(?:(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun),? )?\d{1,2} (?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) \d{4}|(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) +\d{1,2},? \d{4}`)
+	// bannerTime 时刻和时区，如 12:34:56.789+08:00、12:34:56 GMT
+	bannerTime = regexp.MustCompile(`\d{1,2}:\d{2}(?::\d{2})?(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2}| ?(?:GMT|UTC))?`)
+	// bannerNonce 十六进制或base64串，至少16个字符
+	bannerNonce = regexp.MustCompile(`[A-Za-z0-9+/]{16,}={0,2}`)
+	// bannerNumber 数字或点分数字，如 1896、2.4.58
+	bannerNumber = regexp.MustCompile(`\d+(?:\.\d+)*`)
+)
+
+// normalizeBanner 把banner中每次连接都会变化的部分替换为占位符
+// 日期、时刻和随机串整体替换；版本号（如 2.4.58、OpenSSH_9.6p1）保留，其余数字替换
+func normalizeBanner(s string) string {
+	s = bannerDate.ReplaceAllString(s, "<date>")
+	s = bannerTime.ReplaceAllString(s, "<time>")
+	s = bannerNonce.ReplaceAllStringFunc(s, func(m string) string {
+		// 不含数字的长串多是普通单词，如 ESMTPSERVICEREADY
+		if countDigits(m) < 2 {
+			return m
+		}
+		return "<nonce>"
+	})
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range bannerNumber.FindAllStringIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		sb.WriteString(s[last:start])
+		last = end
+		if isVersion(s, start, end) {
+			sb.WriteString(s[start:end])
+		} else {
+			sb.WriteString("<n>")
+		}
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// isVersion s[start:end] 处的数字是否像版本号
+// 点分数字每段不超过5位（超过的多是进程号和时间戳拼成的挑战值），单个数字则要紧挨着字母或下划线，如 9.6p1 中的 1
+func isVersion(s string, start, end int) bool {
+	m := s[start:end]
+	for _, part := range strings.Split(m, ".") {
+		if len(part) > 5 {
+			return false
+		}
+	}
+	if strings.Contains(m, ".") {
+		return true
+	}
+	word := func(c byte) bool {
+		return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+	}
+	return (start > 0 && word(s[start-1])) || (end < len(s) && word(s[end]))
+}
+
+// countDigits 统计字符串中的数字个数
+func countDigits(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// ParamsDiffer 两次扫描的类型或端口范围是否不同，报告中缺少的参数不比较
+func (r *Report) ParamsDiffer() bool {
+	differ := func(a, b string) bool { return a != "" && b != "" && a != b }
+	return differ(r.Old.ScanType, r.New.ScanType) || differ(r.Old.Ports, r.New.Ports)
+}
+
+// less 按主机、端口、协议排序
+func less(a, b portKey) bool {
+	if a.host != b.host {
+		return a.host < b.host
+	}
+	if a.port != b.port {
+		return a.port < b.port
+	}
+	return a.protocol < b.protocol
+}
+
+// sortPorts 排序端口变化
+func sortPorts(ports []PortChange) {
+	sort.Slice(ports, func(i, j int) bool {
+		return less(portKey{ports[i].Host, ports[i].Port, ports[i].Protocol}, portKey{ports[j].Host, ports[j].Port, ports[j].Protocol})
+	})
+}
+
+// sortFindings 按风险等级从高到低、再按端口排序
+func sortFindings(findings []FindingChange) {
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if ra, rb := reporter.SeverityRank(a.Severity), reporter.SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		ka, kb := portKey{a.Host, a.Port, a.Protocol}, portKey{b.Host, b.Port, b.Protocol}
+		if ka != kb {
+			return less(ka, kb)
+		}
+		if a.Plugin != b.Plugin {
+			return a.Plugin < b.Plugin
+		}
+		return a.ID < b.ID
+	})
+}
+
+// Name 问题的显示名称，插件返回了问题标识时为 插件/标识
+func (f FindingChange) Name() string {
+	if f.ID == "" {
+		return f.Plugin
+	}
+	return f.Plugin + "/" + f.ID
+}
+
+// address 格式化 主机:端口/协议
+func address(host string, port int, protocol string) string {
+	return net.JoinHostPort(host, strconv.Itoa(port)) + "/" + protocol
+}
+
+// String 格式化服务指纹
+func (f Fingerprint) String() string {
+	s := strings.TrimSpace(strings.Join([]string{f.Service, f.Product, f.Version}, " "))
+	if f.Banner != "" {
+		s += fmt.Sprintf(" [%s]", f.Banner)
+	}
+	return s
+}
+
+// WriteJSON 以JSON格式输出差异
+func WriteJSON(w io.Writer, r *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText 以文本格式输出差异
+func WriteText(w io.Writer, r *Report) {
+	fmt.Fprintf(w, "📊 对比 %s（%s，%d 个开放端口）→ %s（%s，%d 个开放端口）\n",
+		r.Old.Target, r.Old.StartTime.Format("2006-01-02 15:04:05"), r.Old.OpenPorts,
+		r.New.Target, r.New.StartTime.Format("2006-01-02 15:04:05"), r.New.OpenPorts)
+	if r.Old.Incomplete || r.New.Incomplete {
+		fmt.Fprintln(w, "⚠️ 至少有一次扫描被中断，差异可能不准确")
+	}
+	if r.ParamsDiffer() {
+		fmt.Fprintf(w, "⚠️ 两次扫描的参数不同（%s %s → %s %s），只有新扫描也探测过的端口才计入已关闭\n",
+			r.Old.ScanType, r.Old.Ports, r.New.ScanType, r.New.Ports)
+	}
+	if len(r.NotScanned) > 0 {
+		fmt.Fprintf(w, "\n❔ 新扫描未探测的端口（%d），不计入已关闭：\n", len(r.NotScanned))
+		for _, p := range r.NotScanned {
+			fmt.Fprintf(w, "  ? %s\t%s\n", address(p.Host, p.Port, p.Protocol), strings.TrimSpace(p.Service+" "+p.Product+" "+p.Version))
+		}
+	}
+	if r.Empty() {
+		fmt.Fprintln(w, "\n✅ 两次扫描没有差异")
+		return
+	}
+
+	if len(r.NewPorts) > 0 {
+		fmt.Fprintf(w, "\n🆕 新开放端口（%d）：\n", len(r.NewPorts))
+		for _, p := range r.NewPorts {
+			fmt.Fprintf(w, "  + %s\t%s\n", address(p.Host, p.Port, p.Protocol), strings.TrimSpace(p.Service+" "+p.Product+" "+p.Version))
+		}
+	}
+	if len(r.ClosedPorts) > 0 {
+		fmt.Fprintf(w, "\n🔒 已关闭端口（%d）：\n", len(r.ClosedPorts))
+		for _, p := range r.ClosedPorts {
+			fmt.Fprintf(w, "  - %s\t%s\n", address(p.Host, p.Port, p.Protocol), strings.TrimSpace(p.Service+" "+p.Product+" "+p.Version))
+		}
+	}
+	if len(r.ChangedServices) > 0 {
+		fmt.Fprintf(w, "\n🔄 服务变化（%d）：\n", len(r.ChangedServices))
+		for _, c := range r.ChangedServices {
+			fmt.Fprintf(w, "  ~ %s\n      旧: %s\n      新: %s\n", address(c.Host, c.Port, c.Protocol), c.Old, c.New)
+		}
+	}
+	if len(r.ChangedBanners) > 0 {
+		fmt.Fprintf(w, "\n📝 banner变化（%d）：\n", len(r.ChangedBanners))
+		for _, c := range r.ChangedBanners {
+			fmt.Fprintf(w, "  ~ %s\n      旧: %s\n      新: %s\n", address(c.Host, c.Port, c.Protocol), c.Old, c.New)
+		}
+	}
+	if len(r.NewFindings) > 0 {
+		fmt.Fprintf(w, "\n⚠️ 新发现的问题（%d）：\n", len(r.NewFindings))
+		for _, f := range r.NewFindings {
+			fmt.Fprintf(w, "  + [%s] %s %s: %s\n", f.Severity, address(f.Host, f.Port, f.Protocol), f.Name(), f.Details)
+		}
+	}
+	if len(r.ResolvedFindings) > 0 {
+		fmt.Fprintf(w, "\n✅ 已修复的问题（%d）：\n", len(r.ResolvedFindings))
+		for _, f := range r.ResolvedFindings {
+			fmt.Fprintf(w, "  - [%s] %s %s: %s\n", f.Severity, address(f.Host, f.Port, f.Protocol), f.Name(), f.Details)
+		}
+	}
+}
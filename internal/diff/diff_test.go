@@ -0,0 +1,296 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+
+	"netscanner/internal/reporter"
+)
+
+// testReport 按主机分组构造扫描报告，total 为探测过的端口总数
+func testReport(total int, ports string, results ...reporter.ScanResult) reporter.ScanReport {
+	report := reporter.ScanReport{Target: "test", ScanType: "tcp", Ports: ports, TotalPorts: total}
+	for _, res := range results {
+		if res.Protocol == "" {
+			res.Protocol = "tcp"
+		}
+		if len(report.Hosts) == 0 || report.Hosts[len(report.Hosts)-1].Host != res.Host {
+			report.Hosts = append(report.Hosts, reporter.HostResult{Host: res.Host})
+		}
+		h := &report.Hosts[len(report.Hosts)-1]
+		h.Results = append(h.Results, res)
+	}
+	return report
+}
+
+// open 构造开放端口
+func open(host string, port int, service, product, version, banner string, findings ...reporter.Finding) reporter.ScanResult {
+	return reporter.ScanResult{
+		Host: host, Port: port, State: "open",
+		Service: service, Product: product, Version: version, Banner: banner,
+		Findings: findings,
+	}
+}
+
+// closed 构造关闭端口
+func closed(host string, port int) reporter.ScanResult {
+	return reporter.ScanResult{Host: host, Port: port, State: "closed"}
+}
+
+func portAddrs(ports []PortChange) []string {
+	addrs := []string{}
+	for _, p := range ports {
+		addrs = append(addrs, address(p.Host, p.Port, p.Protocol))
+	}
+	return addrs
+}
+
+func findingNames(findings []FindingChange) []string {
+	names := []string{}
+	for _, f := range findings {
+		names = append(names, address(f.Host, f.Port, f.Protocol)+" "+f.Name())
+	}
+	return names
+}
+
+func TestCompare(t *testing.T) {
+	weakPassword := reporter.Finding{Plugin: "redis-unauth", ID: "redis-unauth", Vulnerable: true, Severity: "critical"}
+	oldTLS := reporter.Finding{Plugin: "tls-audit", ID: "tls-weak-protocol", Vulnerable: true, Severity: "medium"}
+	info := reporter.Finding{Plugin: "http-headers", ID: "http-server-header", Severity: "info"}
+
+	tests := []struct {
+		name     string
+		old, new reporter.ScanReport
+		newPorts []string
+		closed   []string
+		notScan  []string
+		services []string
+		banners  []string
+		newFind  []string
+		resolved []string
+	}{
+		{
+			name: "没有变化",
+			old:  testReport(2, "22,80", open("10.0.0.1", 22, "ssh", "OpenSSH", "9.6p1", "SSH-2.0-OpenSSH_9.6p1"), closed("10.0.0.1", 80)),
+			new:  testReport(2, "22,80", open("10.0.0.1", 22, "ssh", "OpenSSH", "9.6p1", "SSH-2.0-OpenSSH_9.6p1"), closed("10.0.0.1", 80)),
+		},
+		{
+			name:     "新开放和已关闭",
+			old:      testReport(2, "22,80", open("10.0.0.1", 22, "ssh", "", "", ""), closed("10.0.0.1", 80)),
+			new:      testReport(2, "22,80", closed("10.0.0.1", 22), open("10.0.0.1", 80, "http", "", "", "")),
+			newPorts: []string{"10.0.0.1:80/tcp"},
+			closed:   []string{"10.0.0.1:22/tcp"},
+		},
+		{
+			name:    "新扫描没有探测的端口不算关闭",
+			old:     testReport(3, "22,80,443", open("10.0.0.1", 22, "ssh", "", "", ""), open("10.0.0.1", 443, "https", "", "", ""), closed("10.0.0.1", 80)),
+			new:     testReport(2, "22,80", open("10.0.0.1", 22, "ssh", "", "", ""), closed("10.0.0.1", 80)),
+			notScan: []string{"10.0.0.1:443/tcp"},
+		},
+		{
+			name:     "服务版本变化",
+			old:      testReport(1, "22", open("10.0.0.1", 22, "ssh", "OpenSSH", "8.9p1", "SSH-2.0-OpenSSH_8.9p1")),
+			new:      testReport(1, "22", open("10.0.0.1", 22, "ssh", "OpenSSH", "9.6p1", "SSH-2.0-OpenSSH_9.6p1")),
+			services: []string{"10.0.0.1:22/tcp"},
+		},
+		{
+			name:    "服务相同但banner变化",
+			old:     testReport(1, "21", open("10.0.0.1", 21, "ftp", "", "", "220 ProFTPD Server ready")),
+			new:     testReport(1, "21", open("10.0.0.1", 21, "ftp", "", "", "220 vsFTPd ready")),
+			banners: []string{"10.0.0.1:21/tcp"},
+		},
+		{
+			name: "banner中只有时间戳和随机串变化",
+			old:  testReport(1, "25", open("10.0.0.1", 25, "smtp", "Postfix", "", "220 mx ESMTP Postfix Wed, 01 May 2024 12:34:56 +0000 id 1714566896")),
+			new:  testReport(1, "25", open("10.0.0.1", 25, "smtp", "Postfix", "", "220 mx ESMTP Postfix Thu, 02 May 2024 08:00:01 +0000 id 1714636801")),
+		},
+		{
+			name: "新扫描没有读到banner",
+			old:  testReport(1, "21", open("10.0.0.1", 21, "ftp", "", "", "220 ProFTPD Server ready")),
+			new:  testReport(1, "21", open("10.0.0.1", 21, "ftp", "", "", "")),
+		},
+		{
+			name:     "新发现和已修复的问题",
+			old:      testReport(2, "443,6379", open("10.0.0.1", 443, "https", "", "", "", oldTLS, info), open("10.0.0.1", 6379, "redis", "", "", "")),
+			new:      testReport(2, "443,6379", open("10.0.0.1", 443, "https", "", "", ""), open("10.0.0.1", 6379, "redis", "", "", "", weakPassword, info)),
+			newFind:  []string{"10.0.0.1:6379/tcp redis-unauth/redis-unauth"},
+			resolved: []string{"10.0.0.1:443/tcp tls-audit/tls-weak-protocol"},
+		},
+		{
+			name:     "按主机和端口排序",
+			old:      testReport(4, "22,80", closed("10.0.0.1", 22), closed("10.0.0.1", 80), closed("10.0.0.2", 22), closed("10.0.0.2", 80)),
+			new:      testReport(4, "22,80", open("10.0.0.2", 80, "http", "", "", ""), open("10.0.0.2", 22, "ssh", "", "", ""), open("10.0.0.1", 80, "http", "", "", ""), closed("10.0.0.1", 22)),
+			newPorts: []string{"10.0.0.1:80/tcp", "10.0.0.2:22/tcp", "10.0.0.2:80/tcp"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Compare(tt.old, tt.new)
+
+			check := func(what string, got, want []string) {
+				t.Helper()
+				if want == nil {
+					want = []string{}
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("%s = %v，期望 %v", what, got, want)
+				}
+			}
+			check("NewPorts", portAddrs(r.NewPorts), tt.newPorts)
+			check("ClosedPorts", portAddrs(r.ClosedPorts), tt.closed)
+			check("NotScanned", portAddrs(r.NotScanned), tt.notScan)
+			var services, banners []string
+			for _, c := range r.ChangedServices {
+				services = append(services, address(c.Host, c.Port, c.Protocol))
+			}
+			for _, c := range r.ChangedBanners {
+				banners = append(banners, address(c.Host, c.Port, c.Protocol))
+			}
+			check("ChangedServices", services, tt.services)
+			check("ChangedBanners", banners, tt.banners)
+			check("NewFindings", findingNames(r.NewFindings), tt.newFind)
+			check("ResolvedFindings", findingNames(r.ResolvedFindings), tt.resolved)
+
+			// 未探测的端口不算差异
+			wantEmpty := len(tt.newPorts)+len(tt.closed)+len(tt.services)+len(tt.banners)+len(tt.newFind)+len(tt.resolved) == 0
+			if r.Empty() != wantEmpty {
+				t.Errorf("Empty() = %v，期望 %v", r.Empty(), wantEmpty)
+			}
+		})
+	}
+}
+
+func TestScanCoverage(t *testing.T) {
+	alive := []reporter.HostDiscovery{{Host: "10.0.0.1", Alive: true}, {Host: "10.0.0.2"}}
+
+	tests := []struct {
+		name   string
+		report reporter.ScanReport
+		want   map[portKey]bool
+	}{
+		{
+			name:   "报告列出了所有端口",
+			report: testReport(2, "", open("10.0.0.1", 22, "ssh", "", "", ""), closed("10.0.0.1", 80)),
+			want: map[portKey]bool{
+				{"10.0.0.1", 22, "tcp"}:  true,
+				{"10.0.0.1", 80, "tcp"}:  true,
+				{"10.0.0.1", 443, "tcp"}: false,
+				{"10.0.0.1", 80, "udp"}:  false,
+				{"10.0.0.2", 22, "tcp"}:  false,
+			},
+		},
+		{
+			name:   "只有开放端口时按端口范围推断",
+			report: testReport(1000, "1-1000", open("10.0.0.1", 22, "ssh", "", "", "")),
+			want: map[portKey]bool{
+				{"10.0.0.1", 22, "tcp"}:   true,
+				{"10.0.0.1", 80, "tcp"}:   true,
+				{"10.0.0.1", 8080, "tcp"}: false,
+				{"10.0.0.9", 80, "tcp"}:   true, // 没有主机发现结果，无法排除主机
+			},
+		},
+		{
+			name: "按主机发现结果排除不存活的主机",
+			report: func() reporter.ScanReport {
+				r := testReport(2000, "1-1000", open("10.0.0.1", 22, "ssh", "", "", ""), open("10.0.0.3", 80, "http", "", "", ""))
+				r.Discovery = alive
+				return r
+			}(),
+			want: map[portKey]bool{
+				{"10.0.0.1", 80, "tcp"}:   true,
+				{"10.0.0.1", 8080, "tcp"}: false,
+				{"10.0.0.2", 80, "tcp"}:   false, // 判定不存活
+				{"10.0.0.3", 443, "tcp"}:  true,  // 有开放端口，视为存活
+				{"10.0.0.4", 80, "tcp"}:   false, // 不在发现结果中
+			},
+		},
+		{
+			name:   "旧版本报告没有端口范围",
+			report: testReport(500, "", open("10.0.0.1", 22, "ssh", "", "", "")),
+			want: map[portKey]bool{
+				{"10.0.0.1", 22, "tcp"}:    true,
+				{"10.0.0.1", 65000, "tcp"}: true, // 无法确定时视为探测过
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := scanCoverage(tt.report)
+			for key, want := range tt.want {
+				if got := c.covers(key); got != want {
+					t.Errorf("covers(%s) = %v，期望 %v", address(key.host, key.port, key.protocol), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeBanner(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   string
+		same   bool
+		banner string // a 归一化后的结果，空表示不检查
+	}{
+		{
+			name:   "版本号保留",
+			a:      "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5",
+			b:      "SSH-2.0-OpenSSH_9.7p1 Ubuntu-3ubuntu13.5",
+			banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5",
+		},
+		{
+			name:   "日期和时刻",
+			a:      "220 mx ESMTP Wed, 01 May 2024 12:34:56 +0000",
+			b:      "220 mx ESMTP Thu, 02 May 2024 08:00:01 +0000",
+			same:   true,
+			banner: "<n> mx ESMTP <date> <time> +<n>",
+		},
+		{
+			name: "ISO时间",
+			a:    "* OK Dovecot ready. 2024-05-01T10:00:00Z",
+			b:    "* OK Dovecot ready. 2025-01-31T23:59:59.123Z",
+			same: true,
+		},
+		{
+			name:   "APOP挑战值",
+			a:      "+OK POP3 ready <1896.697170952@dbc.mtview.ca.us>",
+			b:      "+OK POP3 ready <2004.715420183@dbc.mtview.ca.us>",
+			same:   true,
+			banner: "+OK POP3 ready <<n>@dbc.mtview.ca.us>",
+		},
+		{
+			name:   "随机串",
+			a:      "5.7.44-log a1b2c3d4e5f6a7b8c9d0",
+			b:      "5.7.44-log 9f8e7d6c5b4a39281706",
+			same:   true,
+			banner: "5.7.44-log <nonce>",
+		},
+		{
+			name:   "不含数字的长单词不是随机串",
+			a:      "Server: Microsoft-HTTPAPI/2.0 NOTIFICATIONSERVICE",
+			banner: "Server: Microsoft-HTTPAPI/2.0 NOTIFICATIONSERVICE",
+		},
+		{
+			name: "产品不同",
+			a:    "220 ProFTPD Server ready pid 1234",
+			b:    "220 Pure-FTPd ready pid 1234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeBanner(tt.a)
+			if tt.banner != "" && got != tt.banner {
+				t.Errorf("normalizeBanner(%q) = %q，期望 %q", tt.a, got, tt.banner)
+			}
+			if tt.b == "" {
+				return
+			}
+			if same := sameBanner(tt.a, tt.b); same != tt.same {
+				t.Errorf("sameBanner(%q, %q) = %v，期望 %v（%q / %q）", tt.a, tt.b, same, tt.same, got, normalizeBanner(tt.b))
+			}
+		})
+	}
+}
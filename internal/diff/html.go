@@ -0,0 +1,248 @@
+package diff
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+
+	"netscanner/internal/reporter"
+)
+
+// htmlTemplate 对比报告模板，样式与扫描报告一致
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>扫描对比报告 - {{.New.Target}}</title>
+    <style>
+{{styleSheet}}
+        .scan-results + .scan-results {
+            margin-top: 20px;
+        }
+
+        .old-value {
+            color: #c0392b;
+            text-decoration: line-through;
+        }
+
+        .new-value {
+            color: #27ae60;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>🔄 扫描对比报告</h1>
+            <p class="timestamp">
+                旧扫描: <span class="highlight">{{.Old.Target}}</span> {{.Old.StartTime.Format "2006-01-02 15:04:05"}}（{{.Old.OpenPorts}} 个开放端口） →
+                新扫描: <span class="highlight">{{.New.Target}}</span> {{.New.StartTime.Format "2006-01-02 15:04:05"}}（{{.New.OpenPorts}} 个开放端口）
+            </p>
+            {{if or .Old.Incomplete .New.Incomplete}}
+            <p class="incomplete">⚠️ 至少有一次扫描被中断，差异可能不准确</p>
+            {{end}}
+            {{if .ParamsDiffer}}
+            <p class="incomplete">⚠️ 两次扫描的参数不同（{{.Old.ScanType}} {{.Old.Ports}} → {{.New.ScanType}} {{.New.Ports}}），只有新扫描也探测过的端口才计入已关闭</p>
+            {{end}}
+        </div>
+
+        <div class="summary-cards">
+            <div class="card open">
+                <h3>新开放端口</h3>
+                <div class="number">{{len .NewPorts}}</div>
+            </div>
+            <div class="card closed">
+                <h3>已关闭端口</h3>
+                <div class="number">{{len .ClosedPorts}}</div>
+            </div>
+            <div class="card filtered">
+                <h3>服务变化</h3>
+                <div class="number">{{len .ChangedServices}}</div>
+            </div>
+            <div class="card filtered">
+                <h3>banner变化</h3>
+                <div class="number">{{len .ChangedBanners}}</div>
+            </div>
+            <div class="card findings">
+                <h3>新发现问题</h3>
+                <div class="number">{{len .NewFindings}}</div>
+            </div>
+            <div class="card total">
+                <h3>已修复问题</h3>
+                <div class="number">{{len .ResolvedFindings}}</div>
+            </div>
+        </div>
+
+        {{if .Empty}}
+        <div class="scan-results">
+            <p style="text-align: center; color: #7f8c8d; padding: 40px;">
+                两次扫描没有差异
+            </p>
+        </div>
+        {{end}}
+
+        {{if .NewPorts}}
+        <div class="scan-results">
+            <h2>🆕 新开放端口</h2>
+            {{template "ports" .NewPorts}}
+        </div>
+        {{end}}
+
+        {{if .ClosedPorts}}
+        <div class="scan-results">
+            <h2>🔒 已关闭端口</h2>
+            {{template "ports" .ClosedPorts}}
+        </div>
+        {{end}}
+
+        {{if .NotScanned}}
+        <div class="scan-results">
+            <h2>❔ 新扫描未探测的端口</h2>
+            <p>这些端口在旧扫描中开放，新扫描没有探测，不计入已关闭</p>
+            {{template "ports" .NotScanned}}
+        </div>
+        {{end}}
+
+        {{if .ChangedServices}}
+        <div class="scan-results">
+            <h2>🔄 服务变化</h2>
+            <table class="sortable">
+                <thead>
+                    <tr>
+                        <th>端口</th>
+                        <th>服务</th>
+                        <th>产品/版本</th>
+                        <th>Banner信息</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .ChangedServices}}
+                    <tr>
+                        <td data-sort="{{.Host}}:{{printf "%05d" .Port}}"><strong>{{.Host}}:{{.Port}}</strong>/{{.Protocol}}</td>
+                        <td>{{template "change" (pair .Old.Service .New.Service)}}</td>
+                        <td>{{template "change" (pair (print .Old.Product " " .Old.Version) (print .New.Product " " .New.Version))}}</td>
+                        <td>{{template "change" (pair .Old.Banner .New.Banner)}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .ChangedBanners}}
+        <div class="scan-results">
+            <h2>📝 banner变化</h2>
+            <p>服务识别结果相同，但去掉数字、时间戳和随机串后banner仍不同</p>
+            <table class="sortable">
+                <thead>
+                    <tr>
+                        <th>端口</th>
+                        <th>Banner信息</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .ChangedBanners}}
+                    <tr>
+                        <td data-sort="{{.Host}}:{{printf "%05d" .Port}}"><strong>{{.Host}}:{{.Port}}</strong>/{{.Protocol}}</td>
+                        <td>{{template "change" (pair .Old .New)}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .NewFindings}}
+        <div class="scan-results findings">
+            <h2>⚠️ 新发现的问题</h2>
+            {{template "findings" .NewFindings}}
+        </div>
+        {{end}}
+
+        {{if .ResolvedFindings}}
+        <div class="scan-results findings">
+            <h2>✅ 已修复的问题</h2>
+            {{template "findings" .ResolvedFindings}}
+        </div>
+        {{end}}
+
+        <div class="footer">
+            <p>报告由 <strong>NetSecScanner</strong> 生成</p>
+            <p>仅供安全测试和教育目的使用</p>
+        </div>
+    </div>
+    <script>
+{{sortScript}}
+    </script>
+</body>
+</html>
+
+{{define "ports"}}
+<table class="sortable">
+    <thead>
+        <tr>
+            <th>端口</th>
+            <th>服务</th>
+            <th>产品/版本</th>
+        </tr>
+    </thead>
+    <tbody>
+        {{range .}}
+        <tr>
+            <td data-sort="{{.Host}}:{{printf "%05d" .Port}}"><strong>{{.Host}}:{{.Port}}</strong>/{{.Protocol}}</td>
+            <td>{{.Service}}</td>
+            <td>{{.Product}} {{.Version}}</td>
+        </tr>
+        {{end}}
+    </tbody>
+</table>
+{{end}}
+
+{{define "findings"}}
+<table class="sortable">
+    <thead>
+        <tr>
+            <th>端口</th>
+            <th>插件</th>
+            <th>风险等级</th>
+            <th>详情</th>
+        </tr>
+    </thead>
+    <tbody>
+        {{range .}}
+        <tr>
+            <td data-sort="{{.Host}}:{{printf "%05d" .Port}}"><strong>{{.Host}}:{{.Port}}</strong>/{{.Protocol}}</td>
+            <td><code>{{.Name}}</code></td>
+            <td data-sort="{{severityRank .Severity}}"><span class="badge severity-{{.Severity}}">{{severityLabel .Severity}}</span></td>
+            <td>{{.Details}}</td>
+        </tr>
+        {{end}}
+    </tbody>
+</table>
+{{end}}
+
+{{define "change"}}
+{{if eq (index . 0) (index . 1)}}{{index . 1}}{{else}}<span class="old-value">{{index . 0}}</span><br><span class="new-value">{{index . 1}}</span>{{end}}
+{{end}}`
+
+// GenerateHTML 生成HTML格式的对比报告
+func GenerateHTML(r *Report, outputFile string) error {
+	tmpl, err := template.New("diff").Funcs(reporter.TemplateFuncs()).Funcs(template.FuncMap{
+		"pair": func(a, b string) []string { return []string{a, b} },
+	}).Parse(htmlTemplate)
+	if err != nil {
+		return fmt.Errorf("解析模板失败: %v", err)
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, r); err != nil {
+		return fmt.Errorf("生成报告失败: %v", err)
+	}
+	return nil
+}
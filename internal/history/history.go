@@ -0,0 +1,320 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"netscanner/internal/reporter"
+)
+
+// 数据库中的 bucket
+// scans 保存完整的扫描记录；ports 和 findings 是按端口、插件组织的索引，
+// 键以扫描ID结尾，同一端口或问题的各次记录按时间顺序相邻
+var (
+	scansBucket    = []byte("scans")
+	portsBucket    = []byte("ports")
+	findingsBucket = []byte("findings")
+)
+
+// Params 扫描参数
+type Params struct {
+	Target   string   `json:"target"`
+	Hosts    []string `json:"hosts"`
+	Ports    string   `json:"ports"`
+	Mode     string   `json:"mode"`
+	ScanType string   `json:"scan_type"`
+	Timing   string   `json:"timing,omitempty"`
+	Source   string   `json:"source,omitempty"` // 从 nmap XML 导入时为源文件
+}
+
+// Record 一次扫描的完整记录，结果使用JSON报告的结构保存
+type Record struct {
+	ID     uint64              `json:"id"`
+	Params Params              `json:"params"`
+	Report reporter.JSONReport `json:"report"`
+}
+
+// Summary 扫描记录的概要
+type Summary struct {
+	ID         uint64
+	Target     string
+	StartTime  time.Time
+	Duration   time.Duration
+	Mode       string
+	ScanType   string
+	Hosts      int
+	OpenPorts  int
+	Findings   int // 存在风险的检测结果数
+	Incomplete bool
+}
+
+// PortSighting 某个端口在一次扫描中的状态
+type PortSighting struct {
+	ScanID   uint64    `json:"scan_id"`
+	Time     time.Time `json:"time"`
+	State    string    `json:"state"`
+	Service  string    `json:"service,omitempty"`
+	Product  string    `json:"product,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Findings int       `json:"findings"`
+}
+
+// FindingSighting 某个插件在一次扫描中的检测结果，插件返回多个问题时每个问题一条
+type FindingSighting struct {
+	ScanID     uint64    `json:"scan_id"`
+	Time       time.Time `json:"time"`
+	ID         string    `json:"id,omitempty"` // 插件给出的问题标识
+	Vulnerable bool      `json:"vulnerable"`
+	Severity   string    `json:"severity,omitempty"`
+	Details    string    `json:"details"`
+}
+
+// Store 扫描历史库，使用 bbolt 单文件存储
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开历史库，文件不存在时创建
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开历史库失败: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{scansBucket, portsBucket, findingsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化历史库失败: %v", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close 关闭历史库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save 保存一次扫描，返回扫描ID
+// 端口索引只记录开放的端口，关闭的端口不占用空间
+func (s *Store) Save(params Params, report reporter.ScanReport) (uint64, error) {
+	var id uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		scans := tx.Bucket(scansBucket)
+		seq, err := scans.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = seq
+
+		record := Record{ID: id, Params: params, Report: reporter.ToJSON(report)}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := scans.Put(idKey(id), data); err != nil {
+			return err
+		}
+
+		ports := tx.Bucket(portsBucket)
+		findings := tx.Bucket(findingsBucket)
+		for _, h := range report.Hosts {
+			for _, r := range h.Results {
+				if r.State != "open" && r.State != "open|filtered" {
+					continue
+				}
+
+				sighting := PortSighting{
+					ScanID:  id,
+					Time:    report.StartTime,
+					State:   r.State,
+					Service: r.Service,
+					Product: r.Product,
+					Version: r.Version,
+				}
+				for i, f := range r.Findings {
+					if f.Vulnerable {
+						sighting.Findings++
+					}
+					fs := FindingSighting{
+						ScanID:     id,
+						Time:       report.StartTime,
+						ID:         f.ID,
+						Vulnerable: f.Vulnerable,
+						Severity:   f.Severity,
+						Details:    f.Details,
+					}
+					if err := putJSON(findings, findingKey(r.Host, r.Port, r.Protocol, f.Plugin, id, i), fs); err != nil {
+						return err
+					}
+				}
+				if err := putJSON(ports, portKey(r.Host, r.Port, r.Protocol, id), sighting); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("保存扫描记录失败: %v", err)
+	}
+	return id, nil
+}
+
+// List 按时间顺序列出所有扫描
+func (s *Store) List() ([]Summary, error) {
+	var summaries []Summary
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(scansBucket).ForEach(func(k, v []byte) error {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			summaries = append(summaries, summarize(record))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取历史库失败: %v", err)
+	}
+	return summaries, nil
+}
+
+// Get 读取一次扫描的完整记录
+func (s *Store) Get(id uint64) (*Record, error) {
+	var record *Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(scansBucket).Get(idKey(id))
+		if data == nil {
+			return nil
+		}
+		record = &Record{}
+		return json.Unmarshal(data, record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取历史库失败: %v", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("扫描记录不存在: %d", id)
+	}
+	return record, nil
+}
+
+// PortHistory 返回端口在各次扫描中开放的记录，按时间顺序，第一条即端口首次出现的扫描
+func (s *Store) PortHistory(host string, port int, protocol string) ([]PortSighting, error) {
+	var sightings []PortSighting
+	err := s.scanPrefix(portsBucket, portPrefix(host, port, protocol), func(v []byte) error {
+		var ps PortSighting
+		if err := json.Unmarshal(v, &ps); err != nil {
+			return err
+		}
+		sightings = append(sightings, ps)
+		return nil
+	})
+	return sightings, err
+}
+
+// FindingHistory 返回插件在该端口上各次检测的结果，按时间顺序
+func (s *Store) FindingHistory(host string, port int, protocol, plugin string) ([]FindingSighting, error) {
+	var sightings []FindingSighting
+	err := s.scanPrefix(findingsBucket, findingPrefix(host, port, protocol, plugin), func(v []byte) error {
+		var fs FindingSighting
+		if err := json.Unmarshal(v, &fs); err != nil {
+			return err
+		}
+		sightings = append(sightings, fs)
+		return nil
+	})
+	return sightings, err
+}
+
+// scanPrefix 遍历以 prefix 开头的键
+func (s *Store) scanPrefix(bucket, prefix []byte, fn func(v []byte) error) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("读取历史库失败: %v", err)
+	}
+	return nil
+}
+
+// summarize 提取扫描概要
+func summarize(record Record) Summary {
+	doc := record.Report
+	summary := Summary{
+		ID:         record.ID,
+		Target:     doc.Scan.Target,
+		StartTime:  doc.Scan.StartTime,
+		Duration:   time.Duration(doc.Scan.DurationMs) * time.Millisecond,
+		Mode:       doc.Scan.Mode,
+		ScanType:   doc.Scan.ScanType,
+		Hosts:      doc.Summary.Hosts,
+		OpenPorts:  doc.Summary.Open,
+		Incomplete: doc.Scan.Incomplete,
+	}
+	for _, h := range doc.Hosts {
+		for _, p := range h.Ports {
+			for _, f := range p.Findings {
+				if f.Vulnerable {
+					summary.Findings++
+				}
+			}
+		}
+	}
+	return summary
+}
+
+// putJSON 以JSON保存值
+func putJSON(b *bolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// idKey 扫描ID的键，大端序保证按ID顺序遍历
+func idKey(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+// portPrefix 端口索引键前缀：host \x00 protocol \x00 port \x00
+func portPrefix(host string, port int, protocol string) []byte {
+	return []byte(host + "\x00" + protocol + "\x00" + strconv.Itoa(port) + "\x00")
+}
+
+// portKey 端口索引键
+func portKey(host string, port int, protocol string, id uint64) []byte {
+	return append(portPrefix(host, port, protocol), idKey(id)...)
+}
+
+// findingPrefix 检测结果索引键前缀
+func findingPrefix(host string, port int, protocol, plugin string) []byte {
+	return append(portPrefix(host, port, protocol), []byte(plugin+"\x00")...)
+}
+
+// findingKey 检测结果索引键，扫描ID之后是结果在端口中的序号，按前缀遍历时仍按时间顺序；
+// 不用问题标识区分，插件返回多个没有标识的问题时也不会互相覆盖
+func findingKey(host string, port int, protocol, plugin string, id uint64, index int) []byte {
+	return binary.BigEndian.AppendUint32(append(findingPrefix(host, port, protocol, plugin), idKey(id)...), uint32(index))
+}
@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eolRelease 一个版本系列的停止支持日期
+type eolRelease struct {
+	series string // 如 8.0、10.6、14
+	date   string // 官方停止支持（含扩展支持）的日期
+}
+
+// endOfLife 各产品的版本系列，按版本从旧到新排列，来自各项目公布的支持策略。
+// 比表中最早系列还旧的版本视为已停止支持；夹在两个系列之间但不在表中的系列，
+// 视为与前一个系列同时停止支持；比表中最新系列还新的版本视为仍受支持
+var endOfLife = map[string][]eolRelease{
+	"MySQL": {
+		{"5.5", "2018-12-31"},
+		{"5.6", "2021-02-28"},
+		{"5.7", "2023-10-31"},
+		{"8.0", "2026-04-30"},
+		{"8.1", "2023-10-25"},
+		{"8.2", "2024-01-16"},
+		{"8.3", "2024-04-30"},
+		{"8.4", "2032-04-30"},
+		{"9.0", "2024-10-15"},
+		{"9.1", "2025-01-21"},
+		{"9.2", "2025-04-15"},
+		{"9.3", "2025-07-22"},
+		{"9.4", "2025-10-21"},
+		{"9.5", "2026-01-20"},
+	},
+	"MariaDB": {
+		{"5.5", "2020-04-11"},
+		{"10.0", "2019-03-31"},
+		{"10.1", "2020-10-17"},
+		{"10.2", "2022-05-23"},
+		{"10.3", "2023-05-25"},
+		{"10.4", "2024-06-18"},
+		{"10.5", "2025-06-24"},
+		{"10.6", "2026-07-06"},
+		{"10.7", "2023-02-09"},
+		{"10.8", "2023-05-20"},
+		{"10.9", "2023-08-22"},
+		{"10.10", "2023-11-17"},
+		{"10.11", "2028-02-16"},
+		{"11.0", "2024-06-06"},
+		{"11.1", "2024-08-21"},
+		{"11.2", "2024-11-21"},
+		{"11.3", "2024-05-29"},
+		{"11.4", "2029-05-29"},
+		{"11.5", "2024-11-13"},
+		{"11.6", "2025-02-13"},
+		{"11.8", "2028-06-04"},
+	},
+	"PostgreSQL": {
+		{"9.6", "2021-11-11"},
+		{"10", "2022-11-10"},
+		{"11", "2023-11-09"},
+		{"12", "2024-11-21"},
+		{"13", "2025-11-13"},
+		{"14", "2026-11-12"},
+		{"15", "2027-11-11"},
+		{"16", "2028-11-09"},
+		{"17", "2029-11-08"},
+		{"18", "2030-11-14"},
+	},
+	"MongoDB": {
+		{"3.6", "2021-04-30"},
+		{"4.0", "2022-04-30"},
+		{"4.2", "2023-04-30"},
+		{"4.4", "2024-02-29"},
+		{"5.0", "2024-10-31"},
+		{"6.0", "2025-07-31"},
+		{"7.0", "2027-08-31"},
+		{"8.0", "2029-10-31"},
+	},
+}
+
+// endOfLifeDate 返回版本所属系列的停止支持日期，无法判断时返回 false
+func endOfLifeDate(product, version string) (time.Time, bool) {
+	releases := endOfLife[product]
+	parts := versionParts(version)
+	if len(releases) == 0 || len(parts) == 0 {
+		return time.Time{}, false
+	}
+
+	for _, r := range releases {
+		if series := versionParts(r.series); hasVersionPrefix(parts, series) {
+			date, err := time.Parse("2006-01-02", r.date)
+			return date, err == nil
+		}
+	}
+	// 比最早的系列还旧
+	oldest := releases[0]
+	if compareVersions(parts, versionParts(oldest.series)) < 0 {
+		date, err := time.Parse("2006-01-02", oldest.date)
+		return date, err == nil
+	}
+	// 比最新的系列还新时无法判断；夹在两个系列之间时按前一个系列的日期处理
+	if compareVersions(parts, versionParts(releases[len(releases)-1].series)) > 0 {
+		return time.Time{}, false
+	}
+	for i := len(releases) - 2; i >= 0; i-- {
+		if compareVersions(parts, versionParts(releases[i].series)) > 0 {
+			date, err := time.Parse("2006-01-02", releases[i].date)
+			return date, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// eolFinding 版本已停止支持时返回问题，id 如 mysql-eol-version
+func eolFinding(id, product, version string, now time.Time) (Finding, bool) {
+	date, ok := endOfLifeDate(product, version)
+	if !ok || now.Before(date) {
+		return Finding{}, false
+	}
+	return Finding{
+		ID:          id,
+		Title:       fmt.Sprintf("%s %s 已于 %s 停止支持", product, version, date.Format("2006-01-02")),
+		Severity:    "medium",
+		CVSS:        5.3,
+		Evidence:    product + " " + version,
+		Remediation: fmt.Sprintf("升级到仍受支持的 %s 版本，停止支持的版本不再发布安全更新", product),
+	}, true
+}
+
+// versionParts 解析版本号开头的数字部分，如 10.6.12-MariaDB 返回 [10 6 12]
+func versionParts(version string) []int {
+	var parts []int
+	for _, field := range strings.Split(version, ".") {
+		end := 0
+		for end < len(field) && field[end] >= '0' && field[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(field[:end])
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+		if end < len(field) {
+			break
+		}
+	}
+	return parts
+}
+
+// hasVersionPrefix 版本是否属于该系列
+func hasVersionPrefix(parts, series []int) bool {
+	if len(parts) < len(series) {
+		return false
+	}
+	for i := range series {
+		if parts[i] != series[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// compareVersions 逐段比较版本号
+func compareVersions(a, b []int) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
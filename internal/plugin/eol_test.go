@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndOfLifeTable(t *testing.T) {
+	for product, releases := range endOfLife {
+		for i, r := range releases {
+			if _, err := time.Parse("2006-01-02", r.date); err != nil {
+				t.Errorf("%s %s 的日期无效: %v", product, r.series, err)
+			}
+			if i > 0 && compareVersions(versionParts(releases[i-1].series), versionParts(r.series)) >= 0 {
+				t.Errorf("%s 的系列没有按版本排列: %s 在 %s 之后", product, r.series, releases[i-1].series)
+			}
+		}
+	}
+}
+
+func TestEndOfLifeDate(t *testing.T) {
+	tests := []struct {
+		product string
+		version string
+		want    string // 空表示无法判断
+	}{
+		{product: "MySQL", version: "8.0.36", want: "2026-04-30"},
+		{product: "MySQL", version: "8.4.3", want: "2032-04-30"},
+		{product: "MySQL", version: "9.3.0", want: "2025-07-22"},
+		{product: "MySQL", version: "9.5.0", want: "2026-01-20"},
+		{product: "MySQL", version: "5.1.73", want: "2018-12-31"}, // 比最早的系列还旧
+		{product: "MySQL", version: "99.0.1"},                     // 比最新的系列还新
+		{product: "MariaDB", version: "10.6.12", want: "2026-07-06"},
+		{product: "MariaDB", version: "10.7.8", want: "2023-02-09"},
+		{product: "MariaDB", version: "10.10.7", want: "2023-11-17"},
+		{product: "MariaDB", version: "10.11.6", want: "2028-02-16"},
+		{product: "MariaDB", version: "11.2.2", want: "2024-11-21"},
+		{product: "MariaDB", version: "11.3.2", want: "2024-05-29"},
+		{product: "MariaDB", version: "11.6.2", want: "2025-02-13"},
+		{product: "MariaDB", version: "11.7.2", want: "2025-02-13"}, // 不在表中，按前一个系列处理
+		{product: "MariaDB", version: "12.0.1"},
+		{product: "PostgreSQL", version: "9.4", want: "2021-11-11"},
+		{product: "PostgreSQL", version: "16.2", want: "2028-11-09"},
+		{product: "MongoDB", version: "4.1.13", want: "2022-04-30"}, // 开发版本夹在两个系列之间
+		{product: "MongoDB", version: "7.0.5", want: "2027-08-31"},
+		{product: "MySQL", version: "未知版本"},
+		{product: "MySQL", version: ""},
+		{product: "Oracle", version: "19.3"},
+	}
+
+	for _, tt := range tests {
+		date, ok := endOfLifeDate(tt.product, tt.version)
+		got := ""
+		if ok {
+			got = date.Format("2006-01-02")
+		}
+		if got != tt.want {
+			t.Errorf("endOfLifeDate(%s, %q) = %q，期望 %q", tt.product, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestEOLFinding(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	f, ok := eolFinding("mysql-eol-version", "MariaDB", "10.9.8", now)
+	if !ok || f.ID != "mysql-eol-version" || f.Title != "MariaDB 10.9.8 已于 2023-08-22 停止支持" {
+		t.Errorf("eolFinding = %+v, %v", f, ok)
+	}
+	if _, ok := eolFinding("mysql-eol-version", "MySQL", "8.4.3", now); ok {
+		t.Error("仍受支持的版本返回了问题")
+	}
+	if _, ok := eolFinding("postgresql-eol-version", "PostgreSQL", "14.10", time.Date(2026, 11, 12, 0, 0, 0, 0, time.UTC)); !ok {
+		t.Error("停止支持当天没有返回问题")
+	}
+}
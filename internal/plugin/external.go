@@ -0,0 +1,268 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExternalProtocolVersion 外部插件协议版本
+//
+// 每次调用启动一个新进程，向 stdin 写入一行JSON请求，从 stdout 读取一个JSON响应：
+//
+//	→ {"method":"describe","protocol":1}
+//	← {"name":"x","description":"...","services":["http"],"ports":[80],"category":"safe","author":"...","references":["..."]}
+//	→ {"method":"scan","protocol":1,"target":"10.0.0.1","port":80,"timeout_ms":2000}
+//	← {"vulnerable":true,"details":"...","severity":"high","evidence":"...","remediation":"..."}
+//
+// 发现多个问题时在 scan 响应中返回 "findings" 列表，每项包含 id、title、severity、
+// cvss、evidence、remediation，此时 vulnerable 和 severity 可以省略
+//
+// 响应中带 "error" 时视为扫描失败。进程超时会被结束，崩溃或输出无效JSON时
+// 返回的错误中包含 stderr 的最后几行，不影响扫描器本身
+const ExternalProtocolVersion = 1
+
+// 外部插件进程的限制
+const (
+	describeTimeout  = 10 * time.Second
+	minScanTimeout   = 10 * time.Second
+	maxExternalOut   = 1 << 20
+	maxExternalError = 64 << 10
+	stderrTailLines  = 5
+)
+
+// externalRequest 发送给外部插件的请求
+type externalRequest struct {
+	Method    string `json:"method"` // describe 或 scan
+	Protocol  int    `json:"protocol"`
+	Target    string `json:"target,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`
+}
+
+// externalDescription describe 的响应
+type externalDescription struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Services    []string `json:"services"`
+	Ports       []int    `json:"ports"`
+	Category    string   `json:"category"`
+	Author      string   `json:"author"`
+	References  []string `json:"references"`
+	Error       string   `json:"error"`
+}
+
+// externalResult scan 的响应
+type externalResult struct {
+	Result
+	Error string `json:"error"`
+}
+
+// ExternalPlugin 通过 stdin/stdout JSON 协议调用的外部可执行文件插件
+type ExternalPlugin struct {
+	Path        string // 可执行文件
+	name        string
+	description string
+	meta        Metadata
+}
+
+// NewExternalPlugin 执行 describe 握手并创建插件
+func NewExternalPlugin(ctx context.Context, path string) (*ExternalPlugin, error) {
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+
+	var desc externalDescription
+	if err := runExternal(ctx, path, externalRequest{Method: "describe", Protocol: ExternalProtocolVersion}, &desc); err != nil {
+		return nil, fmt.Errorf("外部插件 %s 握手失败: %v", path, err)
+	}
+	if desc.Error != "" {
+		return nil, fmt.Errorf("外部插件 %s 握手失败: %s", path, desc.Error)
+	}
+	if desc.Name == "" || strings.ContainsAny(desc.Name, " \t\r\n") {
+		return nil, fmt.Errorf("外部插件 %s 的名称为空或包含空白字符", path)
+	}
+	category, err := validCategory(desc.Category)
+	if err != nil {
+		return nil, fmt.Errorf("外部插件 %s: %v", path, err)
+	}
+
+	return &ExternalPlugin{
+		Path:        path,
+		name:        desc.Name,
+		description: desc.Description,
+		meta: Metadata{
+			Services:   desc.Services,
+			Ports:      desc.Ports,
+			Category:   category,
+			Author:     desc.Author,
+			References: desc.References,
+		},
+	}, nil
+}
+
+// LoadExternalPlugins 加载目录（含子目录）下的可执行文件，模板和脚本除外
+// 单个插件握手失败时跳过该插件，返回其余插件和所有错误
+func LoadExternalPlugins(ctx context.Context, dir string) ([]*ExternalPlugin, error) {
+	var plugins []*ExternalPlugin
+	var errs []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isTemplateFile(path) || isScriptFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			return nil
+		}
+		p, err := NewExternalPlugin(ctx, path)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		plugins = append(plugins, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取插件目录失败: %v", err)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// Name 插件名称
+func (p *ExternalPlugin) Name() string {
+	return p.name
+}
+
+// Description 插件描述
+func (p *ExternalPlugin) Description() string {
+	if p.description == "" {
+		return "外部插件: " + filepath.Base(p.Path)
+	}
+	return p.description
+}
+
+// Metadata 插件元数据
+func (p *ExternalPlugin) Metadata() Metadata {
+	return p.meta
+}
+
+// Scan 执行扫描
+func (p *ExternalPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 启动插件进程执行一次扫描
+// 插件可能建立多个连接，进程最长运行 timeout 的 10 倍（至少 10 秒）
+func (p *ExternalPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, max(10*timeout, minScanTimeout))
+	defer cancel()
+
+	req := externalRequest{
+		Method:    "scan",
+		Protocol:  ExternalProtocolVersion,
+		Target:    target,
+		Port:      port,
+		TimeoutMs: timeout.Milliseconds(),
+	}
+	var reply externalResult
+	if err := runExternal(ctx, p.Path, req, &reply); err != nil {
+		return Result{Vulnerable: false}, fmt.Errorf("外部插件 %s 执行失败: %v", p.name, err)
+	}
+	if reply.Error != "" {
+		return Result{Vulnerable: false}, fmt.Errorf("外部插件 %s: %s", p.name, reply.Error)
+	}
+	return reply.Result, nil
+}
+
+// runExternal 启动进程，写入请求并解析响应
+func runExternal(ctx context.Context, path string, req externalRequest, reply any) error {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	stderr := &tailBuffer{limit: maxExternalError}
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = &limitedWriter{w: &stdout, n: maxExternalOut}
+	cmd.Stderr = stderr
+	// 插件的子进程继承了输出管道时，不无限等待管道关闭
+	cmd.WaitDelay = time.Second
+
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return withOutput(fmt.Errorf("超时或被取消: %v", ctx.Err()), "stderr", stderr)
+	}
+	if runErr != nil {
+		return withOutput(runErr, "stderr", stderr)
+	}
+
+	decoder := json.NewDecoder(&stdout)
+	if err := decoder.Decode(reply); err != nil {
+		return withOutput(fmt.Errorf("响应不是有效的JSON: %v", err), "stderr", stderr)
+	}
+	return nil
+}
+
+// withOutput 在错误中附加插件输出（stderr 或脚本的 print）的最后几行
+func withOutput(err error, label string, output *tailBuffer) error {
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return err
+	}
+	if len(lines) > stderrTailLines {
+		lines = lines[len(lines)-stderrTailLines:]
+	}
+	return fmt.Errorf("%v（%s: %s）", err, label, strings.Join(lines, " | "))
+}
+
+// tailBuffer 只保留最后 limit 字节的缓冲区
+type tailBuffer struct {
+	buf   []byte
+	limit int
+}
+
+// Write 写入数据，超出上限时丢弃最早的数据
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+// String 返回缓冲区内容
+func (b *tailBuffer) String() string {
+	return strings.ToValidUTF8(string(b.buf), "")
+}
+
+// limitedWriter 超出上限后丢弃数据的 Writer，避免失控的插件耗尽内存
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+// Write 写入数据
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n <= 0 {
+		return len(p), nil
+	}
+	if len(p) > l.n {
+		l.w.Write(p[:l.n])
+		l.n = 0
+		return len(p), nil
+	}
+	l.n -= len(p)
+	return l.w.Write(p)
+}
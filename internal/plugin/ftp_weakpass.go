@@ -1,8 +1,10 @@
 package plugin
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,16 +22,35 @@ func (p *FTPWeakPassPlugin) Description() string {
 	return "检测FTP服务的弱口令"
 }
 
+// Metadata 插件元数据
+func (p *FTPWeakPassPlugin) Metadata() Metadata {
+	return Metadata{
+		Services: []string{"ftp"},
+		Ports:    []int{21},
+		Category: CategoryBruteforce,
+	}
+}
+
 // Scan 执行扫描
 func (p *FTPWeakPassPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
-	address := fmt.Sprintf("%s:%d", target, port)
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+// ScanContext 执行可取消的扫描
+func (p *FTPWeakPassPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
 		return Result{Vulnerable: false}, err
 	}
 	defer conn.Close()
 
+	// 取消时关闭连接，打断进行中的登录尝试
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
 	// 读取banner
 	conn.SetReadDeadline(time.Now().Add(timeout))
 	buffer := make([]byte, 1024)
@@ -59,11 +80,29 @@ func (p *FTPWeakPassPlugin) Scan(target string, port int, timeout time.Duration)
 	}
 
 	for _, cred := range weakPasswords {
-		if p.testFTPLogin(conn, cred.username, cred.password, timeout) {
+		if ctx.Err() != nil {
+			return Result{Vulnerable: false}, ctx.Err()
+		}
+		if ok, response := p.testFTPLogin(conn, cred.username, cred.password, timeout); ok {
+			finding := Finding{
+				ID:          "ftp-weak-password",
+				Title:       fmt.Sprintf("发现弱口令: %s/%s", cred.username, cred.password),
+				Severity:    "high",
+				CVSS:        7.5,
+				Evidence:    fmt.Sprintf("USER %s / PASS %s → %s", cred.username, cred.password, strings.TrimSpace(response)),
+				Remediation: "修改该账户的口令为强口令，并限制FTP的访问来源",
+			}
+			if cred.username == "anonymous" {
+				finding.ID = "ftp-anonymous-login"
+				finding.Severity = "medium"
+				finding.CVSS = 5.3
+				finding.Remediation = "关闭匿名登录（如 vsftpd 设置 anonymous_enable=NO），或确认匿名目录中没有敏感文件"
+			}
 			return Result{
-				Vulnerable: true,
-				Details:    fmt.Sprintf("发现弱口令: %s/%s", cred.username, cred.password),
-				Severity:   "medium",
+				Details:     finding.Title,
+				Evidence:    finding.Evidence,
+				Remediation: finding.Remediation,
+				Findings:    []Finding{finding},
 			}, nil
 		}
 	}
@@ -71,8 +110,8 @@ func (p *FTPWeakPassPlugin) Scan(target string, port int, timeout time.Duration)
 	return Result{Vulnerable: false, Details: "未发现常见弱口令"}, nil
 }
 
-// testFTPLogin 测试FTP登录
-func (p *FTPWeakPassPlugin) testFTPLogin(conn net.Conn, username, password string, timeout time.Duration) bool {
+// testFTPLogin 测试FTP登录，成功时返回服务器的登录响应
+func (p *FTPWeakPassPlugin) testFTPLogin(conn net.Conn, username, password string, timeout time.Duration) (bool, string) {
 	// 发送用户名
 	conn.SetWriteDeadline(time.Now().Add(timeout))
 	conn.Write([]byte(fmt.Sprintf("USER %s\r\n", username)))
@@ -82,7 +121,7 @@ func (p *FTPWeakPassPlugin) testFTPLogin(conn net.Conn, username, password strin
 	buffer := make([]byte, 1024)
 	n, err := conn.Read(buffer)
 	if err != nil || !strings.HasPrefix(string(buffer[:n]), "331") {
-		return false
+		return false, ""
 	}
 
 	// 发送密码
@@ -93,9 +132,9 @@ func (p *FTPWeakPassPlugin) testFTPLogin(conn net.Conn, username, password strin
 	conn.SetReadDeadline(time.Now().Add(timeout))
 	n, err = conn.Read(buffer)
 	if err != nil {
-		return false
+		return false, ""
 	}
 
 	response := string(buffer[:n])
-	return strings.HasPrefix(response, "230")
+	return strings.HasPrefix(response, "230"), response
 }
@@ -1,14 +1,39 @@
 package plugin
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // HTTPSecurityPlugin HTTP安全检测插件
-type HTTPSecurityPlugin struct{}
+// 通过 TLS 握手判断端口使用 http 还是 https，逐跳跟随同一主机内的重定向并记录重定向链，
+// 检查 HTTP 到 HTTPS 的跳转，并对首个响应和最终响应检查安全头
+type HTTPSecurityPlugin struct {
+	// InsecureSkipVerify 访问 HTTPS 时不验证证书，用于自签名或内网证书
+	InsecureSkipVerify bool
+}
+
+// httpMaxRedirects 最多跟随的重定向次数
+const httpMaxRedirects = 10
+
+// httpHop 重定向链中的一次请求
+type httpHop struct {
+	url      *url.URL
+	status   int
+	line     string // 状态行，如 HTTP/1.1 301 Moved Permanently
+	header   http.Header
+	location *url.URL // 重定向目标，不是重定向时为 nil
+}
 
 // Name 插件名称
 func (p *HTTPSecurityPlugin) Name() string {
@@ -17,67 +42,323 @@ func (p *HTTPSecurityPlugin) Name() string {
 
 // Description 插件描述
 func (p *HTTPSecurityPlugin) Description() string {
-	return "检测HTTP服务的安全头信息"
+	return "检测HTTP服务的安全头信息和HTTPS跳转"
+}
+
+// Metadata 插件元数据
+func (p *HTTPSecurityPlugin) Metadata() Metadata {
+	return Metadata{
+		Services:   []string{"http", "https", "https-alt"},
+		Ports:      []int{80, 443, 8443},
+		Category:   CategorySafe,
+		References: []string{"https://owasp.org/www-project-secure-headers/"},
+	}
 }
 
 // Scan 执行扫描
 func (p *HTTPSecurityPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
-	url := fmt.Sprintf("http://%s:%d", target, port)
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *HTTPSecurityPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	host := net.JoinHostPort(target, strconv.Itoa(port))
+	scheme, err := detectHTTPScheme(ctx, host, timeout)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
 
 	client := &http.Client{
 		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: p.InsecureSkipVerify},
+		},
+		// 重定向由 followRedirects 逐跳处理
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	// 扫描结束后关闭保持的连接，否则每个目标都会留下空闲连接
+	defer client.CloseIdleConnections()
+
+	hops, hopErr := followRedirects(ctx, client, &url.URL{Scheme: scheme, Host: host, Path: "/"})
+	if len(hops) == 0 {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(hopErr, &certErr) {
+			return Result{Vulnerable: false}, fmt.Errorf("HTTPS证书验证失败: %v（可开启跳过证书验证的选项）", certErr.Err)
+		}
+		return Result{Vulnerable: false}, hopErr
+	}
+	first, final := hops[0], hops[len(hops)-1]
+
+	findings := redirectFindings(hops, hopErr)
+
+	// 检查安全头，有重定向时首个响应和最终响应都要检查
+	checked := []*httpHop{first}
+	labels := []string{"首个响应"}
+	if len(hops) > 1 {
+		checked = append(checked, final)
+		labels = append(labels, "最终响应")
+	}
+	headerFindings, recommendations := checkSecurityHeaders(checked, labels)
+	findings = append(findings, headerFindings...)
+
+	// 证据：重定向链中各响应的状态行，以及检查过的响应实际返回的安全头
+	var evidence []string
+	for i, hop := range checked {
+		if len(checked) > 1 {
+			evidence = append(evidence, fmt.Sprintf("[%s] %s", labels[i], hop.url))
+		}
+		evidence = append(evidence, hop.line)
+		for _, header := range securityHeaderNames() {
+			if value := hop.header.Get(header); value != "" {
+				evidence = append(evidence, header+": "+value)
+			}
+		}
 	}
 
-	resp, err := client.Get(url)
+	result := Result{
+		Evidence:    strings.Join(evidence, "\n"),
+		Remediation: strings.Join(recommendations, "\n"),
+		Findings:    findings,
+	}
+	risky := false
+	for _, f := range findings {
+		risky = risky || f.Severity != "info"
+	}
+	if !risky {
+		result.Details = "基本安全头已正确配置"
+	}
+	return result, nil
+}
+
+// detectHTTPScheme 尝试 TLS 握手判断端口使用的协议，握手成功为 https，否则为 http
+// 这里只判断协议，不验证证书
+func detectHTTPScheme(ctx context.Context, host string, timeout time.Duration) (string, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
 	if err != nil {
-		return Result{Vulnerable: false}, err
+		return "", err
+	}
+	defer conn.Close()
+
+	hsCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.HandshakeContext(hsCtx); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "http", nil
 	}
-	defer resp.Body.Close()
+	return "https", nil
+}
 
-	// 检查安全头
-	missingHeaders := []string{}
-	recommendations := []string{}
+// followRedirects 从 start 开始逐跳请求，只跟随同一主机内的重定向
+// 返回已完成的请求；中途失败时同时返回错误，超过 httpMaxRedirects 时最后一跳仍带有 location
+func followRedirects(ctx context.Context, client *http.Client, start *url.URL) ([]*httpHop, error) {
+	var hops []*httpHop
+	next := start
+	for range httpMaxRedirects + 1 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next.String(), nil)
+		if err != nil {
+			return hops, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return hops, err
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
 
-	securityHeaders := map[string]string{
-		"X-Content-Type-Options":    "nosniff",
-		"X-Frame-Options":           "DENY",
-		"X-XSS-Protection":          "1; mode=block",
-		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		hop := &httpHop{url: next, status: resp.StatusCode, line: resp.Proto + " " + resp.Status, header: resp.Header}
+		hops = append(hops, hop)
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+			return hops, nil
+		}
+		location, err := resp.Location()
+		if err != nil {
+			return hops, nil
+		}
+		hop.location = location
+		if location.Hostname() != start.Hostname() {
+			// 跳到其他主机时只记录，不向扫描范围以外的主机发请求
+			return hops, nil
+		}
+		next = location
 	}
+	return hops, nil
+}
 
-	for header, expectedValue := range securityHeaders {
-		value := resp.Header.Get(header)
-		if value == "" {
-			missingHeaders = append(missingHeaders, header)
-			recommendations = append(recommendations,
-				fmt.Sprintf("建议添加 %s 头，期望值: %s", header, expectedValue))
-		} else if header == "X-Frame-Options" &&
-			strings.ToUpper(value) != "DENY" &&
-			!strings.Contains(strings.ToUpper(value), "SAMEORIGIN") {
-			recommendations = append(recommendations,
-				fmt.Sprintf("%s 头配置不安全: %s (期望: %s)", header, value, expectedValue))
+// redirectFindings 根据重定向链检查 HTTP 到 HTTPS 的跳转
+func redirectFindings(hops []*httpHop, hopErr error) []Finding {
+	var findings []Finding
+	first, final := hops[0], hops[len(hops)-1]
+
+	var chain []string
+	for _, hop := range hops {
+		if hop.location != nil {
+			chain = append(chain, fmt.Sprintf("%d %s → %s", hop.status, hop.url, hop.location))
+		} else {
+			chain = append(chain, fmt.Sprintf("%d %s", hop.status, hop.url))
 		}
-		// 注意：这里我们使用了 expectedValue 变量，修复了编译错误
+	}
+	if hopErr != nil {
+		chain = append(chain, "请求失败: "+hopErr.Error())
+	}
+	if len(chain) > 1 {
+		title := fmt.Sprintf("重定向 %d 次，最终到达 %s", len(hops)-1, final.url)
+		switch {
+		case hopErr != nil:
+			title = fmt.Sprintf("重定向 %d 次，请求 %s 失败", len(hops), final.location)
+		case final.location != nil:
+			title = fmt.Sprintf("重定向 %d 次，最终指向 %s", len(hops), final.location)
+		}
+		findings = append(findings, Finding{
+			ID:       "http-redirect-chain",
+			Title:    title,
+			Severity: "info",
+			Evidence: strings.Join(chain, "\n"),
+		})
 	}
 
-	if len(missingHeaders) > 0 {
-		return Result{
-			Vulnerable: true,
-			Details:    fmt.Sprintf("缺少安全头: %v。建议: %v", missingHeaders, recommendations),
-			Severity:   "low",
-		}, nil
+	// 请求没有失败但最后一跳仍是重定向：跳到其他主机或重定向次数过多
+	if final.location != nil && hopErr == nil {
+		if final.location.Hostname() != first.url.Hostname() {
+			findings = append(findings, Finding{
+				ID:       "http-redirect-offsite",
+				Title:    "重定向到其他主机 " + final.location.Host + "，未继续跟随",
+				Severity: "info",
+				Evidence: chain[len(chain)-1],
+			})
+		} else {
+			findings = append(findings, Finding{
+				ID:          "http-redirect-loop",
+				Title:       fmt.Sprintf("重定向超过 %d 次", httpMaxRedirects),
+				Severity:    "low",
+				Evidence:    chain[len(chain)-1],
+				Remediation: "检查重定向规则，避免循环重定向",
+			})
+		}
 	}
 
-	if len(recommendations) > 0 {
-		return Result{
-			Vulnerable: true,
-			Details:    fmt.Sprintf("安全头配置需要改进: %v", recommendations),
-			Severity:   "low",
-		}, nil
+	// HTTPS 页面跳回 HTTP
+	for _, hop := range hops {
+		if hop.url.Scheme == "https" && hop.location != nil && hop.location.Scheme == "http" {
+			findings = append(findings, Finding{
+				ID:          "http-redirect-downgrade",
+				Title:       "HTTPS 重定向到 HTTP",
+				Severity:    "medium",
+				Evidence:    fmt.Sprintf("%d %s → %s", hop.status, hop.url, hop.location),
+				Remediation: "重定向目标使用 https，避免把已加密的连接降级为明文",
+			})
+			break
+		}
 	}
 
-	return Result{
-		Vulnerable: false,
-		Details:    "基本安全头已正确配置",
-	}, nil
+	if first.url.Scheme != "http" {
+		return findings
+	}
+	// 明文 HTTP：应当跳转到 HTTPS，并使用永久重定向
+	for _, hop := range hops {
+		if hop.location == nil || hop.location.Scheme != "https" {
+			continue
+		}
+		if hop.status != http.StatusMovedPermanently && hop.status != http.StatusPermanentRedirect {
+			findings = append(findings, Finding{
+				ID:          "http-redirect-temporary",
+				Title:       fmt.Sprintf("HTTP 到 HTTPS 使用临时重定向 %d", hop.status),
+				Severity:    "low",
+				Evidence:    fmt.Sprintf("%d %s → %s", hop.status, hop.url, hop.location),
+				Remediation: "使用 301 或 308 永久重定向，并在 HTTPS 响应中设置 Strict-Transport-Security",
+			})
+		}
+		return findings
+	}
+	findings = append(findings, Finding{
+		ID:          "http-no-https-redirect",
+		Title:       "HTTP 未重定向到 HTTPS",
+		Severity:    "low",
+		Evidence:    strings.Join(chain, "\n"),
+		Remediation: "把明文 HTTP 请求用 301 重定向到 HTTPS，避免凭据和会话在网络中明文传输",
+	})
+	return findings
+}
+
+// securityHeaders 检查的安全头和期望值
+var securityHeaders = map[string]string{
+	"X-Content-Type-Options":    "nosniff",
+	"X-Frame-Options":           "DENY",
+	"X-XSS-Protection":          "1; mode=block",
+	"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+}
+
+// securityHeaderNames 按固定顺序返回安全头名称，保证输出稳定
+func securityHeaderNames() []string {
+	headerNames := make([]string, 0, len(securityHeaders))
+	for header := range securityHeaders {
+		headerNames = append(headerNames, header)
+	}
+	sort.Strings(headerNames)
+	return headerNames
+}
+
+// checkSecurityHeaders 检查各响应的安全头，同一个头在多个响应中缺失时合并为一个问题
+// Strict-Transport-Security 只对 HTTPS 响应有效，明文响应不检查；
+// 重定向响应没有页面内容，只检查 Strict-Transport-Security
+func checkSecurityHeaders(hops []*httpHop, labels []string) ([]Finding, []string) {
+	var findings []Finding
+	var recommendations []string
+
+	for _, header := range securityHeaderNames() {
+		expectedValue := securityHeaders[header]
+		var missing, insecure, evidence []string
+		for i, hop := range hops {
+			if header == "Strict-Transport-Security" && hop.url.Scheme != "https" {
+				continue
+			}
+			if header != "Strict-Transport-Security" && hop.location != nil {
+				continue
+			}
+			value := hop.header.Get(header)
+			if value == "" {
+				missing = append(missing, labels[i])
+				evidence = append(evidence, hop.url.String())
+			} else if header == "X-Frame-Options" &&
+				strings.ToUpper(value) != "DENY" &&
+				!strings.Contains(strings.ToUpper(value), "SAMEORIGIN") {
+				insecure = append(insecure, labels[i])
+				evidence = append(evidence, fmt.Sprintf("%s: %s (%s)", header, value, hop.url))
+			}
+		}
+
+		where := func(names []string) string {
+			if len(hops) == 1 {
+				return ""
+			}
+			return "（" + strings.Join(names, "、") + "）"
+		}
+		if len(missing) > 0 {
+			recommendations = append(recommendations,
+				fmt.Sprintf("建议添加 %s 头，期望值: %s", header, expectedValue))
+			findings = append(findings, Finding{
+				ID:          "http-missing-" + strings.ToLower(header),
+				Title:       "缺少安全头 " + header + where(missing),
+				Severity:    "low",
+				Evidence:    strings.Join(evidence, "\n"),
+				Remediation: recommendations[len(recommendations)-1],
+			})
+		} else if len(insecure) > 0 {
+			recommendations = append(recommendations,
+				fmt.Sprintf("%s 头配置不安全 (期望: %s)", header, expectedValue))
+			findings = append(findings, Finding{
+				ID:          "http-insecure-x-frame-options",
+				Title:       "X-Frame-Options 头配置不安全" + where(insecure),
+				Severity:    "low",
+				Evidence:    strings.Join(evidence, "\n"),
+				Remediation: recommendations[len(recommendations)-1],
+			})
+		}
+	}
+	return findings, recommendations
 }
@@ -0,0 +1,392 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MongoDBPlugin MongoDB未授权访问检测插件
+// 用 MongoDB 线协议发送 isMaster/hello、buildInfo 和 listDatabases，
+// listDatabases 无需认证即成功时说明没有开启访问控制
+type MongoDBPlugin struct{}
+
+// MongoDB 线协议常量
+const (
+	mongoOpReply      = 1
+	mongoOpQuery      = 2004
+	mongoOpMsg        = 2013
+	mongoMaxMessage   = 16 << 20
+	mongoMaxDepth     = 100 // 与服务器的 BSON 嵌套层数限制一致
+	mongoOpMsgWire    = 6   // 3.6 起支持 OP_MSG
+	mongoUnauthorized = 13  // 错误码 Unauthorized
+)
+
+// bsonElem 有序的 BSON 元素，命令名必须是文档的第一个键
+type bsonElem struct {
+	Key   string
+	Value any
+}
+
+// Name 插件名称
+func (p *MongoDBPlugin) Name() string {
+	return "mongodb-unauth"
+}
+
+// Description 插件描述
+func (p *MongoDBPlugin) Description() string {
+	return "检测MongoDB未授权访问并列出数据库"
+}
+
+// Metadata 插件元数据
+func (p *MongoDBPlugin) Metadata() Metadata {
+	return Metadata{
+		Services: []string{"mongodb"},
+		Ports:    []int{27017},
+		Category: CategorySafe,
+		References: []string{
+			"https://www.mongodb.com/docs/manual/administration/security-checklist/",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *MongoDBPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *MongoDBPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	dialer := net.Dialer{Timeout: timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	defer raw.Close()
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+	defer stop()
+	conn := &mongoConn{Conn: raw, timeout: timeout}
+
+	// isMaster 使用 OP_QUERY，所有版本都支持，并由 maxWireVersion 决定后续命令的格式
+	hello, err := conn.query([]bsonElem{{"isMaster", int32(1)}})
+	if err != nil {
+		return Result{Vulnerable: false}, fmt.Errorf("不是MongoDB服务: %v", err)
+	}
+	if wire, _ := bsonInt(hello["maxWireVersion"]); wire >= mongoOpMsgWire {
+		conn.opMsg = true
+	}
+
+	version := "未知版本"
+	if info, err := conn.command("buildInfo"); err == nil {
+		if v, ok := info["version"].(string); ok {
+			version = v
+		}
+	}
+	title := "MongoDB " + version
+	switch {
+	case hello["setName"] != nil:
+		title += fmt.Sprintf("，副本集 %v", hello["setName"])
+	case hello["msg"] == "isdbgrid":
+		title += "，mongos 路由"
+	}
+	findings := []Finding{{ID: "mongodb-version", Title: title, Severity: "info", Evidence: fmt.Sprintf("maxWireVersion=%v", hello["maxWireVersion"])}}
+	if eol, ok := eolFinding("mongodb-eol-version", "MongoDB", version, time.Now()); ok {
+		findings = append(findings, eol)
+	}
+
+	list, err := conn.command("listDatabases")
+	if err != nil {
+		if strings.Contains(err.Error(), "Unauthorized") || strings.Contains(err.Error(), "requires authentication") {
+			findings = append(findings, Finding{ID: "mongodb-auth-required", Title: "MongoDB 需要认证", Severity: "info", Evidence: err.Error()})
+			return Result{Details: "MongoDB 已开启访问控制", Findings: findings}, nil
+		}
+		return Result{Vulnerable: false}, err
+	}
+
+	var names []string
+	var lines []string
+	if dbs, ok := list["databases"].([]any); ok {
+		for _, item := range dbs {
+			db, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			name, _ := db["name"].(string)
+			size, _ := bsonInt(db["sizeOnDisk"])
+			names = append(names, name)
+			lines = append(lines, fmt.Sprintf("%s（%d 字节）", name, size))
+		}
+	}
+	findings = append(findings,
+		Finding{
+			ID:          "mongodb-unauthenticated",
+			Title:       fmt.Sprintf("MongoDB 无需认证即可列出 %d 个数据库", len(names)),
+			Severity:    "critical",
+			CVSS:        9.8,
+			Evidence:    "listDatabases → ok: " + strings.Join(names, ", "),
+			Remediation: "开启访问控制（security.authorization: enabled）并创建管理员账户，用 net.bindIp 和防火墙限制访问来源",
+		},
+		Finding{
+			ID:       "mongodb-databases",
+			Title:    "数据库: " + joinLimited(names),
+			Severity: "info",
+			Evidence: strings.Join(lines, "\n"),
+		},
+	)
+	return Result{Findings: findings}, nil
+}
+
+// mongoConn MongoDB 连接
+type mongoConn struct {
+	net.Conn
+	timeout   time.Duration
+	opMsg     bool
+	requestID int32
+}
+
+// command 在 admin 库上执行命令，服务器返回 ok: 0 时以 errmsg 作为错误
+func (c *mongoConn) command(name string) (map[string]any, error) {
+	doc := []bsonElem{{name, int32(1)}}
+	var reply map[string]any
+	var err error
+	if c.opMsg {
+		reply, err = c.msg(append(doc, bsonElem{"$db", "admin"}))
+	} else {
+		reply, err = c.query(doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := bsonFloat(reply["ok"]); ok != 1 {
+		code, _ := bsonInt(reply["code"])
+		msg, _ := reply["errmsg"].(string)
+		if code == mongoUnauthorized {
+			return nil, fmt.Errorf("%s 失败: Unauthorized: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s 失败: %s", name, msg)
+	}
+	return reply, nil
+}
+
+// query 以 OP_QUERY 在 admin.$cmd 上执行命令
+func (c *mongoConn) query(doc []bsonElem) (map[string]any, error) {
+	var body bytes.Buffer
+	binary.Write(&body, binary.LittleEndian, int32(0)) // flags
+	body.WriteString("admin.$cmd\x00")
+	binary.Write(&body, binary.LittleEndian, int32(0))  // numberToSkip
+	binary.Write(&body, binary.LittleEndian, int32(-1)) // numberToReturn
+	body.Write(encodeBSON(doc))
+
+	opCode, reply, err := c.roundTrip(mongoOpQuery, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	// OP_REPLY：responseFlags、cursorID、startingFrom、numberReturned、文档
+	if opCode != mongoOpReply || len(reply) < 20 {
+		return nil, fmt.Errorf("无法识别的回复（opCode %d）", opCode)
+	}
+	doc2, _, err := decodeBSON(reply[20:], 0)
+	return doc2, err
+}
+
+// msg 以 OP_MSG 执行命令
+func (c *mongoConn) msg(doc []bsonElem) (map[string]any, error) {
+	body := append(make([]byte, 5), encodeBSON(doc)...) // flagBits 和 kind 0 的段
+	opCode, reply, err := c.roundTrip(mongoOpMsg, body)
+	if err != nil {
+		return nil, err
+	}
+	if opCode != mongoOpMsg || len(reply) < 5 || reply[4] != 0 {
+		return nil, fmt.Errorf("无法识别的回复（opCode %d）", opCode)
+	}
+	result, _, err := decodeBSON(reply[5:], 0)
+	return result, err
+}
+
+// roundTrip 发送一条消息并读取回复，返回回复的 opCode 和消息体
+func (c *mongoConn) roundTrip(opCode int32, body []byte) (int32, []byte, error) {
+	c.requestID++
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:], uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(header[4:], uint32(c.requestID))
+	binary.LittleEndian.PutUint32(header[12:], uint32(opCode))
+
+	c.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.Write(append(header, body...)); err != nil {
+		return 0, nil, err
+	}
+	if _, err := io.ReadFull(c, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[0:])
+	if length < 16 || length > mongoMaxMessage {
+		return 0, nil, fmt.Errorf("消息长度无效: %d", length)
+	}
+	reply := make([]byte, length-16)
+	if _, err := io.ReadFull(c, reply); err != nil {
+		return 0, nil, err
+	}
+	return int32(binary.LittleEndian.Uint32(header[12:])), reply, nil
+}
+
+// encodeBSON 编码文档，值只支持 int32、string 和 bool
+func encodeBSON(doc []bsonElem) []byte {
+	var body bytes.Buffer
+	for _, e := range doc {
+		switch v := e.Value.(type) {
+		case int32:
+			body.WriteByte(0x10)
+			body.WriteString(e.Key + "\x00")
+			binary.Write(&body, binary.LittleEndian, v)
+		case string:
+			body.WriteByte(0x02)
+			body.WriteString(e.Key + "\x00")
+			binary.Write(&body, binary.LittleEndian, int32(len(v)+1))
+			body.WriteString(v + "\x00")
+		case bool:
+			body.WriteByte(0x08)
+			body.WriteString(e.Key + "\x00")
+			if v {
+				body.WriteByte(1)
+			} else {
+				body.WriteByte(0)
+			}
+		}
+	}
+	out := binary.LittleEndian.AppendUint32(nil, uint32(body.Len()+5))
+	out = append(out, body.Bytes()...)
+	return append(out, 0)
+}
+
+// decodeBSON 解码文档，返回文档和占用的字节数
+// 数组解码为 []any，嵌套文档为 map[string]any，不关心的类型只跳过。
+// depth 是当前的嵌套层数，超过 mongoMaxDepth 时返回错误，避免深层嵌套耗尽栈空间
+func decodeBSON(data []byte, depth int) (map[string]any, int, error) {
+	if depth > mongoMaxDepth {
+		return nil, 0, fmt.Errorf("BSON 嵌套层数超过 %d", mongoMaxDepth)
+	}
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("BSON 文档不完整")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size < 5 || size > len(data) {
+		return nil, 0, fmt.Errorf("BSON 文档长度无效: %d", size)
+	}
+
+	doc := make(map[string]any)
+	pos := 4
+	for pos < size-1 {
+		kind := data[pos]
+		end := bytes.IndexByte(data[pos+1:size], 0)
+		if end < 0 {
+			return nil, 0, fmt.Errorf("BSON 键名不完整")
+		}
+		key := string(data[pos+1 : pos+1+end])
+		pos += end + 2
+
+		value, n, err := decodeBSONValue(kind, data[pos:size], depth)
+		if err != nil {
+			return nil, 0, fmt.Errorf("BSON 字段 %s: %v", key, err)
+		}
+		doc[key] = value
+		pos += n
+	}
+	return doc, size, nil
+}
+
+// decodeBSONValue 解码一个值，返回值和占用的字节数
+func decodeBSONValue(kind byte, data []byte, depth int) (any, int, error) {
+	fixed := map[byte]int{0x01: 8, 0x07: 12, 0x08: 1, 0x09: 8, 0x0a: 0, 0x10: 4, 0x11: 8, 0x12: 8, 0x13: 16, 0xff: 0, 0x7f: 0}
+	if n, ok := fixed[kind]; ok {
+		if len(data) < n {
+			return nil, 0, fmt.Errorf("数据不完整")
+		}
+		switch kind {
+		case 0x01:
+			return math.Float64frombits(binary.LittleEndian.Uint64(data)), n, nil
+		case 0x08:
+			return data[0] != 0, n, nil
+		case 0x10:
+			return int32(binary.LittleEndian.Uint32(data)), n, nil
+		case 0x12:
+			return int64(binary.LittleEndian.Uint64(data)), n, nil
+		}
+		return nil, n, nil
+	}
+
+	if len(data) < 4 {
+		return nil, 0, fmt.Errorf("数据不完整")
+	}
+	length := int(binary.LittleEndian.Uint32(data))
+	switch kind {
+	case 0x02, 0x0d, 0x0e: // 字符串、JavaScript 代码、符号
+		if length < 1 || 4+length > len(data) {
+			return nil, 0, fmt.Errorf("字符串长度无效")
+		}
+		return string(data[4 : 4+length-1]), 4 + length, nil
+	case 0x03:
+		doc, n, err := decodeBSON(data, depth+1)
+		return doc, n, err
+	case 0x04:
+		doc, n, err := decodeBSON(data, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		// 数组的键是 "0"、"1"……
+		items := make([]any, 0, len(doc))
+		for i := 0; ; i++ {
+			item, ok := doc[strconv.Itoa(i)]
+			if !ok {
+				break
+			}
+			items = append(items, item)
+		}
+		return items, n, nil
+	case 0x05:
+		if length < 0 || 5+length > len(data) {
+			return nil, 0, fmt.Errorf("二进制长度无效")
+		}
+		return nil, 5 + length, nil
+	}
+	return nil, 0, fmt.Errorf("不支持的类型 0x%02x", kind)
+}
+
+// bsonInt 把 BSON 数值转换为整数
+func bsonInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// bsonFloat 把 BSON 数值转换为浮点数，ok 字段在不同版本中可能是 double 或 int32
+func bsonFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,540 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBSON 编码测试用的文档，比 encodeBSON 多支持服务器回复中出现的类型
+func testBSON(doc []bsonElem) []byte {
+	var body []byte
+	for _, e := range doc {
+		var kind byte
+		var value []byte
+		switch v := e.Value.(type) {
+		case float64:
+			kind, value = 0x01, binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+		case string:
+			kind, value = 0x02, append(binary.LittleEndian.AppendUint32(nil, uint32(len(v)+1)), v+"\x00"...)
+		case []bsonElem:
+			kind, value = 0x03, testBSON(v)
+		case []any:
+			items := make([]bsonElem, len(v))
+			for i, item := range v {
+				items[i] = bsonElem{strconv.Itoa(i), item}
+			}
+			kind, value = 0x04, testBSON(items)
+		case bool:
+			kind, value = 0x08, []byte{0}
+			if v {
+				value[0] = 1
+			}
+		case nil:
+			kind = 0x0a
+		case int32:
+			kind, value = 0x10, binary.LittleEndian.AppendUint32(nil, uint32(v))
+		case int64:
+			kind, value = 0x12, binary.LittleEndian.AppendUint64(nil, uint64(v))
+		}
+		body = append(body, kind)
+		body = append(body, e.Key+"\x00"...)
+		body = append(body, value...)
+	}
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(body)+5))
+	out = append(out, body...)
+	return append(out, 0)
+}
+
+// rawBSON 用原始的元素字节构造文档
+func rawBSON(elems ...string) []byte {
+	body := strings.Join(elems, "")
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(body)+5))
+	out = append(out, body...)
+	return append(out, 0)
+}
+
+// nestedBSON 构造嵌套 levels 层子文档的文档
+func nestedBSON(levels int) []byte {
+	// 每层是类型 0x03、空键名和子文档的长度，最内层是空文档，最后补齐各层的结束字节
+	total := 5 + levels*6
+	buf := make([]byte, 0, total)
+	for i := range levels {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(total-i*6))
+		buf = append(buf, 0x03, 0)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, 5)
+	buf = append(buf, 0)
+	return append(buf, make([]byte, levels)...)
+}
+
+func TestEncodeBSON(t *testing.T) {
+	got := encodeBSON([]bsonElem{{"isMaster", int32(1)}})
+	want := []byte("\x13\x00\x00\x00\x10isMaster\x00\x01\x00\x00\x00\x00")
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeBSON = %q，期望 %q", got, want)
+	}
+
+	doc := []bsonElem{{"listDatabases", int32(1)}, {"nameOnly", true}, {"$db", "admin"}, {"off", false}}
+	decoded, n, err := decodeBSON(encodeBSON(doc), 0)
+	if err != nil {
+		t.Fatalf("decodeBSON 返回错误: %v", err)
+	}
+	wantDoc := map[string]any{"listDatabases": int32(1), "nameOnly": true, "$db": "admin", "off": false}
+	if n != len(encodeBSON(doc)) || !reflect.DeepEqual(decoded, wantDoc) {
+		t.Errorf("编码后解码 = %v（%d 字节），期望 %v", decoded, n, wantDoc)
+	}
+	if !bytes.Equal(encodeBSON(doc), testBSON(doc)) {
+		t.Error("encodeBSON 与测试编码器的结果不一致")
+	}
+}
+
+func TestDecodeBSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  map[string]any
+	}{
+		{name: "空文档", input: rawBSON(), want: map[string]any{}},
+		{
+			name: "常用类型",
+			input: testBSON([]bsonElem{
+				{"ok", 1.0}, {"version", "7.0.5"}, {"ismaster", true}, {"maxWireVersion", int32(21)},
+				{"sizeOnDisk", int64(1 << 40)}, {"setName", nil},
+			}),
+			want: map[string]any{
+				"ok": 1.0, "version": "7.0.5", "ismaster": true, "maxWireVersion": int32(21),
+				"sizeOnDisk": int64(1 << 40), "setName": nil,
+			},
+		},
+		{
+			name: "嵌套文档和数组",
+			input: testBSON([]bsonElem{{"databases", []any{
+				[]bsonElem{{"name", "admin"}, {"sizeOnDisk", 40960.0}},
+				[]bsonElem{{"name", "local"}, {"empty", false}},
+			}}, {"totalSize", 81920.0}}),
+			want: map[string]any{
+				"databases": []any{
+					map[string]any{"name": "admin", "sizeOnDisk": 40960.0},
+					map[string]any{"name": "local", "empty": false},
+				},
+				"totalSize": 81920.0,
+			},
+		},
+		{name: "空数组", input: testBSON([]bsonElem{{"a", []any{}}}), want: map[string]any{"a": []any{}}},
+		{name: "空字符串", input: testBSON([]bsonElem{{"s", ""}}), want: map[string]any{"s": ""}},
+		{
+			name: "跳过的定长类型",
+			input: rawBSON(
+				"\x07_id\x00"+strings.Repeat("\x01", 12), // ObjectId
+				"\x09t\x00"+strings.Repeat("\x00", 8),    // UTC 时间
+				"\x11ts\x00"+strings.Repeat("\x00", 8),   // 时间戳
+				"\x13d\x00"+strings.Repeat("\x00", 16),   // Decimal128
+				"\xffmin\x00", "\x7fmax\x00",
+			),
+			want: map[string]any{"_id": nil, "t": nil, "ts": nil, "d": nil, "min": nil, "max": nil},
+		},
+		{
+			name:  "二进制和代码",
+			input: rawBSON("\x05bin\x00\x03\x00\x00\x00\x00abc", "\x0dcode\x00\x04\x00\x00\x00f()\x00"),
+			want:  map[string]any{"bin": nil, "code": "f()"},
+		},
+		{name: "文档后有多余数据", input: append(rawBSON("\x08b\x00\x01"), "trailing"...), want: map[string]any{"b": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n, err := decodeBSON(tt.input, 0)
+			if err != nil {
+				t.Fatalf("decodeBSON 返回错误: %v", err)
+			}
+			if size := int(binary.LittleEndian.Uint32(tt.input)); n != size {
+				t.Errorf("占用 %d 字节，期望 %d", n, size)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeBSON = %#v，期望 %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBSONMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "空数据", input: nil},
+		{name: "不足5字节", input: []byte{5, 0, 0, 0}},
+		{name: "长度小于5", input: []byte{4, 0, 0, 0, 0}},
+		{name: "长度超过数据", input: []byte{6, 0, 0, 0, 0}},
+		{name: "长度溢出", input: []byte{0xff, 0xff, 0xff, 0xff, 0}},
+		{name: "键名没有结束符", input: []byte{8, 0, 0, 0, 0x10, 'a', 'b', 'c'}},
+		{name: "int32不完整", input: rawBSON("\x10n\x00\x01\x00")},
+		{name: "double不完整", input: rawBSON("\x01n\x00\x01\x02\x03")},
+		{name: "字符串长度为0", input: rawBSON("\x02s\x00\x00\x00\x00\x00")},
+		{name: "字符串长度超过文档", input: rawBSON("\x02s\x00\x10\x00\x00\x00ab\x00")},
+		{name: "字符串长度溢出", input: rawBSON("\x02s\x00\xff\xff\xff\xffab\x00")},
+		{name: "字符串长度不完整", input: rawBSON("\x02s\x00\x01")},
+		{name: "二进制长度超过文档", input: rawBSON("\x05b\x00\x10\x00\x00\x00\x00ab")},
+		{name: "二进制长度溢出", input: rawBSON("\x05b\x00\xff\xff\xff\xff\x00ab")},
+		{name: "子文档长度无效", input: rawBSON("\x03d\x00\x02\x00\x00\x00\x00")},
+		{name: "子文档超过外层", input: rawBSON("\x03d\x00\x20\x00\x00\x00\x00")},
+		{name: "数组内容无效", input: rawBSON("\x04a\x00\x08\x00\x00\x00\x10" + "0\x00")},
+		{name: "不支持的类型", input: rawBSON("\x06u\x00")},
+		{name: "未知类型", input: rawBSON("\x42x\x00\x00\x00\x00\x00")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if doc, _, err := decodeBSON(tt.input, 0); err == nil {
+				t.Errorf("decodeBSON(%q) = %v，期望返回错误", tt.input, doc)
+			}
+		})
+	}
+}
+
+func TestDecodeBSONTruncated(t *testing.T) {
+	full := testBSON([]bsonElem{
+		{"ok", 1.0},
+		{"databases", []any{[]bsonElem{{"name", "admin"}, {"sizeOnDisk", int64(4096)}}}},
+		{"errmsg", "none"},
+	})
+	for i := range len(full) {
+		if _, _, err := decodeBSON(full[:i], 0); err == nil {
+			t.Errorf("截断到 %d 字节时没有返回错误", i)
+		}
+	}
+	// 改变长度字段，让文档在元素中间结束
+	for size := 5; size < len(full); size++ {
+		data := slices.Clone(full)
+		binary.LittleEndian.PutUint32(data, uint32(size))
+		decodeBSON(data, 0)
+	}
+}
+
+func TestDecodeBSONDepth(t *testing.T) {
+	if _, _, err := decodeBSON(nestedBSON(mongoMaxDepth), 0); err != nil {
+		t.Errorf("嵌套 %d 层返回错误: %v", mongoMaxDepth, err)
+	}
+	if _, _, err := decodeBSON(nestedBSON(mongoMaxDepth+1), 0); err == nil {
+		t.Errorf("嵌套 %d 层没有返回错误", mongoMaxDepth+1)
+	}
+	// 接近消息长度上限的深层嵌套曾经耗尽栈空间
+	if _, _, err := decodeBSON(nestedBSON(mongoMaxMessage/6-1), 0); err == nil {
+		t.Error("深层嵌套没有返回错误")
+	}
+}
+
+func TestBSONNumbers(t *testing.T) {
+	tests := []struct {
+		value   any
+		integer int64
+		float   float64
+		ok      bool
+	}{
+		{value: int32(-7), integer: -7, float: -7, ok: true},
+		{value: int64(1 << 40), integer: 1 << 40, float: 1 << 40, ok: true},
+		{value: 1.0, integer: 1, float: 1, ok: true},
+		{value: "1", ok: false},
+		{value: nil, ok: false},
+	}
+	for _, tt := range tests {
+		if n, ok := bsonInt(tt.value); n != tt.integer || ok != tt.ok {
+			t.Errorf("bsonInt(%#v) = %d, %v，期望 %d, %v", tt.value, n, ok, tt.integer, tt.ok)
+		}
+		if f, ok := bsonFloat(tt.value); f != tt.float || ok != tt.ok {
+			t.Errorf("bsonFloat(%#v) = %v, %v，期望 %v, %v", tt.value, f, ok, tt.float, tt.ok)
+		}
+	}
+	if f, ok := bsonFloat(true); f != 1 || !ok {
+		t.Errorf("bsonFloat(true) = %v, %v", f, ok)
+	}
+	if _, ok := bsonInt(true); ok {
+		t.Error("bsonInt(true) 不应成功")
+	}
+}
+
+// mongoFrame 构造带消息头的回复
+func mongoFrame(opCode int32, body []byte) []byte {
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:], uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(opCode))
+	return append(header, body...)
+}
+
+// opReplyBody 构造 OP_REPLY 的消息体
+func opReplyBody(doc []byte) []byte {
+	body := make([]byte, 20)
+	binary.LittleEndian.PutUint32(body[16:], 1) // numberReturned
+	return append(body, doc...)
+}
+
+// opMsgBody 构造 OP_MSG 的消息体
+func opMsgBody(doc []byte) []byte {
+	return append(make([]byte, 5), doc...)
+}
+
+func TestMongoFraming(t *testing.T) {
+	okDoc := testBSON([]bsonElem{{"ok", 1.0}})
+
+	tests := []struct {
+		name    string
+		opMsg   bool
+		reply   []byte
+		wantErr bool
+	}{
+		{name: "OP_REPLY", reply: mongoFrame(mongoOpReply, opReplyBody(okDoc))},
+		{name: "OP_MSG", opMsg: true, reply: mongoFrame(mongoOpMsg, opMsgBody(okDoc))},
+		{name: "OP_MSG带校验和", opMsg: true, reply: mongoFrame(mongoOpMsg, append(opMsgBody(okDoc), 1, 2, 3, 4))},
+		{name: "连接被关闭", reply: nil, wantErr: true},
+		{name: "消息头不完整", reply: mongoFrame(mongoOpReply, nil)[:10], wantErr: true},
+		{name: "消息长度小于消息头", reply: []byte{15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0}, wantErr: true},
+		{name: "消息长度超过上限", reply: []byte{0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0}, wantErr: true},
+		{name: "消息体不完整", reply: mongoFrame(mongoOpReply, opReplyBody(okDoc))[:30], wantErr: true},
+		{name: "OP_REPLY过短", reply: mongoFrame(mongoOpReply, make([]byte, 19)), wantErr: true},
+		{name: "OP_QUERY收到OP_MSG", reply: mongoFrame(mongoOpMsg, opMsgBody(okDoc)), wantErr: true},
+		{name: "OP_MSG收到OP_REPLY", opMsg: true, reply: mongoFrame(mongoOpReply, opReplyBody(okDoc)), wantErr: true},
+		{name: "OP_MSG过短", opMsg: true, reply: mongoFrame(mongoOpMsg, []byte{0, 0, 0, 0}), wantErr: true},
+		{name: "OP_MSG第一段不是kind 0", opMsg: true, reply: mongoFrame(mongoOpMsg, append([]byte{0, 0, 0, 0, 1}, okDoc...)), wantErr: true},
+		{name: "文档无效", reply: mongoFrame(mongoOpReply, opReplyBody([]byte{0xff, 0, 0, 0, 0})), wantErr: true},
+		{name: "HTTP响应", reply: []byte("HTTP/1.1 400 Bad Request\r\n\r\n"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer server.Close()
+				header := make([]byte, 16)
+				if _, err := io.ReadFull(server, header); err != nil {
+					return
+				}
+				io.CopyN(io.Discard, server, int64(binary.LittleEndian.Uint32(header))-16)
+				server.Write(tt.reply)
+			}()
+
+			c := &mongoConn{Conn: client, timeout: time.Second, opMsg: tt.opMsg}
+			reply, err := c.command("ping")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %v", reply)
+				}
+				return
+			}
+			if err != nil || reply["ok"] != 1.0 {
+				t.Errorf("command = %v, %v", reply, err)
+			}
+		})
+	}
+}
+
+// fakeMongo 模拟 MongoDB 的命令处理
+type fakeMongo struct {
+	wire      int32
+	version   string
+	hello     []bsonElem // isMaster 回复中的其他字段
+	auth      bool       // listDatabases 需要认证
+	databases []any
+	opCodes   []int32 // 收到的请求类型
+}
+
+// readMongoRequest 读取请求，返回 opCode 和命令名
+func readMongoRequest(conn net.Conn) (int32, string, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, "", err
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header)-16)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, "", err
+	}
+	opCode := int32(binary.LittleEndian.Uint32(header[12:]))
+	var doc []byte
+	switch opCode {
+	case mongoOpQuery:
+		_, rest, _ := bytes.Cut(body[4:], []byte{0}) // 集合名
+		doc = rest[8:]
+	case mongoOpMsg:
+		doc = body[5:]
+	}
+	// 命令名是文档的第一个键
+	name, _, _ := bytes.Cut(doc[5:], []byte{0})
+	return opCode, string(name), nil
+}
+
+func (f *fakeMongo) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		opCode, name, err := readMongoRequest(conn)
+		if err != nil {
+			return
+		}
+		f.opCodes = append(f.opCodes, opCode)
+
+		var doc []bsonElem
+		switch name {
+		case "isMaster":
+			doc = append([]bsonElem{{"ismaster", true}, {"maxWireVersion", f.wire}}, f.hello...)
+			doc = append(doc, bsonElem{"ok", 1.0})
+		case "buildInfo":
+			doc = []bsonElem{{"version", f.version}, {"ok", 1.0}}
+		case "listDatabases":
+			if f.auth {
+				doc = []bsonElem{{"ok", 0.0}, {"errmsg", "command listDatabases requires authentication"}, {"code", int32(mongoUnauthorized)}}
+			} else {
+				doc = []bsonElem{{"databases", f.databases}, {"ok", 1.0}}
+			}
+		default:
+			doc = []bsonElem{{"ok", 0.0}, {"errmsg", "no such command"}}
+		}
+
+		if opCode == mongoOpMsg {
+			conn.Write(mongoFrame(mongoOpMsg, opMsgBody(testBSON(doc))))
+		} else {
+			conn.Write(mongoFrame(mongoOpReply, opReplyBody(testBSON(doc))))
+		}
+	}
+}
+
+// serveMongo 启动本地的模拟服务，返回端口
+func serveMongo(t *testing.T, f *fakeMongo) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestMongoDBScan(t *testing.T) {
+	databases := []any{
+		[]bsonElem{{"name", "admin"}, {"sizeOnDisk", 40960.0}},
+		[]bsonElem{{"name", "shop"}, {"sizeOnDisk", int64(1 << 20)}},
+		"not a document",
+	}
+
+	tests := []struct {
+		name     string
+		server   fakeMongo
+		opCodes  []int32
+		findings []string
+		title    string
+		evidence string // mongodb-databases 的证据
+	}{
+		{
+			name:     "OP_MSG未授权",
+			server:   fakeMongo{wire: 21, version: "8.0.4", databases: databases},
+			opCodes:  []int32{mongoOpQuery, mongoOpMsg, mongoOpMsg},
+			findings: []string{"mongodb-version", "mongodb-unauthenticated", "mongodb-databases"},
+			title:    "MongoDB 8.0.4",
+			evidence: "admin（40960 字节）\nshop（1048576 字节）",
+		},
+		{
+			name:     "OP_QUERY旧版本",
+			server:   fakeMongo{wire: 5, version: "3.4.24", databases: databases[:1]},
+			opCodes:  []int32{mongoOpQuery, mongoOpQuery, mongoOpQuery},
+			findings: []string{"mongodb-version", "mongodb-eol-version", "mongodb-unauthenticated", "mongodb-databases"},
+			title:    "MongoDB 3.4.24",
+			evidence: "admin（40960 字节）",
+		},
+		{
+			name:     "停止支持的副本集",
+			server:   fakeMongo{wire: 6, version: "3.6.23", hello: []bsonElem{{"setName", "rs0"}}, databases: []any{}},
+			opCodes:  []int32{mongoOpQuery, mongoOpMsg, mongoOpMsg},
+			findings: []string{"mongodb-version", "mongodb-eol-version", "mongodb-unauthenticated", "mongodb-databases"},
+			title:    "MongoDB 3.6.23，副本集 rs0",
+		},
+		{
+			name:     "需要认证的mongos",
+			server:   fakeMongo{wire: 17, version: "6.0.1", hello: []bsonElem{{"msg", "isdbgrid"}}, auth: true},
+			opCodes:  []int32{mongoOpQuery, mongoOpMsg, mongoOpMsg},
+			findings: []string{"mongodb-version", "mongodb-eol-version", "mongodb-auth-required"},
+			title:    "MongoDB 6.0.1，mongos 路由",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveMongo(t, &tt.server)
+			result, err := (&MongoDBPlugin{}).ScanContext(context.Background(), "127.0.0.1", port, 2*time.Second)
+			if err != nil {
+				t.Fatalf("ScanContext 返回错误: %v", err)
+			}
+			var ids []string
+			for _, f := range result.Findings {
+				ids = append(ids, f.ID)
+				switch f.ID {
+				case "mongodb-version":
+					if f.Title != tt.title {
+						t.Errorf("版本标题 = %q，期望 %q", f.Title, tt.title)
+					}
+				case "mongodb-databases":
+					if f.Evidence != tt.evidence {
+						t.Errorf("数据库证据 = %q，期望 %q", f.Evidence, tt.evidence)
+					}
+				}
+			}
+			if !slices.Equal(ids, tt.findings) {
+				t.Errorf("发现 = %v，期望 %v", ids, tt.findings)
+			}
+			if !slices.Equal(tt.server.opCodes, tt.opCodes) {
+				t.Errorf("请求类型 = %v，期望 %v", tt.server.opCodes, tt.opCodes)
+			}
+		})
+	}
+}
+
+func TestMongoDBScanNotMongo(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+		io.Copy(io.Discard, conn)
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	if result, err := (&MongoDBPlugin{}).ScanContext(context.Background(), "127.0.0.1", port, time.Second); err == nil {
+		t.Errorf("期望返回错误，得到 %+v", result)
+	}
+}
+
+func FuzzDecodeBSON(f *testing.F) {
+	f.Add(testBSON([]bsonElem{{"ok", 1.0}, {"version", "7.0.5"}}))
+	f.Add(testBSON([]bsonElem{{"databases", []any{[]bsonElem{{"name", "admin"}}}}}))
+	f.Add(rawBSON("\x05bin\x00\x03\x00\x00\x00\x00abc"))
+	f.Add(nestedBSON(8))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, n, err := decodeBSON(data, 0)
+		if err == nil && (n < 5 || n > len(data)) {
+			t.Errorf("占用 %d 字节，数据只有 %d 字节", n, len(data))
+		}
+	})
+}
@@ -0,0 +1,444 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MySQLPlugin MySQL/MariaDB 握手信息和弱口令检测插件
+// 解析服务器的初始握手包，得到版本、默认认证插件和 SSL 支持。
+// TestCredentials 为 true 时额外用 mysql_native_password 和 caching_sha2_password 的认证流程
+// 测试常见口令，默认关闭以免触发账号锁定
+type MySQLPlugin struct {
+	TestCredentials bool
+}
+
+// mysqlCredentials 尝试的账号，root 空口令是许多安装包的默认配置
+var mysqlCredentials = []struct {
+	username string
+	password string
+}{
+	{"root", ""},
+	{"root", "root"},
+	{"root", "123456"},
+	{"root", "password"},
+	{"mysql", "mysql"},
+}
+
+// MySQL 协议常量
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientTransactions     = 0x00002000
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+	mysqlMaxPacket              = 1 << 24
+	mysqlNativePassword         = "mysql_native_password"
+	mysqlCachingSHA2Password    = "caching_sha2_password"
+)
+
+// mysqlGreeting 服务器的初始握手包（协议版本 10）
+type mysqlGreeting struct {
+	Version      string
+	ConnectionID uint32
+	Capabilities uint32
+	Salt         []byte
+	AuthPlugin   string
+}
+
+// mysqlError 服务器返回的错误包
+type mysqlError struct {
+	Code    uint16
+	Message string
+}
+
+// Error 错误信息
+func (e *mysqlError) Error() string {
+	return fmt.Sprintf("MySQL 错误 %d: %s", e.Code, e.Message)
+}
+
+// Name 插件名称
+func (p *MySQLPlugin) Name() string {
+	return "mysql-audit"
+}
+
+// Description 插件描述
+func (p *MySQLPlugin) Description() string {
+	if p.TestCredentials {
+		return "检测MySQL/MariaDB的版本、认证方式和弱口令"
+	}
+	return "检测MySQL/MariaDB的版本、认证方式和SSL支持"
+}
+
+// Metadata 插件元数据，开启口令测试时属于 bruteforce 类别
+func (p *MySQLPlugin) Metadata() Metadata {
+	category := CategorySafe
+	if p.TestCredentials {
+		category = CategoryBruteforce
+	}
+	return Metadata{
+		Services: []string{"mysql"},
+		Ports:    []int{3306},
+		Category: category,
+		References: []string{
+			"https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase.html",
+			"https://endoflife.date/mysql",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *MySQLPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *MySQLPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	conn, greeting, err := mysqlConnect(ctx, address, timeout)
+	if err != nil {
+		// 服务器不允许扫描主机连接时直接返回错误包
+		if myErr, ok := err.(*mysqlError); ok {
+			return Result{
+				Details:  "服务器拒绝了连接: " + myErr.Message,
+				Findings: []Finding{{ID: "mysql-host-denied", Title: "MySQL 拒绝了来自扫描主机的连接", Severity: "info", Evidence: myErr.Error()}},
+			}, nil
+		}
+		return Result{Vulnerable: false}, err
+	}
+	conn.Close()
+
+	product, version := mysqlProduct(greeting.Version)
+	ssl := greeting.Capabilities&mysqlClientSSL != 0
+	sslText := "不支持 SSL"
+	if ssl {
+		sslText = "支持 SSL"
+	}
+	findings := []Finding{{
+		ID:       "mysql-version",
+		Title:    fmt.Sprintf("%s %s，认证插件 %s，%s", product, version, greeting.AuthPlugin, sslText),
+		Severity: "info",
+		Evidence: fmt.Sprintf("server_version=%s capabilities=0x%08x auth_plugin=%s", greeting.Version, greeting.Capabilities, greeting.AuthPlugin),
+	}}
+	if eol, ok := eolFinding("mysql-eol-version", product, version, time.Now()); ok {
+		findings = append(findings, eol)
+	}
+	if !ssl {
+		findings = append(findings, Finding{
+			ID:          "mysql-ssl-disabled",
+			Title:       "MySQL 不支持 SSL，口令和数据以明文传输",
+			Severity:    "low",
+			CVSS:        3.7,
+			Remediation: "配置服务器证书开启 TLS，并对远程账户设置 REQUIRE SSL",
+		})
+	}
+
+	// 口令测试需显式开启，否则只报告握手信息
+	if !p.TestCredentials {
+		return Result{Evidence: greeting.Version, Findings: findings}, nil
+	}
+	for _, cred := range mysqlCredentials {
+		if ctx.Err() != nil {
+			return Result{Vulnerable: false}, ctx.Err()
+		}
+		ok, err := mysqlLogin(ctx, address, cred.username, cred.password, timeout)
+		if err != nil {
+			findings = append(findings, Finding{ID: "mysql-auth-untested", Title: "无法测试口令: " + err.Error(), Severity: "info"})
+			break
+		}
+		if ok {
+			findings = append(findings, Finding{
+				ID:          "mysql-weak-password",
+				Title:       fmt.Sprintf("发现弱口令: %s/%s", cred.username, cred.password),
+				Severity:    "critical",
+				CVSS:        9.8,
+				Evidence:    fmt.Sprintf("以 %s/%s 登录成功", cred.username, cred.password),
+				Remediation: "为该账户设置强口令，删除匿名账户，并限制 root 只能从本机登录",
+			})
+			break
+		}
+	}
+
+	return Result{Evidence: greeting.Version, Findings: findings}, nil
+}
+
+// mysqlProduct 区分 MySQL 和 MariaDB，MariaDB 5.5.5- 前缀是为兼容旧客户端加的
+func mysqlProduct(serverVersion string) (string, string) {
+	if strings.Contains(serverVersion, "MariaDB") {
+		version := strings.TrimPrefix(serverVersion, "5.5.5-")
+		version, _, _ = strings.Cut(version, "-")
+		return "MariaDB", version
+	}
+	version, _, _ := strings.Cut(serverVersion, "-")
+	return "MySQL", version
+}
+
+// mysqlConn MySQL 连接，记录报文序号
+type mysqlConn struct {
+	net.Conn
+	reader *bufio.Reader
+	seq    byte
+}
+
+// mysqlConnect 连接服务器并读取初始握手包，调用者负责关闭连接
+func mysqlConnect(ctx context.Context, address string, timeout time.Duration) (*mysqlConn, *mysqlGreeting, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := &mysqlConn{Conn: raw, reader: bufio.NewReader(raw)}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	payload, err := conn.readPacket()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("读取握手包失败: %v", err)
+	}
+	greeting, err := parseMySQLGreeting(payload)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, greeting, nil
+}
+
+// parseMySQLGreeting 解析初始握手包
+func parseMySQLGreeting(payload []byte) (*mysqlGreeting, error) {
+	if len(payload) > 0 && payload[0] == 0xff {
+		return nil, parseMySQLError(payload)
+	}
+	if len(payload) < 1 || payload[0] != 10 {
+		return nil, fmt.Errorf("不是MySQL服务或协议版本不受支持")
+	}
+
+	data := payload[1:]
+	end := bytes.IndexByte(data, 0)
+	if end < 0 || len(data) < end+1+4+8+1+2 {
+		return nil, fmt.Errorf("握手包不完整")
+	}
+	g := &mysqlGreeting{Version: string(data[:end]), AuthPlugin: mysqlNativePassword}
+	data = data[end+1:]
+	g.ConnectionID = binary.LittleEndian.Uint32(data)
+	g.Salt = append(g.Salt, data[4:12]...)
+	g.Capabilities = uint32(binary.LittleEndian.Uint16(data[13:15]))
+	data = data[15:]
+
+	// 字符集、状态、能力标志高位、认证数据长度和 10 字节保留
+	if len(data) < 1+2+2+1+10 {
+		return g, nil
+	}
+	g.Capabilities |= uint32(binary.LittleEndian.Uint16(data[3:5])) << 16
+	saltLen := int(data[5])
+	data = data[16:]
+	if g.Capabilities&mysqlClientSecureConnection != 0 {
+		n := max(13, saltLen-8)
+		if len(data) < n {
+			return g, nil
+		}
+		g.Salt = append(g.Salt, bytes.TrimRight(data[:n], "\x00")...)
+		data = data[n:]
+	}
+	if g.Capabilities&mysqlClientPluginAuth != 0 {
+		if name, _, _ := bytes.Cut(data, []byte{0}); len(name) > 0 {
+			g.AuthPlugin = string(name)
+		}
+	}
+	return g, nil
+}
+
+// parseMySQLError 解析错误包：0xff、错误码、可选的 # 和 SQL 状态、错误信息
+func parseMySQLError(payload []byte) *mysqlError {
+	if len(payload) < 3 {
+		return &mysqlError{Message: "错误包不完整"}
+	}
+	e := &mysqlError{Code: binary.LittleEndian.Uint16(payload[1:3])}
+	message := payload[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		message = message[6:]
+	}
+	e.Message = string(message)
+	return e
+}
+
+// mysqlLogin 用账号口令登录，认证失败返回 false，无法完成认证流程时返回错误
+func mysqlLogin(ctx context.Context, address, username, password string, timeout time.Duration) (bool, error) {
+	conn, greeting, err := mysqlConnect(ctx, address, timeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	plugin, salt := greeting.AuthPlugin, greeting.Salt
+	authData, err := mysqlScramble(plugin, password, salt)
+	if err != nil {
+		return false, err
+	}
+
+	// 握手响应（HandshakeResponse41）
+	flags := uint32(mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientTransactions |
+		mysqlClientSecureConnection | mysqlClientPluginAuth)
+	flags &= greeting.Capabilities | mysqlClientProtocol41
+	var resp bytes.Buffer
+	binary.Write(&resp, binary.LittleEndian, flags)
+	binary.Write(&resp, binary.LittleEndian, uint32(mysqlMaxPacket-1))
+	resp.WriteByte(0x21) // utf8_general_ci
+	resp.Write(make([]byte, 23))
+	resp.WriteString(username)
+	resp.WriteByte(0)
+	resp.WriteByte(byte(len(authData)))
+	resp.Write(authData)
+	resp.WriteString(plugin)
+	resp.WriteByte(0)
+	if err := conn.writePacket(resp.Bytes()); err != nil {
+		return false, err
+	}
+
+	for range 4 {
+		conn.SetDeadline(time.Now().Add(timeout))
+		payload, err := conn.readPacket()
+		if err != nil {
+			return false, err
+		}
+		if len(payload) == 0 {
+			return false, fmt.Errorf("认证响应为空")
+		}
+
+		switch payload[0] {
+		case 0x00:
+			return true, nil
+		case 0xff:
+			return false, nil
+		case 0xfe:
+			// 切换认证插件：插件名、新的随机数
+			name, data, _ := bytes.Cut(payload[1:], []byte{0})
+			plugin, salt = string(name), bytes.TrimRight(data, "\x00")
+			if authData, err = mysqlScramble(plugin, password, salt); err != nil {
+				return false, err
+			}
+			err = conn.writePacket(authData)
+		case 0x01:
+			err = conn.cachingSHA2More(payload[1:], password, salt)
+		default:
+			return false, fmt.Errorf("无法识别的认证响应 0x%02x", payload[0])
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, fmt.Errorf("认证流程未结束")
+}
+
+// cachingSHA2More 处理 caching_sha2_password 的附加数据
+// 0x03 表示缓存命中，随后是 OK 包；0x04 表示需要完整认证，明文连接上先请求服务器公钥，
+// 再用 RSA-OAEP 加密口令；服务器返回公钥时 data 是 PEM
+func (c *mysqlConn) cachingSHA2More(data []byte, password string, salt []byte) error {
+	switch {
+	case len(data) == 1 && data[0] == 0x03:
+		return nil
+	case len(data) == 1 && data[0] == 0x04:
+		return c.writePacket([]byte{0x02})
+	case bytes.HasPrefix(data, []byte("-----BEGIN")):
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return fmt.Errorf("服务器公钥格式无效")
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("服务器公钥格式无效: %v", err)
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("服务器公钥不是RSA密钥")
+		}
+		if len(salt) == 0 {
+			return fmt.Errorf("服务器没有提供随机数")
+		}
+		plain := append([]byte(password), 0)
+		for i := range plain {
+			plain[i] ^= salt[i%len(salt)]
+		}
+		encrypted, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, plain, nil)
+		if err != nil {
+			return err
+		}
+		return c.writePacket(encrypted)
+	}
+	return fmt.Errorf("无法识别的 caching_sha2_password 数据")
+}
+
+// mysqlScramble 按认证插件计算口令的认证数据，空口令发送空数据
+func mysqlScramble(plugin, password string, salt []byte) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case mysqlNativePassword:
+		// SHA1(password) XOR SHA1(salt + SHA1(SHA1(password)))
+		stage1 := sha1.Sum([]byte(password))
+		stage2 := sha1.Sum(stage1[:])
+		h := sha1.New()
+		h.Write(salt)
+		h.Write(stage2[:])
+		return xorBytes(stage1[:], h.Sum(nil)), nil
+	case mysqlCachingSHA2Password:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + salt)
+		stage1 := sha256.Sum256([]byte(password))
+		stage2 := sha256.Sum256(stage1[:])
+		h := sha256.New()
+		h.Write(stage2[:])
+		h.Write(salt)
+		return xorBytes(stage1[:], h.Sum(nil)), nil
+	}
+	return nil, fmt.Errorf("不支持的认证插件 %s", plugin)
+}
+
+// xorBytes 逐字节异或，两个切片长度相同
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// readPacket 读取一个报文：3 字节长度、1 字节序号、载荷
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	c.seq = header[3] + 1
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// writePacket 写出一个报文，序号接着上一次读取的报文
+func (c *mysqlConn) writePacket(payload []byte) error {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), c.seq}
+	c.seq++
+	_, err := c.Write(append(header, payload...))
+	return err
+}
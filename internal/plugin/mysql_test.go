@@ -0,0 +1,512 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"net"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testMySQLSalt 测试用的 20 字节随机数
+var testMySQLSalt = []byte("abcdefghijklmnopqrst")
+
+// buildMySQLGreeting 构造协议版本 10 的初始握手包
+func buildMySQLGreeting(version string, caps uint32, salt []byte, plugin string) []byte {
+	g := append([]byte{10}, version...)
+	g = append(g, 0)
+	g = binary.LittleEndian.AppendUint32(g, 42)
+	g = append(g, salt[:8]...)
+	g = append(g, 0)
+	g = binary.LittleEndian.AppendUint16(g, uint16(caps))
+	g = append(g, 0x21, 0x02, 0x00)
+	g = binary.LittleEndian.AppendUint16(g, uint16(caps>>16))
+	g = append(g, byte(len(salt)+1))
+	g = append(g, make([]byte, 10)...)
+	g = append(g, salt[8:]...)
+	g = append(g, 0)
+	g = append(g, plugin...)
+	return append(g, 0)
+}
+
+const testMySQLCaps = mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth
+
+func TestParseMySQLGreeting(t *testing.T) {
+	mysql8 := buildMySQLGreeting("8.0.36", testMySQLCaps, testMySQLSalt, mysqlCachingSHA2Password)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		version string
+		plugin  string
+		salt    []byte
+		wantErr bool
+	}{
+		{name: "MySQL 8", payload: mysql8, version: "8.0.36", plugin: mysqlCachingSHA2Password, salt: testMySQLSalt},
+		{
+			name:    "MariaDB",
+			payload: buildMySQLGreeting("5.5.5-10.11.6-MariaDB-0+deb12u1", testMySQLCaps, testMySQLSalt, mysqlNativePassword),
+			version: "5.5.5-10.11.6-MariaDB-0+deb12u1", plugin: mysqlNativePassword, salt: testMySQLSalt,
+		},
+		{
+			name:    "不支持插件认证时使用默认插件",
+			payload: buildMySQLGreeting("5.1.73", mysqlClientProtocol41|mysqlClientSecureConnection, testMySQLSalt, "ignored"),
+			version: "5.1.73", plugin: mysqlNativePassword, salt: testMySQLSalt,
+		},
+		{
+			name:    "只有前8字节随机数的旧版握手包",
+			payload: mysql8[:1+len("8.0.36")+1+4+8+1+2],
+			version: "8.0.36", plugin: mysqlNativePassword, salt: testMySQLSalt[:8],
+		},
+		{
+			name:    "随机数第二段被截断",
+			payload: mysql8[:len(mysql8)-len(mysqlCachingSHA2Password)-8],
+			version: "8.0.36", plugin: mysqlNativePassword, salt: testMySQLSalt[:8],
+		},
+		{name: "错误包", payload: append([]byte{0xff, 0x6a, 0x04}, "Host '10.0.0.1' is not allowed to connect"...), wantErr: true},
+		{name: "空载荷", payload: nil, wantErr: true},
+		{name: "协议版本9", payload: append([]byte{9}, mysql8[1:]...), wantErr: true},
+		{name: "版本号没有结束符", payload: []byte("\x0a8.0.36"), wantErr: true},
+		{name: "版本号之后被截断", payload: mysql8[:12], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := parseMySQLGreeting(tt.payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %+v", g)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMySQLGreeting 返回错误: %v", err)
+			}
+			if g.Version != tt.version || g.AuthPlugin != tt.plugin || !bytes.Equal(g.Salt, tt.salt) || g.ConnectionID != 42 {
+				t.Errorf("parseMySQLGreeting = %+v，期望版本 %q 插件 %q 随机数 %q", g, tt.version, tt.plugin, tt.salt)
+			}
+		})
+	}
+
+	// 截断到任意长度都不能崩溃
+	for i := range mysql8 {
+		parseMySQLGreeting(mysql8[:i])
+	}
+}
+
+func TestParseMySQLError(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		code    uint16
+		message string
+	}{
+		{payload: append([]byte{0xff, 0x15, 0x04}, "#28000Access denied"...), code: 1045, message: "Access denied"},
+		{payload: append([]byte{0xff, 0x6a, 0x04}, "Host is blocked"...), code: 1130, message: "Host is blocked"},
+		{payload: []byte{0xff, 0x15, 0x04, '#', '2'}, code: 1045, message: "#2"},
+		{payload: []byte{0xff, 0x15}, message: "错误包不完整"},
+	}
+	for _, tt := range tests {
+		if e := parseMySQLError(tt.payload); e.Code != tt.code || e.Message != tt.message {
+			t.Errorf("parseMySQLError(%q) = %+v，期望 %d %q", tt.payload, e, tt.code, tt.message)
+		}
+	}
+}
+
+func TestMySQLProduct(t *testing.T) {
+	tests := []struct {
+		version, product, want string
+	}{
+		{version: "8.0.36", product: "MySQL", want: "8.0.36"},
+		{version: "5.7.44-log", product: "MySQL", want: "5.7.44"},
+		{version: "5.5.5-10.11.6-MariaDB-0+deb12u1", product: "MariaDB", want: "10.11.6"},
+		{version: "11.2.2-MariaDB", product: "MariaDB", want: "11.2.2"},
+	}
+	for _, tt := range tests {
+		if product, version := mysqlProduct(tt.version); product != tt.product || version != tt.want {
+			t.Errorf("mysqlProduct(%q) = %s %s，期望 %s %s", tt.version, product, version, tt.product, tt.want)
+		}
+	}
+}
+
+// mysqlHandshake 服务端读到的握手响应
+type mysqlHandshake struct {
+	user   string
+	auth   []byte
+	plugin string
+}
+
+// readMySQLHandshake 解析客户端的 HandshakeResponse41
+func readMySQLHandshake(t *testing.T, c *mysqlConn) mysqlHandshake {
+	t.Helper()
+	payload, err := c.readPacket()
+	if err != nil || len(payload) < 32 {
+		t.Errorf("读取握手响应失败: %v", err)
+		return mysqlHandshake{}
+	}
+	user, rest, _ := bytes.Cut(payload[32:], []byte{0})
+	if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+		t.Errorf("握手响应格式错误: %q", payload)
+		return mysqlHandshake{}
+	}
+	n := int(rest[0])
+	plugin, _, _ := bytes.Cut(rest[1+n:], []byte{0})
+	return mysqlHandshake{user: string(user), auth: rest[1 : 1+n], plugin: string(plugin)}
+}
+
+// checkNativePassword 按服务端算法校验 mysql_native_password 认证数据
+func checkNativePassword(auth, salt []byte, password string) bool {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.Sum(append(append([]byte{}, salt...), stage2[:]...))
+	if len(auth) != len(h) {
+		return false
+	}
+	candidate := sha1.Sum(xorBytes(auth, h[:]))
+	return candidate == stage2
+}
+
+// checkCachingSHA2 按服务端算法校验 caching_sha2_password 的快速认证数据
+func checkCachingSHA2(auth, salt []byte, password string) bool {
+	stage1 := sha256.Sum256([]byte(password))
+	stage2 := sha256.Sum256(stage1[:])
+	h := sha256.Sum256(append(stage2[:], salt...))
+	if len(auth) != len(h) {
+		return false
+	}
+	candidate := sha256.Sum256(xorBytes(auth, h[:]))
+	return candidate == stage2
+}
+
+var (
+	mysqlOK     = []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	mysqlDenied = append([]byte{0xff, 0x15, 0x04}, "#28000Access denied for user 'root'"...)
+)
+
+// serveMySQL 启动本地 MySQL 服务，每个连接先发送握手包再交给 handle
+func serveMySQL(t *testing.T, greeting []byte, handle func(c *mysqlConn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			raw, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer raw.Close()
+				c := &mysqlConn{Conn: raw, reader: bufio.NewReader(raw)}
+				if c.writePacket(greeting) == nil && handle != nil {
+					handle(c)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestMySQLLogin(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	native := buildMySQLGreeting("5.7.44", testMySQLCaps, testMySQLSalt, mysqlNativePassword)
+	sha2 := buildMySQLGreeting("8.0.36", testMySQLCaps, testMySQLSalt, mysqlCachingSHA2Password)
+	switchSalt := []byte("ABCDEFGHIJKLMNOPQRST")
+
+	// fullAuth 模拟 caching_sha2_password 的完整认证：发送公钥，解密并校验口令
+	fullAuth := func(c *mysqlConn, salt []byte, password string) {
+		c.writePacket([]byte{0x01, 0x04})
+		if req, err := c.readPacket(); err != nil || !bytes.Equal(req, []byte{0x02}) {
+			t.Errorf("期望客户端请求公钥，得到 %q (%v)", req, err)
+			return
+		}
+		c.writePacket(append([]byte{0x01}, publicPEM...))
+		encrypted, err := c.readPacket()
+		if err != nil {
+			return
+		}
+		plain, err := rsa.DecryptOAEP(sha1.New(), nil, key, encrypted, nil)
+		if err != nil {
+			t.Errorf("解密口令失败: %v", err)
+			c.writePacket(mysqlDenied)
+			return
+		}
+		for i := range plain {
+			plain[i] ^= salt[i%len(salt)]
+		}
+		if string(plain) == password+"\x00" {
+			c.writePacket(mysqlOK)
+		} else {
+			c.writePacket(mysqlDenied)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		greeting []byte
+		password string
+		handle   func(c *mysqlConn)
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name: "native口令正确", greeting: native, password: "secret", want: true,
+			handle: func(c *mysqlConn) {
+				hs := readMySQLHandshake(t, c)
+				if hs.user != "root" || hs.plugin != mysqlNativePassword || !checkNativePassword(hs.auth, testMySQLSalt, "secret") {
+					c.writePacket(mysqlDenied)
+					return
+				}
+				c.writePacket(mysqlOK)
+			},
+		},
+		{
+			name: "native口令错误", greeting: native, password: "wrong", want: false,
+			handle: func(c *mysqlConn) {
+				hs := readMySQLHandshake(t, c)
+				if checkNativePassword(hs.auth, testMySQLSalt, "secret") {
+					c.writePacket(mysqlOK)
+					return
+				}
+				c.writePacket(mysqlDenied)
+			},
+		},
+		{
+			name: "空口令发送空认证数据", greeting: native, password: "", want: true,
+			handle: func(c *mysqlConn) {
+				if hs := readMySQLHandshake(t, c); len(hs.auth) != 0 {
+					c.writePacket(mysqlDenied)
+					return
+				}
+				c.writePacket(mysqlOK)
+			},
+		},
+		{
+			name: "caching_sha2快速认证", greeting: sha2, password: "secret", want: true,
+			handle: func(c *mysqlConn) {
+				hs := readMySQLHandshake(t, c)
+				if hs.plugin != mysqlCachingSHA2Password || !checkCachingSHA2(hs.auth, testMySQLSalt, "secret") {
+					c.writePacket(mysqlDenied)
+					return
+				}
+				c.writePacket([]byte{0x01, 0x03})
+				c.writePacket(mysqlOK)
+			},
+		},
+		{
+			name: "caching_sha2通过RSA完整认证", greeting: sha2, password: "secret", want: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				fullAuth(c, testMySQLSalt, "secret")
+			},
+		},
+		{
+			name: "caching_sha2完整认证口令错误", greeting: sha2, password: "wrong", want: false,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				fullAuth(c, testMySQLSalt, "secret")
+			},
+		},
+		{
+			name: "切换认证插件", greeting: sha2, password: "secret", want: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				c.writePacket(append(append([]byte{0xfe}, mysqlNativePassword+"\x00"...), append(switchSalt, 0)...))
+				auth, err := c.readPacket()
+				if err != nil || !checkNativePassword(auth, switchSalt, "secret") {
+					c.writePacket(mysqlDenied)
+					return
+				}
+				c.writePacket(mysqlOK)
+			},
+		},
+		{
+			name: "切换到不支持的插件", greeting: sha2, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				c.writePacket(append([]byte{0xfe}, "sha256_password\x00salt\x00"...))
+			},
+		},
+		{
+			name: "切换后没有随机数再要求RSA认证", greeting: sha2, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				c.writePacket(append([]byte{0xfe}, mysqlCachingSHA2Password+"\x00"...))
+				c.readPacket()
+				c.writePacket(append([]byte{0x01}, publicPEM...))
+			},
+		},
+		{
+			name: "公钥格式错误", greeting: sha2, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				c.writePacket([]byte("\x01-----BEGIN PUBLIC KEY-----\ngarbage\n-----END PUBLIC KEY-----\n"))
+			},
+		},
+		{
+			name: "无法识别的附加数据", greeting: sha2, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				c.writePacket([]byte{0x01, 0x09})
+			},
+		},
+		{
+			name: "无法识别的认证响应", greeting: native, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				c.writePacket([]byte{0x07})
+			},
+		},
+		{
+			name: "认证响应为空", greeting: native, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				c.writePacket(nil)
+			},
+		},
+		{
+			name: "认证过程中断开", greeting: native, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+			},
+		},
+		{
+			name: "认证流程不结束", greeting: native, password: "secret", wantErr: true,
+			handle: func(c *mysqlConn) {
+				readMySQLHandshake(t, c)
+				for range 5 {
+					c.writePacket([]byte{0x01, 0x03})
+				}
+			},
+		},
+		{name: "握手包是错误包", greeting: append([]byte{0xff, 0x6a, 0x04}, "Host is not allowed"...), password: "secret", wantErr: true},
+		{name: "握手包被截断", greeting: native[:10], password: "secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveMySQL(t, tt.greeting, tt.handle)
+			ok, err := mysqlLogin(context.Background(), addr, "root", tt.password, 2*time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %v", ok)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mysqlLogin 返回错误: %v", err)
+			}
+			if ok != tt.want {
+				t.Errorf("mysqlLogin = %v，期望 %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestMySQLScan(t *testing.T) {
+	greeting := buildMySQLGreeting("8.4.3", testMySQLCaps, testMySQLSalt, mysqlNativePassword)
+
+	tests := []struct {
+		name        string
+		credentials bool
+		findings    []string
+		logins      int32
+	}{
+		{name: "默认只读取握手包", findings: []string{"mysql-version", "mysql-ssl-disabled"}},
+		{
+			name:        "开启口令测试",
+			credentials: true,
+			findings:    []string{"mysql-version", "mysql-ssl-disabled", "mysql-weak-password"},
+			logins:      2, // root 空口令失败后 root/root 成功
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logins atomic.Int32
+			addr := serveMySQL(t, greeting, func(c *mysqlConn) {
+				// 读取握手信息的连接不发送握手响应
+				if _, err := c.reader.Peek(1); err != nil {
+					return
+				}
+				logins.Add(1)
+				if hs := readMySQLHandshake(t, c); hs.user == "root" && checkNativePassword(hs.auth, testMySQLSalt, "root") {
+					c.writePacket(mysqlOK)
+					return
+				}
+				c.writePacket(mysqlDenied)
+			})
+			host, port, _ := net.SplitHostPort(addr)
+			portNum, _ := strconv.Atoi(port)
+
+			result, err := (&MySQLPlugin{TestCredentials: tt.credentials}).ScanContext(context.Background(), host, portNum, 2*time.Second)
+			if err != nil {
+				t.Fatalf("ScanContext 返回错误: %v", err)
+			}
+			var ids []string
+			for _, f := range result.Findings {
+				ids = append(ids, f.ID)
+			}
+			if !slices.Equal(ids, tt.findings) {
+				t.Errorf("发现 = %v，期望 %v", ids, tt.findings)
+			}
+			if n := logins.Load(); n != tt.logins {
+				t.Errorf("尝试登录 %d 次，期望 %d 次", n, tt.logins)
+			}
+		})
+	}
+
+	if c := (&MySQLPlugin{}).Metadata().Category; c != CategorySafe {
+		t.Errorf("默认类别 = %s，期望 %s", c, CategorySafe)
+	}
+	if c := (&MySQLPlugin{TestCredentials: true}).Metadata().Category; c != CategoryBruteforce {
+		t.Errorf("开启口令测试后类别 = %s，期望 %s", c, CategoryBruteforce)
+	}
+}
+
+func TestMySQLReadPacketTruncated(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "空数据", data: nil},
+		{name: "报文头不完整", data: []byte{5, 0}},
+		{name: "载荷不完整", data: []byte{5, 0, 0, 0, 'a', 'b'}},
+	}
+	for _, tt := range tests {
+		c := &mysqlConn{reader: bufio.NewReader(bytes.NewReader(tt.data))}
+		if payload, err := c.readPacket(); err == nil {
+			t.Errorf("%s: 期望返回错误，得到 %q", tt.name, payload)
+		}
+	}
+}
+
+func FuzzParseMySQLGreeting(f *testing.F) {
+	f.Add(buildMySQLGreeting("8.0.36", testMySQLCaps, testMySQLSalt, mysqlCachingSHA2Password))
+	f.Add([]byte{0xff, 0x15, 0x04})
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		parseMySQLGreeting(payload)
+	})
+}
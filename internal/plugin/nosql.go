@@ -0,0 +1,496 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nosqlMaxBody HTTP 接口响应体的读取上限
+const nosqlMaxBody = 1 << 20
+
+// nosqlMaxNames 证据中最多列出的数据库、索引或键名数量
+const nosqlMaxNames = 50
+
+// ElasticsearchPlugin Elasticsearch/OpenSearch未授权访问检测插件
+// 读取根路径的集群信息，并尝试无认证列出索引
+type ElasticsearchPlugin struct{}
+
+// Name 插件名称
+func (p *ElasticsearchPlugin) Name() string {
+	return "elasticsearch-unauth"
+}
+
+// Description 插件描述
+func (p *ElasticsearchPlugin) Description() string {
+	return "检测Elasticsearch/OpenSearch未授权访问并列出索引"
+}
+
+// Metadata 插件元数据
+func (p *ElasticsearchPlugin) Metadata() Metadata {
+	return Metadata{
+		Services: []string{"elasticsearch", "opensearch"},
+		Ports:    []int{9200},
+		Category: CategorySafe,
+		References: []string{
+			"https://www.elastic.co/guide/en/elasticsearch/reference/current/secure-cluster.html",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *ElasticsearchPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *ElasticsearchPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	client, err := newJSONClient(ctx, target, port, timeout)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	defer client.close()
+
+	var root struct {
+		Name        string `json:"name"`
+		ClusterName string `json:"cluster_name"`
+		Version     struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+		Tagline string `json:"tagline"`
+	}
+	status, err := client.get("/", &root)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return Result{
+			Details:  "Elasticsearch 已开启认证",
+			Findings: []Finding{{ID: "elasticsearch-auth-required", Title: "Elasticsearch 需要认证", Severity: "info", Evidence: fmt.Sprintf("GET %s/ → %d", client.baseURL, status)}},
+		}, nil
+	}
+	if status != http.StatusOK || root.Version.Number == "" {
+		return Result{Vulnerable: false}, fmt.Errorf("不是Elasticsearch服务: GET / 返回 %d", status)
+	}
+
+	product := "Elasticsearch"
+	if root.Version.Distribution == "opensearch" {
+		product = "OpenSearch"
+	}
+	findings := []Finding{{
+		ID:       "elasticsearch-version",
+		Title:    fmt.Sprintf("%s %s，集群 %s，节点 %s", product, root.Version.Number, root.ClusterName, root.Name),
+		Severity: "info",
+		Evidence: fmt.Sprintf("GET %s/ → %d", client.baseURL, status),
+	}}
+
+	var indices []struct {
+		Index     string `json:"index"`
+		DocsCount string `json:"docs.count"`
+		StoreSize string `json:"store.size"`
+	}
+	status, err = client.get("/_cat/indices?format=json&h=index,docs.count,store.size", &indices)
+	if err != nil || status != http.StatusOK {
+		// 根路径可以匿名访问但索引受限，说明配置了匿名用户的最小权限
+		findings = append(findings, Finding{
+			ID:       "elasticsearch-auth-required",
+			Title:    "Elasticsearch 不允许匿名列出索引",
+			Severity: "info",
+			Evidence: fmt.Sprintf("GET %s/_cat/indices → %d", client.baseURL, status),
+		})
+		return Result{Findings: findings}, nil
+	}
+
+	names := make([]string, 0, len(indices))
+	lines := make([]string, 0, len(indices))
+	for _, idx := range indices {
+		names = append(names, idx.Index)
+		lines = append(lines, fmt.Sprintf("%s（%s 条文档，%s）", idx.Index, idx.DocsCount, idx.StoreSize))
+	}
+	sort.Strings(names)
+	sort.Strings(lines)
+	findings = append(findings,
+		Finding{
+			ID:          "elasticsearch-unauthenticated",
+			Title:       fmt.Sprintf("%s 无需认证即可读取 %d 个索引", product, len(names)),
+			Severity:    "critical",
+			CVSS:        9.8,
+			Evidence:    fmt.Sprintf("GET %s/_cat/indices → %d", client.baseURL, status),
+			Remediation: "开启安全功能（xpack.security.enabled 或 OpenSearch Security 插件）并为用户分配最小权限，不要把 9200 端口暴露到公网",
+		},
+		Finding{
+			ID:       "elasticsearch-indices",
+			Title:    "索引: " + joinLimited(names),
+			Severity: "info",
+			Evidence: strings.Join(lines, "\n"),
+		},
+	)
+	return Result{Findings: findings}, nil
+}
+
+// CouchDBPlugin CouchDB未授权访问检测插件
+// 读取根路径的版本信息，并尝试无认证列出数据库（admin party 模式）
+type CouchDBPlugin struct{}
+
+// Name 插件名称
+func (p *CouchDBPlugin) Name() string {
+	return "couchdb-unauth"
+}
+
+// Description 插件描述
+func (p *CouchDBPlugin) Description() string {
+	return "检测CouchDB未授权访问并列出数据库"
+}
+
+// Metadata 插件元数据
+func (p *CouchDBPlugin) Metadata() Metadata {
+	return Metadata{
+		Services: []string{"couchdb"},
+		Ports:    []int{5984},
+		Category: CategorySafe,
+		References: []string{
+			"https://docs.couchdb.org/en/stable/intro/security.html",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *CouchDBPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *CouchDBPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	client, err := newJSONClient(ctx, target, port, timeout)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	defer client.close()
+
+	var root struct {
+		CouchDB string `json:"couchdb"`
+		Version string `json:"version"`
+		Vendor  struct {
+			Name string `json:"name"`
+		} `json:"vendor"`
+	}
+	status, err := client.get("/", &root)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	if status != http.StatusOK || root.CouchDB == "" {
+		return Result{Vulnerable: false}, fmt.Errorf("不是CouchDB服务: GET / 返回 %d", status)
+	}
+	findings := []Finding{{
+		ID:       "couchdb-version",
+		Title:    fmt.Sprintf("CouchDB %s（%s）", root.Version, root.Vendor.Name),
+		Severity: "info",
+		Evidence: fmt.Sprintf("GET %s/ → %d", client.baseURL, status),
+	}}
+
+	var dbs []string
+	status, err = client.get("/_all_dbs", &dbs)
+	if err != nil || status != http.StatusOK {
+		findings = append(findings, Finding{
+			ID:       "couchdb-auth-required",
+			Title:    "CouchDB 需要认证",
+			Severity: "info",
+			Evidence: fmt.Sprintf("GET %s/_all_dbs → %d", client.baseURL, status),
+		})
+		return Result{Findings: findings}, nil
+	}
+
+	findings = append(findings,
+		Finding{
+			ID:          "couchdb-unauthenticated",
+			Title:       fmt.Sprintf("CouchDB 无需认证即可列出 %d 个数据库", len(dbs)),
+			Severity:    "critical",
+			CVSS:        9.8,
+			Evidence:    fmt.Sprintf("GET %s/_all_dbs → %d", client.baseURL, status),
+			Remediation: "创建管理员账户退出 admin party 模式，设置 require_valid_user = true，并把 chttpd 绑定到可信地址",
+		},
+		Finding{
+			ID:       "couchdb-databases",
+			Title:    "数据库: " + joinLimited(dbs),
+			Severity: "info",
+			Evidence: strings.Join(dbs, "\n"),
+		},
+	)
+	return Result{Findings: findings}, nil
+}
+
+// MemcachedPlugin Memcached未授权访问检测插件
+// 使用文本协议读取 stats，并通过 stats cachedump 抽样少量键名
+type MemcachedPlugin struct{}
+
+// memcachedDumpSlabs cachedump 抽样的 slab 数量，每个 slab 最多取 memcachedDumpKeys 个键
+const (
+	memcachedDumpSlabs = 3
+	memcachedDumpKeys  = 10
+)
+
+// Name 插件名称
+func (p *MemcachedPlugin) Name() string {
+	return "memcached-unauth"
+}
+
+// Description 插件描述
+func (p *MemcachedPlugin) Description() string {
+	return "检测Memcached未授权访问并抽样键名"
+}
+
+// Metadata 插件元数据
+func (p *MemcachedPlugin) Metadata() Metadata {
+	return Metadata{
+		Services: []string{"memcached"},
+		Ports:    []int{11211},
+		Category: CategorySafe,
+		References: []string{
+			"https://github.com/memcached/memcached/wiki/SASLHowto",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *MemcachedPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *MemcachedPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	client := &memcachedClient{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+
+	stats, reply, err := client.stats("stats")
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	if len(stats) == 0 {
+		// 开启 SASL 的二进制协议服务对文本命令返回错误
+		if strings.HasPrefix(reply, "ERROR") || strings.HasPrefix(reply, "CLIENT_ERROR") {
+			return Result{
+				Details:  "Memcached 拒绝了文本协议命令",
+				Findings: []Finding{{ID: "memcached-auth-required", Title: "Memcached 可能开启了 SASL 认证", Severity: "info", Evidence: "stats → " + reply}},
+			}, nil
+		}
+		return Result{Vulnerable: false}, fmt.Errorf("不是Memcached服务: stats 返回 %q", reply)
+	}
+
+	var evidence []string
+	for _, key := range []string{"version", "uptime", "curr_connections", "curr_items", "bytes", "limit_maxbytes"} {
+		if v, ok := stats[key]; ok {
+			evidence = append(evidence, key+" "+v)
+		}
+	}
+	findings := []Finding{
+		{
+			ID:       "memcached-version",
+			Title:    fmt.Sprintf("Memcached %s，%s 个条目", stats["version"], stats["curr_items"]),
+			Severity: "info",
+			Evidence: strings.Join(evidence, "\n"),
+		},
+		{
+			ID:          "memcached-unauthenticated",
+			Title:       "Memcached 无需认证即可读写缓存",
+			Severity:    "high",
+			CVSS:        7.5,
+			Evidence:    "stats → STAT version " + stats["version"],
+			Remediation: "用 -l 只监听内网地址并关闭 UDP（-U 0），需要跨主机访问时开启 SASL 认证或 TLS",
+		},
+	}
+
+	if keys := client.sampleKeys(); len(keys) > 0 {
+		findings = append(findings, Finding{
+			ID:       "memcached-keys",
+			Title:    "键名抽样: " + joinLimited(keys),
+			Severity: "info",
+			Evidence: strings.Join(keys, "\n"),
+		})
+	}
+	return Result{Findings: findings}, nil
+}
+
+// memcachedClient 简单的 Memcached 文本协议客户端
+type memcachedClient struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// stats 发送 stats 类命令，解析 STAT 行直到 END
+// 回复不是 STAT 行时返回空结果和第一行内容
+func (c *memcachedClient) stats(command string) (map[string]string, string, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := io.WriteString(c.conn, command+"\r\n"); err != nil {
+		return nil, "", err
+	}
+	stats := make(map[string]string)
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "END":
+			return stats, "", nil
+		case strings.HasPrefix(line, "STAT "):
+			key, value, _ := strings.Cut(line[5:], " ")
+			stats[key] = value
+		case strings.HasPrefix(line, "ITEM "):
+			// cachedump 的格式为 ITEM <key> [<size> b; <expiry> s]
+			key, value, _ := strings.Cut(line[5:], " ")
+			stats[key] = value
+		default:
+			return nil, line, nil
+		}
+	}
+}
+
+// sampleKeys 从条目最多的几个 slab 中抽样键名，新版本禁用 cachedump 时返回空
+func (c *memcachedClient) sampleKeys() []string {
+	items, _, err := c.stats("stats items")
+	if err != nil {
+		return nil
+	}
+	// stats items 的键为 items:<slab>:number
+	type slab struct {
+		id    int
+		count int
+	}
+	var slabs []slab
+	for key, value := range items {
+		parts := strings.Split(key, ":")
+		if len(parts) != 3 || parts[2] != "number" {
+			continue
+		}
+		id, err1 := strconv.Atoi(parts[1])
+		count, err2 := strconv.Atoi(value)
+		if err1 == nil && err2 == nil && count > 0 {
+			slabs = append(slabs, slab{id, count})
+		}
+	}
+	sort.Slice(slabs, func(i, j int) bool {
+		if slabs[i].count != slabs[j].count {
+			return slabs[i].count > slabs[j].count
+		}
+		return slabs[i].id < slabs[j].id
+	})
+
+	var keys []string
+	for _, s := range slabs[:min(len(slabs), memcachedDumpSlabs)] {
+		dump, _, err := c.stats(fmt.Sprintf("stats cachedump %d %d", s.id, memcachedDumpKeys))
+		if err != nil {
+			break
+		}
+		for key := range dump {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonClient 访问 HTTP JSON 接口的客户端，baseURL 在创建时按协议探测确定
+type jsonClient struct {
+	ctx     context.Context
+	client  *http.Client
+	baseURL string
+}
+
+// close 关闭保持的连接，扫描结束时调用
+func (c *jsonClient) close() {
+	c.client.CloseIdleConnections()
+}
+
+// newJSONClient 先尝试 http，连接失败或服务只接受 TLS 时改用 https
+func newJSONClient(ctx context.Context, target string, port int, timeout time.Duration) (*jsonClient, error) {
+	host := net.JoinHostPort(target, strconv.Itoa(port))
+	c := &jsonClient{
+		ctx: ctx,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+	}
+
+	var lastErr error
+	for _, scheme := range []string{"http", "https"} {
+		c.baseURL = scheme + "://" + host
+		status, err := c.get("/", nil)
+		if err == nil && status != http.StatusBadRequest {
+			return c, nil
+		}
+		if err == nil {
+			// 向 HTTPS 端口发送明文请求时，部分服务返回 400
+			lastErr = fmt.Errorf("GET / 返回 %d", status)
+			continue
+		}
+		if ctx.Err() != nil {
+			c.close()
+			return nil, ctx.Err()
+		}
+		lastErr = err
+	}
+	c.close()
+	return nil, fmt.Errorf("HTTP请求失败: %v", lastErr)
+}
+
+// get 发送 GET 请求，状态码为 200 时把响应体解析到 v
+func (c *jsonClient) get(path string, v any) (int, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, nosqlMaxBody))
+	if err != nil {
+		return 0, err
+	}
+	if v == nil || resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return resp.StatusCode, fmt.Errorf("解析 %s 响应失败: %v", path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// joinLimited 用逗号连接名称，超过 nosqlMaxNames 个时截断并注明总数
+func joinLimited(names []string) string {
+	if len(names) == 0 {
+		return "（无）"
+	}
+	if len(names) <= nosqlMaxNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s 等 %d 个", strings.Join(names[:nosqlMaxNames], ", "), len(names))
+}
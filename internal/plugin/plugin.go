@@ -1,22 +1,175 @@
 package plugin
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 )
 
+// 插件类别
+const (
+	CategorySafe       = "safe"       // 只读取信息，不改变目标状态
+	CategoryIntrusive  = "intrusive"  // 发送可能影响目标的请求，如写入配置
+	CategoryBruteforce = "bruteforce" // 尝试登录，可能触发账号锁定或告警
+)
+
+// Categories 所有插件类别
+var Categories = []string{CategorySafe, CategoryIntrusive, CategoryBruteforce}
+
+// Metadata 插件元数据
+type Metadata struct {
+	Services   []string `json:"services,omitempty"`   // 安全扫描模式下按检测到的服务自动选择插件
+	Ports      []int    `json:"ports,omitempty"`      // 默认端口，服务未识别时也按端口选择
+	Category   string   `json:"category"`             // safe、intrusive、bruteforce
+	Author     string   `json:"author,omitempty"`     // 作者
+	References []string `json:"references,omitempty"` // 参考链接，如 CVE、厂商公告
+}
+
 // Plugin 插件接口
 type Plugin interface {
 	Name() string
 	Description() string
+	Metadata() Metadata
 	Scan(target string, port int, timeout time.Duration) (Result, error)
 }
 
+// ContextPlugin 支持取消的插件接口
+type ContextPlugin interface {
+	Plugin
+	ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error)
+}
+
+// ScanContext 以可取消的方式运行插件，并根据 Findings 补全结果的概要
+// 插件实现了 ContextPlugin 时直接调用；否则在后台运行 Scan，ctx 被取消时立即返回 ctx.Err()
+func ScanContext(ctx context.Context, p Plugin, target string, port int, timeout time.Duration) (Result, error) {
+	result, err := scanContext(ctx, p, target, port, timeout)
+	if err != nil {
+		return result, err
+	}
+	result.summarize()
+	return result, nil
+}
+
+// scanContext 调用插件
+func scanContext(ctx context.Context, p Plugin, target string, port int, timeout time.Duration) (Result, error) {
+	if cp, ok := p.(ContextPlugin); ok {
+		return cp.ScanContext(ctx, target, port, timeout)
+	}
+
+	type scanReply struct {
+		result Result
+		err    error
+	}
+	done := make(chan scanReply, 1)
+	go func() {
+		result, err := p.Scan(target, port, timeout)
+		done <- scanReply{result, err}
+	}()
+
+	select {
+	case reply := <-done:
+		return reply.result, reply.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
 // Result 插件扫描结果
+// Vulnerable、Details、Severity 是整体结论；插件发现多个问题时放在 Findings 中，
+// 此时 Vulnerable 和 Severity 由 ScanContext 根据 Findings 补全
 type Result struct {
-	Vulnerable bool   `json:"vulnerable"`
-	Details    string `json:"details"`
-	Severity   string `json:"severity"` // low, medium, high, critical
+	Vulnerable  bool      `json:"vulnerable"`
+	Details     string    `json:"details"`
+	Severity    string    `json:"severity"`              // info, low, medium, high, critical
+	Evidence    string    `json:"evidence,omitempty"`    // 支撑结论的原始数据，如服务器响应
+	Remediation string    `json:"remediation,omitempty"` // 修复建议
+	Findings    []Finding `json:"findings,omitempty"`    // 单独的问题
+}
+
+// Finding 插件发现的一个问题，severity 为 info 的只是信息，不算作风险
+type Finding struct {
+	ID          string  `json:"id"`                    // 稳定的标识，用于对比和跟踪，如 ssh-weak-cipher
+	Title       string  `json:"title"`                 // 问题描述
+	Severity    string  `json:"severity"`              // info, low, medium, high, critical
+	CVSS        float64 `json:"cvss,omitempty"`        // CVSS 基础分
+	Evidence    string  `json:"evidence,omitempty"`    // 支撑结论的原始数据
+	Remediation string  `json:"remediation,omitempty"` // 修复建议
+}
+
+// summarize 根据 Findings 补全 Vulnerable、Severity 和 Details
+func (r *Result) summarize() {
+	risky := 0
+	for _, f := range r.Findings {
+		if f.Severity != "info" {
+			r.Vulnerable = true
+			risky++
+		}
+		if severityRank(f.Severity) > severityRank(r.Severity) {
+			r.Severity = f.Severity
+		}
+	}
+	if r.Details == "" && len(r.Findings) > 0 {
+		if risky > 0 {
+			r.Details = fmt.Sprintf("发现 %d 个问题", risky)
+		} else {
+			r.Details = fmt.Sprintf("收集到 %d 条信息", len(r.Findings))
+		}
+	}
+}
+
+// severityRank 风险等级的排序值
+func severityRank(severity string) int {
+	switch severity {
+	case "critical":
+		return 5
+	case "high":
+		return 4
+	case "medium":
+		return 3
+	case "low":
+		return 2
+	case "info":
+		return 1
+	}
+	return 0
+}
+
+// validCategory 检查插件类别，为空时使用 safe
+func validCategory(category string) (string, error) {
+	if category == "" {
+		return CategorySafe, nil
+	}
+	if !slices.Contains(Categories, category) {
+		return "", fmt.Errorf("不支持的插件类别: %s（可选 %s）", category, strings.Join(Categories, "、"))
+	}
+	return category, nil
+}
+
+// unidentifiedServices 没有识别出具体应用的服务名，按端口选择插件
+var unidentifiedServices = []string{"", "unknown", "ssl", "tcpwrapped"}
+
+// ParseCategories 解析逗号分隔的插件类别，如 safe,intrusive；为空时只有 safe
+func ParseCategories(s string) ([]string, error) {
+	var categories []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !slices.Contains(Categories, c) {
+			return nil, fmt.Errorf("不支持的插件类别: %s（可选 %s）", c, strings.Join(Categories, "、"))
+		}
+		if !slices.Contains(categories, c) {
+			categories = append(categories, c)
+		}
+	}
+	if len(categories) == 0 {
+		categories = []string{CategorySafe}
+	}
+	return categories, nil
 }
 
 // PluginManager 插件管理器
@@ -43,6 +196,31 @@ func (pm *PluginManager) GetPlugin(name string) (Plugin, bool) {
 	return plugin, exists
 }
 
+// ForService 按检测到的服务选择插件，服务未识别时按插件声明的端口选择，结果按名称排序；
+// TLS 握手后未识别出应用层协议的端口（ssl）和 tcpwrapped 也视为未识别，
+// 如启用 TLS 的 Elasticsearch 8 默认端口
+// 只选择 categories 中的类别，categories 为空时只选择 safe，
+// 避免自动对所有匹配的端口运行口令猜测或修改配置的插件
+func (pm *PluginManager) ForService(service string, port int, categories []string) []Plugin {
+	unknown := slices.Contains(unidentifiedServices, service)
+	if len(categories) == 0 {
+		categories = []string{CategorySafe}
+	}
+
+	var plugins []Plugin
+	for _, p := range pm.plugins {
+		meta := p.Metadata()
+		if category, err := validCategory(meta.Category); err != nil || !slices.Contains(categories, category) {
+			continue
+		}
+		if slices.Contains(meta.Services, service) || (unknown && slices.Contains(meta.Ports, port)) {
+			plugins = append(plugins, p)
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name() < plugins[j].Name() })
+	return plugins
+}
+
 // ListPlugins 列出所有插件
 func (pm *PluginManager) ListPlugins() []string {
 	var plugins []string
@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseCategories(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "", want: []string{CategorySafe}},
+		{input: " , ", want: []string{CategorySafe}},
+		{input: "safe", want: []string{CategorySafe}},
+		{input: "intrusive", want: []string{CategoryIntrusive}},
+		{input: "safe,bruteforce", want: []string{CategorySafe, CategoryBruteforce}},
+		{input: " safe , intrusive ,bruteforce", want: []string{CategorySafe, CategoryIntrusive, CategoryBruteforce}},
+		{input: "safe,safe,intrusive,safe", want: []string{CategorySafe, CategoryIntrusive}},
+		{input: "safe,", want: []string{CategorySafe}},
+		{input: "Safe", wantErr: true},
+		{input: "safe,all", wantErr: true},
+		{input: "dos", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCategories(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCategories(%q) 错误 = %v，期望出错 %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseCategories(%q) = %v，期望 %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// stubPlugin 只有元数据的插件
+type stubPlugin struct {
+	name string
+	meta Metadata
+}
+
+func (p *stubPlugin) Name() string        { return p.name }
+func (p *stubPlugin) Description() string { return p.name }
+func (p *stubPlugin) Metadata() Metadata  { return p.meta }
+func (p *stubPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return Result{}, nil
+}
+
+func TestForService(t *testing.T) {
+	pm := NewPluginManager()
+	for _, p := range []*stubPlugin{
+		{name: "ssh-audit", meta: Metadata{Services: []string{"ssh"}, Ports: []int{22}, Category: CategorySafe}},
+		{name: "ssh-weak-password", meta: Metadata{Services: []string{"ssh"}, Ports: []int{22}, Category: CategoryBruteforce}},
+		{name: "http-headers", meta: Metadata{Services: []string{"http", "https"}, Ports: []int{80, 443}}}, // 未声明类别视为 safe
+		{name: "elasticsearch-unauth", meta: Metadata{Services: []string{"elasticsearch"}, Ports: []int{9200}, Category: CategorySafe}},
+		{name: "redis-config-write", meta: Metadata{Services: []string{"redis"}, Ports: []int{6379}, Category: CategoryIntrusive}},
+		{name: "bad-category", meta: Metadata{Services: []string{"ssh"}, Ports: []int{22}, Category: "dangerous"}},
+	} {
+		pm.RegisterPlugin(p)
+	}
+
+	tests := []struct {
+		name       string
+		service    string
+		port       int
+		categories []string
+		want       []string
+	}{
+		{name: "按服务选择", service: "ssh", port: 2222, want: []string{"ssh-audit"}},
+		{name: "服务已识别时不按端口选择", service: "http", port: 22, want: []string{"http-headers"}},
+		{name: "未识别的服务按端口选择", service: "unknown", port: 9200, want: []string{"elasticsearch-unauth"}},
+		{name: "没有服务名时按端口选择", service: "", port: 22, want: []string{"ssh-audit"}},
+		{name: "ssl按端口选择", service: "ssl", port: 9200, want: []string{"elasticsearch-unauth"}},
+		{name: "tcpwrapped按端口选择", service: "tcpwrapped", port: 443, want: []string{"http-headers"}},
+		{name: "没有匹配的插件", service: "ftp", port: 21},
+		{name: "未识别且端口不匹配", service: "unknown", port: 12345},
+		{name: "包含口令猜测", service: "ssh", port: 22, categories: []string{CategorySafe, CategoryBruteforce}, want: []string{"ssh-audit", "ssh-weak-password"}},
+		{name: "只选择口令猜测", service: "ssh", port: 22, categories: []string{CategoryBruteforce}, want: []string{"ssh-weak-password"}},
+		{name: "默认不选择修改配置的插件", service: "redis", port: 6379},
+		{name: "按端口选择时同样过滤类别", service: "ssl", port: 6379, categories: []string{CategoryIntrusive}, want: []string{"redis-config-write"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range pm.ForService(tt.service, tt.port, tt.categories) {
+				got = append(got, p.Name())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ForService(%q, %d, %v) = %v，期望 %v", tt.service, tt.port, tt.categories, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,485 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PostgreSQLPlugin PostgreSQL 认证方式和弱口令检测插件
+// 用 SSLRequest 检查 SSL 支持，用启动消息得到服务器要求的认证方式，trust 认证时登录后读取版本。
+// TestCredentials 为 true 时额外以明文口令、MD5 和 SCRAM-SHA-256 认证测试常见口令，
+// 默认关闭以免触发账号锁定
+type PostgreSQLPlugin struct {
+	TestCredentials bool
+}
+
+// postgresCredentials 尝试的账号
+var postgresCredentials = []struct {
+	username string
+	password string
+}{
+	{"postgres", "postgres"},
+	{"postgres", ""},
+	{"postgres", "123456"},
+	{"postgres", "password"},
+}
+
+// PostgreSQL 协议常量
+const (
+	pgProtocolVersion = 3 << 16
+	pgSSLRequestCode  = 80877103
+	pgMaxMessage      = 1 << 20
+	pgAuthOK          = 0
+	pgAuthCleartext   = 3
+	pgAuthMD5         = 5
+	pgAuthSASL        = 10
+	pgAuthSASLCont    = 11
+	pgAuthSASLFinal   = 12
+
+	// pgMaxScramIterations 接受的 SCRAM 迭代次数上限，PostgreSQL 默认 4096，
+	// 恶意服务器可以给出极大的次数让每次口令尝试耗尽CPU
+	pgMaxScramIterations = 100000
+)
+
+// pgError 服务器返回的 ErrorResponse
+type pgError struct {
+	Severity string
+	Code     string // SQLSTATE，如 28P01 口令错误
+	Message  string
+}
+
+// Error 错误信息
+func (e *pgError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Severity, e.Code, e.Message)
+}
+
+// Name 插件名称
+func (p *PostgreSQLPlugin) Name() string {
+	return "postgresql-audit"
+}
+
+// Description 插件描述
+func (p *PostgreSQLPlugin) Description() string {
+	if p.TestCredentials {
+		return "检测PostgreSQL的认证方式、SSL支持和弱口令"
+	}
+	return "检测PostgreSQL的认证方式和SSL支持"
+}
+
+// Metadata 插件元数据，开启口令测试时属于 bruteforce 类别
+func (p *PostgreSQLPlugin) Metadata() Metadata {
+	category := CategorySafe
+	if p.TestCredentials {
+		category = CategoryBruteforce
+	}
+	return Metadata{
+		Services: []string{"postgresql"},
+		Ports:    []int{5432},
+		Category: category,
+		References: []string{
+			"https://www.postgresql.org/docs/current/protocol-flow.html",
+			"https://www.postgresql.org/support/versioning/",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *PostgreSQLPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *PostgreSQLPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	ssl, err := postgresSSLSupported(ctx, address, timeout)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+
+	// 不带口令登录，得到服务器要求的认证方式；trust 认证时直接登录成功
+	attempt, err := postgresLogin(ctx, address, "postgres", nil, timeout)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+
+	var findings []Finding
+	var version, login string
+	switch {
+	case attempt.Method == "":
+		// 启动阶段就返回错误，如 pg_hba.conf 中没有匹配的规则
+		findings = append(findings, Finding{ID: "postgresql-connect-rejected", Title: "PostgreSQL 拒绝了登录: " + attempt.Err.Message, Severity: "info", Evidence: attempt.Err.Error()})
+	case attempt.OK:
+		version, login = attempt.Version, "postgres"
+		findings = append(findings, Finding{
+			ID:          "postgresql-trust-auth",
+			Title:       "PostgreSQL 对 postgres 用户使用 trust 认证，无需口令即可登录",
+			Severity:    "critical",
+			CVSS:        9.8,
+			Evidence:    "启动消息 user=postgres → AuthenticationOk",
+			Remediation: "在 pg_hba.conf 中把远程连接的认证方式改为 scram-sha-256",
+		})
+	default:
+		if attempt.Method == "password" {
+			findings = append(findings, Finding{
+				ID:          "postgresql-cleartext-auth",
+				Title:       "PostgreSQL 要求以明文发送口令",
+				Severity:    "medium",
+				CVSS:        5.9,
+				Evidence:    "AuthenticationCleartextPassword",
+				Remediation: "在 pg_hba.conf 中使用 scram-sha-256 认证，并开启 SSL",
+			})
+		}
+		// 口令测试需显式开启，否则只报告认证方式
+		if p.TestCredentials {
+			for _, cred := range postgresCredentials {
+				if ctx.Err() != nil {
+					return Result{Vulnerable: false}, ctx.Err()
+				}
+				result, err := postgresLogin(ctx, address, cred.username, &cred.password, timeout)
+				if err != nil {
+					findings = append(findings, Finding{ID: "postgresql-auth-untested", Title: "无法测试口令: " + err.Error(), Severity: "info"})
+					break
+				}
+				if result.OK {
+					version, login = result.Version, cred.username
+					findings = append(findings, Finding{
+						ID:          "postgresql-weak-password",
+						Title:       fmt.Sprintf("发现弱口令: %s/%s", cred.username, cred.password),
+						Severity:    "critical",
+						CVSS:        9.8,
+						Evidence:    fmt.Sprintf("以 %s/%s 登录成功（%s 认证）", cred.username, cred.password, result.Method),
+						Remediation: "为该账户设置强口令，并在 pg_hba.conf 中限制可以远程登录的用户和地址",
+					})
+					break
+				}
+			}
+		}
+	}
+
+	sslText := "不支持 SSL"
+	if ssl {
+		sslText = "支持 SSL"
+	}
+	title := "PostgreSQL"
+	if version != "" {
+		title += " " + version
+	} else {
+		title += "（未登录，无法获取版本）"
+	}
+	if attempt.Method != "" {
+		title += "，认证方式 " + attempt.Method
+	}
+	info := Finding{ID: "postgresql-version", Title: title + "，" + sslText, Severity: "info"}
+	if version != "" {
+		info.Evidence = fmt.Sprintf("以 %s 登录后 server_version=%s", login, version)
+	}
+	findings = append([]Finding{info}, findings...)
+
+	if eol, ok := eolFinding("postgresql-eol-version", "PostgreSQL", version, time.Now()); ok {
+		findings = append(findings, eol)
+	}
+	if !ssl {
+		findings = append(findings, Finding{
+			ID:          "postgresql-ssl-disabled",
+			Title:       "PostgreSQL 不支持 SSL，口令和数据以明文传输",
+			Severity:    "low",
+			CVSS:        3.7,
+			Remediation: "设置 ssl = on 并配置证书，在 pg_hba.conf 中对远程连接使用 hostssl",
+		})
+	}
+	return Result{Findings: findings}, nil
+}
+
+// postgresSSLSupported 发送 SSLRequest，服务器回复 S 表示支持 SSL，N 表示不支持
+func postgresSSLSupported(ctx context.Context, address string, timeout time.Duration) (bool, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	request := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), pgSSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return false, err
+	}
+	var reply [1]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return false, fmt.Errorf("不是PostgreSQL服务: %v", err)
+	}
+	switch reply[0] {
+	case 'S':
+		return true, nil
+	case 'N':
+		return false, nil
+	}
+	return false, fmt.Errorf("不是PostgreSQL服务: SSLRequest 返回 %q", reply[0])
+}
+
+// pgLoginResult 一次登录的结果
+type pgLoginResult struct {
+	OK      bool
+	Method  string   // trust、password、md5、scram-sha-256，启动阶段出错时为空
+	Version string   // 登录成功后的 server_version
+	Err     *pgError // 服务器返回的错误
+}
+
+// postgresLogin 以同名数据库发送启动消息并完成认证
+// password 为 nil 时只探测认证方式，服务器要求口令时立即返回
+func postgresLogin(ctx context.Context, address, username string, password *string, timeout time.Duration) (*pgLoginResult, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer raw.Close()
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+	defer stop()
+	conn := &pgConn{Conn: raw, reader: bufio.NewReader(raw), timeout: timeout}
+
+	var startup bytes.Buffer
+	binary.Write(&startup, binary.BigEndian, uint32(pgProtocolVersion))
+	for _, kv := range []string{"user", username, "database", username, "application_name", "netscanner"} {
+		startup.WriteString(kv)
+		startup.WriteByte(0)
+	}
+	startup.WriteByte(0)
+	if err := conn.send(0, startup.Bytes()); err != nil {
+		return nil, err
+	}
+
+	result := &pgLoginResult{}
+	var scram *scramClient
+	for range 16 {
+		kind, body, err := conn.receive()
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case 'E':
+			result.Err = parsePgError(body)
+			return result, nil
+		case 'S':
+			// ParameterStatus：名称、值
+			name, value, _ := bytes.Cut(body, []byte{0})
+			if string(name) == "server_version" {
+				result.Version = string(bytes.TrimRight(value, "\x00"))
+			}
+		case 'Z':
+			// ReadyForQuery，登录完成
+			conn.send('X', nil)
+			return result, nil
+		case 'R':
+			if len(body) < 4 {
+				return nil, fmt.Errorf("认证请求不完整")
+			}
+			code, data := binary.BigEndian.Uint32(body), body[4:]
+			if code == pgAuthOK {
+				result.OK = true
+				if result.Method == "" {
+					result.Method = "trust"
+				}
+				continue
+			}
+			if code == pgAuthSASLCont || code == pgAuthSASLFinal {
+				if scram == nil {
+					return nil, fmt.Errorf("未开始的 SASL 认证")
+				}
+				if code == pgAuthSASLCont {
+					msg, err := scram.final(data, *password)
+					if err != nil {
+						return nil, err
+					}
+					if err := conn.send('p', msg); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+
+			result.Method, err = pgAuthMethod(code, data)
+			if err != nil {
+				return nil, err
+			}
+			if password == nil {
+				return result, nil
+			}
+			switch code {
+			case pgAuthCleartext:
+				err = conn.send('p', append([]byte(*password), 0))
+			case pgAuthMD5:
+				// "md5" + md5(md5(password + user) + salt)
+				inner := md5.Sum([]byte(*password + username))
+				outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), data...))
+				err = conn.send('p', append([]byte("md5"+hex.EncodeToString(outer[:])), 0))
+			case pgAuthSASL:
+				scram = newScramClient()
+				err = conn.send('p', scram.initial())
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("登录流程未结束")
+}
+
+// pgAuthMethod 认证请求对应的认证方式，不支持的方式返回错误
+func pgAuthMethod(code uint32, data []byte) (string, error) {
+	switch code {
+	case pgAuthCleartext:
+		return "password", nil
+	case pgAuthMD5:
+		return "md5", nil
+	case pgAuthSASL:
+		// 以空字符分隔的机制列表
+		for _, mech := range strings.Split(string(data), "\x00") {
+			if mech == "SCRAM-SHA-256" {
+				return "scram-sha-256", nil
+			}
+		}
+		return "", fmt.Errorf("不支持的 SASL 机制: %s", strings.Trim(string(data), "\x00"))
+	}
+	return "", fmt.Errorf("不支持的认证方式 %d", code)
+}
+
+// parsePgError 解析 ErrorResponse 的字段：类型字节和以空字符结尾的值
+func parsePgError(body []byte) *pgError {
+	e := &pgError{}
+	for len(body) > 1 {
+		field := body[0]
+		value, rest, _ := bytes.Cut(body[1:], []byte{0})
+		switch field {
+		case 'S':
+			e.Severity = string(value)
+		case 'C':
+			e.Code = string(value)
+		case 'M':
+			e.Message = string(value)
+		}
+		body = rest
+	}
+	return e
+}
+
+// pgConn PostgreSQL 连接
+type pgConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// send 发送消息，kind 为 0 时是没有类型字节的启动消息
+func (c *pgConn) send(kind byte, body []byte) error {
+	var msg []byte
+	if kind != 0 {
+		msg = append(msg, kind)
+	}
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(body)+4))
+	msg = append(msg, body...)
+	c.SetDeadline(time.Now().Add(c.timeout))
+	_, err := c.Write(msg)
+	return err
+}
+
+// receive 读取一条消息：类型字节、包含自身的 4 字节长度、内容
+func (c *pgConn) receive() (byte, []byte, error) {
+	c.SetDeadline(time.Now().Add(c.timeout))
+	var header [5]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > pgMaxMessage {
+		return 0, nil, fmt.Errorf("消息长度无效: %d", length)
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+// scramClient SCRAM-SHA-256 客户端（RFC 7677），PostgreSQL 使用启动消息中的用户名，
+// 这里的用户名留空
+type scramClient struct {
+	nonce       string
+	clientFirst string
+}
+
+// newScramClient 生成客户端随机数
+func newScramClient() *scramClient {
+	buf := make([]byte, 18)
+	rand.Read(buf)
+	nonce := base64.RawStdEncoding.EncodeToString(buf)
+	return &scramClient{nonce: nonce, clientFirst: "n=,r=" + nonce}
+}
+
+// initial SASLInitialResponse：机制名、长度、client-first-message
+func (s *scramClient) initial() []byte {
+	msg := "n,," + s.clientFirst
+	body := append([]byte("SCRAM-SHA-256"), 0)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(msg)))
+	return append(body, msg...)
+}
+
+// final 根据 server-first-message 计算 client-final-message，格式错误时发送空证明让服务器拒绝，
+// 迭代次数超过 pgMaxScramIterations 时返回错误，不做计算
+func (s *scramClient) final(serverFirst []byte, password string) ([]byte, error) {
+	var nonce, salt string
+	iterations := 0
+	for _, attr := range strings.Split(string(serverFirst), ",") {
+		key, value, _ := strings.Cut(attr, "=")
+		switch key {
+		case "r":
+			nonce = value
+		case "s":
+			salt = value
+		case "i":
+			iterations, _ = strconv.Atoi(value)
+		}
+	}
+	if iterations > pgMaxScramIterations {
+		return nil, fmt.Errorf("SCRAM 迭代次数过大: %d", iterations)
+	}
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil || !strings.HasPrefix(nonce, s.nonce) || iterations <= 0 {
+		return []byte("c=biws,r=" + nonce + ",p="), nil
+	}
+
+	salted, err := pbkdf2.Key(sha256.New, password, saltBytes, iterations, sha256.Size)
+	if err != nil {
+		return []byte("c=biws,r=" + nonce + ",p="), nil
+	}
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := s.clientFirst + "," + string(serverFirst) + "," + withoutProof
+	proof := xorBytes(clientKey, hmacSHA256(storedKey[:], authMessage))
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// hmacSHA256 计算 HMAC-SHA256
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
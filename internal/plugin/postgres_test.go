@@ -0,0 +1,477 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScramFinalRFC7677(t *testing.T) {
+	// RFC 7677 第 3 节的示例，用户名 user，口令 pencil
+	s := &scramClient{nonce: "rOprNGfwEbeRWgbNEkqO", clientFirst: "n=user,r=rOprNGfwEbeRWgbNEkqO"}
+	serverFirst := "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	want := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+
+	got, err := s.final([]byte(serverFirst), "pencil")
+	if err != nil {
+		t.Fatalf("final 返回错误: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("final = %q，期望 %q", got, want)
+	}
+}
+
+func TestScramFinalMalformed(t *testing.T) {
+	s := &scramClient{nonce: "abc", clientFirst: "n=,r=abc"}
+	salt := base64.StdEncoding.EncodeToString([]byte("salt"))
+
+	tests := []struct {
+		name        string
+		serverFirst string
+		emptyProof  bool
+		wantErr     bool
+	}{
+		{name: "正常", serverFirst: "r=abcdef,s=" + salt + ",i=4096"},
+		{name: "缺少随机数", serverFirst: "s=" + salt + ",i=4096", emptyProof: true},
+		{name: "随机数不以客户端随机数开头", serverFirst: "r=xyz,s=" + salt + ",i=4096", emptyProof: true},
+		{name: "盐不是base64", serverFirst: "r=abcdef,s=!!!,i=4096", emptyProof: true},
+		{name: "缺少迭代次数", serverFirst: "r=abcdef,s=" + salt, emptyProof: true},
+		{name: "迭代次数为0", serverFirst: "r=abcdef,s=" + salt + ",i=0", emptyProof: true},
+		{name: "迭代次数为负数", serverFirst: "r=abcdef,s=" + salt + ",i=-5", emptyProof: true},
+		{name: "迭代次数不是数字", serverFirst: "r=abcdef,s=" + salt + ",i=many", emptyProof: true},
+		{name: "空消息", serverFirst: "", emptyProof: true},
+		{name: "没有等号", serverFirst: "garbage,,,", emptyProof: true},
+		{name: "迭代次数达到上限", serverFirst: fmt.Sprintf("r=abcdef,s=%s,i=%d", salt, pgMaxScramIterations)},
+		{name: "迭代次数超过上限", serverFirst: fmt.Sprintf("r=abcdef,s=%s,i=%d", salt, pgMaxScramIterations+1), wantErr: true},
+		{name: "迭代次数溢出", serverFirst: "r=abcdef,s=" + salt + ",i=99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.final([]byte(tt.serverFirst), "secret")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("final 返回错误: %v", err)
+			}
+			if empty := strings.HasSuffix(string(got), ",p="); empty != tt.emptyProof {
+				t.Errorf("final = %q，期望空证明 %v", got, tt.emptyProof)
+			}
+		})
+	}
+}
+
+func TestScramInitial(t *testing.T) {
+	s := newScramClient()
+	msg := s.initial()
+
+	mech, rest, ok := bytes.Cut(msg, []byte{0})
+	if !ok || string(mech) != "SCRAM-SHA-256" || len(rest) < 4 {
+		t.Fatalf("SASLInitialResponse 格式错误: %q", msg)
+	}
+	n := binary.BigEndian.Uint32(rest)
+	if int(n) != len(rest)-4 || string(rest[4:]) != "n,,"+s.clientFirst {
+		t.Errorf("client-first-message = %q（长度 %d）", rest[4:], n)
+	}
+	if newScramClient().nonce == s.nonce {
+		t.Error("两次生成的随机数相同")
+	}
+}
+
+func TestPgAuthMethod(t *testing.T) {
+	tests := []struct {
+		code    uint32
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{code: pgAuthCleartext, want: "password"},
+		{code: pgAuthMD5, data: "salt", want: "md5"},
+		{code: pgAuthSASL, data: "SCRAM-SHA-256-PLUS\x00SCRAM-SHA-256\x00\x00", want: "scram-sha-256"},
+		{code: pgAuthSASL, data: "SCRAM-SHA-256-PLUS\x00\x00", wantErr: true},
+		{code: pgAuthSASL, data: "", wantErr: true},
+		{code: 7, wantErr: true}, // GSSAPI
+	}
+	for _, tt := range tests {
+		got, err := pgAuthMethod(tt.code, []byte(tt.data))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("pgAuthMethod(%d, %q) = %q, %v，期望 %q", tt.code, tt.data, got, err, tt.want)
+		}
+	}
+}
+
+func TestParsePgError(t *testing.T) {
+	tests := []struct {
+		body string
+		want pgError
+	}{
+		{
+			body: "SFATAL\x00VFATAL\x00C28P01\x00Mpassword authentication failed for user \"postgres\"\x00\x00",
+			want: pgError{Severity: "FATAL", Code: "28P01", Message: `password authentication failed for user "postgres"`},
+		},
+		{body: "C28000\x00Mno pg_hba.conf entry", want: pgError{Code: "28000", Message: "no pg_hba.conf entry"}},
+		{body: "", want: pgError{}},
+		{body: "\x00", want: pgError{}},
+		{body: "S", want: pgError{}},
+	}
+	for _, tt := range tests {
+		if got := parsePgError([]byte(tt.body)); *got != tt.want {
+			t.Errorf("parsePgError(%q) = %+v，期望 %+v", tt.body, *got, tt.want)
+		}
+	}
+}
+
+// pgMessage 构造带类型字节的消息
+func pgMessage(kind byte, body []byte) []byte {
+	msg := binary.BigEndian.AppendUint32([]byte{kind}, uint32(len(body)+4))
+	return append(msg, body...)
+}
+
+// pgAuth 构造认证请求
+func pgAuth(code uint32, data string) []byte {
+	return pgMessage('R', append(binary.BigEndian.AppendUint32(nil, code), data...))
+}
+
+func TestPgReceive(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		kind    byte
+		body    string
+		wantErr bool
+	}{
+		{name: "正常消息", data: pgMessage('Z', []byte("I")), kind: 'Z', body: "I"},
+		{name: "空内容", data: pgMessage('R', nil), kind: 'R', body: ""},
+		{name: "空数据", data: nil, wantErr: true},
+		{name: "消息头不完整", data: []byte{'R', 0, 0}, wantErr: true},
+		{name: "长度小于4", data: []byte{'R', 0, 0, 0, 3}, wantErr: true},
+		{name: "长度超过上限", data: []byte{'R', 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "内容不完整", data: []byte{'R', 0, 0, 0, 12, 0, 0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				server.Write(tt.data)
+				server.Close()
+			}()
+
+			c := &pgConn{Conn: client, reader: bufio.NewReader(client), timeout: time.Second}
+			kind, body, err := c.receive()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %c %q", kind, body)
+				}
+				return
+			}
+			if err != nil || kind != tt.kind || string(body) != tt.body {
+				t.Errorf("receive = %c %q %v，期望 %c %q", kind, body, err, tt.kind, tt.body)
+			}
+		})
+	}
+}
+
+// fakePostgres 模拟 PostgreSQL 的认证流程
+type fakePostgres struct {
+	method     string // trust、password、md5、scram、reject
+	password   string
+	iterations int
+	serverMsg  []byte // 不为 nil 时在启动消息后原样发送，用于构造异常响应
+	logins     int32  // 收到的启动消息数，不含 SSLRequest
+}
+
+// readPgClientMessage 读取客户端消息，启动消息没有类型字节
+func readPgClientMessage(r *bufio.Reader, startup bool) ([]byte, error) {
+	if !startup {
+		if _, err := r.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(length[:])-4)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+func (f *fakePostgres) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	startup, err := readPgClientMessage(r, true)
+	if err != nil || len(startup) < 4 {
+		return
+	}
+	if binary.BigEndian.Uint32(startup) == pgSSLRequestCode {
+		conn.Write([]byte("N"))
+		return
+	}
+	atomic.AddInt32(&f.logins, 1)
+	params := strings.Split(string(startup[4:]), "\x00")
+	user := ""
+	if len(params) > 1 && params[0] == "user" {
+		user = params[1]
+	}
+
+	if f.serverMsg != nil {
+		conn.Write(f.serverMsg)
+		return
+	}
+
+	ok := false
+	switch f.method {
+	case "trust":
+		ok = true
+	case "reject":
+		conn.Write(pgMessage('E', []byte("SFATAL\x00C28000\x00Mno pg_hba.conf entry\x00\x00")))
+		return
+	case "password":
+		conn.Write(pgAuth(pgAuthCleartext, ""))
+		body, err := readPgClientMessage(r, false)
+		ok = err == nil && string(body) == f.password+"\x00"
+	case "md5":
+		salt := "\x01\x02\x03\x04"
+		conn.Write(pgAuth(pgAuthMD5, salt))
+		body, err := readPgClientMessage(r, false)
+		inner := md5.Sum([]byte(f.password + user))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+		ok = err == nil && string(body) == "md5"+hex.EncodeToString(outer[:])+"\x00"
+	case "scram":
+		ok = f.scram(conn, r)
+	}
+
+	if !ok {
+		conn.Write(pgMessage('E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00")))
+		return
+	}
+	conn.Write(pgAuth(pgAuthOK, ""))
+	conn.Write(pgMessage('S', []byte("server_version\x0016.2\x00")))
+	conn.Write(pgMessage('Z', []byte("I")))
+}
+
+// scram 按服务端算法校验 SCRAM-SHA-256 的客户端证明
+func (f *fakePostgres) scram(conn net.Conn, r *bufio.Reader) bool {
+	conn.Write(pgAuth(pgAuthSASL, "SCRAM-SHA-256\x00\x00"))
+	body, err := readPgClientMessage(r, false)
+	if err != nil {
+		return false
+	}
+	_, rest, _ := bytes.Cut(body, []byte{0})
+	if len(rest) < 4 {
+		return false
+	}
+	clientFirstBare := strings.TrimPrefix(string(rest[4:]), "n,,")
+	_, clientNonce, _ := strings.Cut(clientFirstBare, "r=")
+
+	salt := []byte("0123456789abcdef")
+	nonce := clientNonce + "server"
+	serverFirst := fmt.Sprintf("r=%s,s=%s,i=%d", nonce, base64.StdEncoding.EncodeToString(salt), f.iterations)
+	conn.Write(pgAuth(pgAuthSASLCont, serverFirst))
+
+	body, err = readPgClientMessage(r, false)
+	if err != nil {
+		return false
+	}
+	clientFinal := string(body)
+	withoutProof, proof, _ := strings.Cut(clientFinal, ",p=")
+	proofBytes, err := base64.StdEncoding.DecodeString(proof)
+	if err != nil || len(proofBytes) != sha256.Size {
+		return false
+	}
+
+	salted, _ := pbkdf2.Key(sha256.New, f.password, salt, f.iterations, sha256.Size)
+	mac := hmac.New(sha256.New, salted)
+	mac.Write([]byte("Client Key"))
+	storedKey := sha256.Sum256(mac.Sum(nil))
+	mac = hmac.New(sha256.New, storedKey[:])
+	mac.Write([]byte(clientFirstBare + "," + serverFirst + "," + withoutProof))
+	clientKey := xorBytes(proofBytes, mac.Sum(nil))
+	if sha256.Sum256(clientKey) != storedKey {
+		return false
+	}
+	conn.Write(pgAuth(pgAuthSASLFinal, "v=ignored"))
+	return true
+}
+
+// servePostgres 启动本地的模拟服务，返回地址
+func servePostgres(t *testing.T, f *fakePostgres) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestPostgresLogin(t *testing.T) {
+	password := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		server   fakePostgres
+		password *string
+		ok       bool
+		method   string
+		version  string
+		errCode  string
+		wantErr  bool
+	}{
+		{name: "trust", server: fakePostgres{method: "trust"}, ok: true, method: "trust", version: "16.2"},
+		{name: "只探测认证方式", server: fakePostgres{method: "scram", password: "secret", iterations: 4096}, method: "scram-sha-256"},
+		{name: "明文口令正确", server: fakePostgres{method: "password", password: "secret"}, password: password("secret"), ok: true, method: "password", version: "16.2"},
+		{name: "md5口令正确", server: fakePostgres{method: "md5", password: "secret"}, password: password("secret"), ok: true, method: "md5", version: "16.2"},
+		{name: "md5口令错误", server: fakePostgres{method: "md5", password: "secret"}, password: password("wrong"), method: "md5", errCode: "28P01"},
+		{name: "SCRAM口令正确", server: fakePostgres{method: "scram", password: "secret", iterations: 4096}, password: password("secret"), ok: true, method: "scram-sha-256", version: "16.2"},
+		{name: "SCRAM口令错误", server: fakePostgres{method: "scram", password: "secret", iterations: 4096}, password: password("wrong"), method: "scram-sha-256", errCode: "28P01"},
+		{name: "SCRAM迭代次数过大", server: fakePostgres{method: "scram", password: "secret", iterations: 1 << 30}, password: password("secret"), wantErr: true},
+		{name: "pg_hba拒绝", server: fakePostgres{method: "reject"}, password: password("secret"), errCode: "28000"},
+		{name: "不支持的认证方式", server: fakePostgres{serverMsg: pgAuth(7, "")}, password: password("secret"), wantErr: true},
+		{name: "未开始的SASL", server: fakePostgres{serverMsg: pgAuth(pgAuthSASLCont, "r=x")}, password: password("secret"), wantErr: true},
+		{name: "认证请求不完整", server: fakePostgres{serverMsg: pgMessage('R', []byte{0, 0})}, password: password("secret"), wantErr: true},
+		{name: "消息长度无效", server: fakePostgres{serverMsg: []byte{'R', 0, 0, 0, 1}}, password: password("secret"), wantErr: true},
+		{name: "连接被关闭", server: fakePostgres{serverMsg: []byte{}}, password: password("secret"), wantErr: true},
+		{
+			name:     "消息过多",
+			server:   fakePostgres{serverMsg: bytes.Repeat(pgMessage('N', []byte("notice")), 20)},
+			password: password("secret"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := servePostgres(t, &tt.server)
+			result, err := postgresLogin(context.Background(), addr, "postgres", tt.password, 2*time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("postgresLogin 返回错误: %v", err)
+			}
+			errCode := ""
+			if result.Err != nil {
+				errCode = result.Err.Code
+			}
+			if result.OK != tt.ok || result.Method != tt.method || result.Version != tt.version || errCode != tt.errCode {
+				t.Errorf("postgresLogin = %+v (错误码 %q)，期望 ok=%v 方式 %q 版本 %q 错误码 %q",
+					result, errCode, tt.ok, tt.method, tt.version, tt.errCode)
+			}
+		})
+	}
+}
+
+func TestPostgresScan(t *testing.T) {
+	tests := []struct {
+		name        string
+		server      fakePostgres
+		credentials bool
+		findings    []string
+		logins      int32
+	}{
+		{
+			name:     "默认只探测认证方式",
+			server:   fakePostgres{method: "md5", password: "postgres"},
+			findings: []string{"postgresql-version", "postgresql-ssl-disabled"},
+			logins:   1,
+		},
+		{
+			name:     "明文认证",
+			server:   fakePostgres{method: "password", password: "Zx9!long-random"},
+			findings: []string{"postgresql-version", "postgresql-cleartext-auth", "postgresql-ssl-disabled"},
+			logins:   1,
+		},
+		{
+			name:     "trust认证无需口令测试",
+			server:   fakePostgres{method: "trust"},
+			findings: []string{"postgresql-version", "postgresql-trust-auth", "postgresql-ssl-disabled"},
+			logins:   1,
+		},
+		{
+			name:        "开启口令测试",
+			server:      fakePostgres{method: "md5", password: "postgres"},
+			credentials: true,
+			findings:    []string{"postgresql-version", "postgresql-weak-password", "postgresql-ssl-disabled"},
+			logins:      2,
+		},
+		{
+			name:        "口令都不正确",
+			server:      fakePostgres{method: "scram", password: "Zx9!long-random", iterations: 4096},
+			credentials: true,
+			findings:    []string{"postgresql-version", "postgresql-ssl-disabled"},
+			logins:      int32(1 + len(postgresCredentials)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(servePostgres(t, &tt.server))
+			portNum, _ := strconv.Atoi(port)
+			result, err := (&PostgreSQLPlugin{TestCredentials: tt.credentials}).ScanContext(context.Background(), host, portNum, 2*time.Second)
+			if err != nil {
+				t.Fatalf("ScanContext 返回错误: %v", err)
+			}
+			var ids []string
+			for _, f := range result.Findings {
+				ids = append(ids, f.ID)
+			}
+			if !slices.Equal(ids, tt.findings) {
+				t.Errorf("发现 = %v，期望 %v", ids, tt.findings)
+			}
+			if n := atomic.LoadInt32(&tt.server.logins); n != tt.logins {
+				t.Errorf("尝试登录 %d 次，期望 %d 次", n, tt.logins)
+			}
+		})
+	}
+
+	if c := (&PostgreSQLPlugin{}).Metadata().Category; c != CategorySafe {
+		t.Errorf("默认类别 = %s，期望 %s", c, CategorySafe)
+	}
+	if c := (&PostgreSQLPlugin{TestCredentials: true}).Metadata().Category; c != CategoryBruteforce {
+		t.Errorf("开启口令测试后类别 = %s，期望 %s", c, CategoryBruteforce)
+	}
+}
+
+func FuzzScramFinal(f *testing.F) {
+	f.Add("r=abcdef,s=c2FsdA==,i=4096")
+	f.Add("r=abc,s=,i=1")
+	f.Fuzz(func(t *testing.T, serverFirst string) {
+		s := &scramClient{nonce: "abc", clientFirst: "n=,r=abc"}
+		s.final([]byte(serverFirst), "secret")
+	})
+}
@@ -0,0 +1,356 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisPlugin Redis未授权访问检测插件
+// 使用 RESP 协议发送 PING 和 INFO；取得访问权限后读取版本、角色和持久化配置，
+// 检查 protected-mode 和 CONFIG SET dir 是否可用。检查 CONFIG SET 时写回 dir 的原值，不改变服务器配置。
+// TestCredentials 为 true 时，需要认证的服务器额外尝试少量常见口令，默认关闭以免触发告警
+type RedisPlugin struct {
+	TestCredentials bool
+}
+
+// redisPasswords 需要认证时尝试的口令，空口令对应 requirepass 被设为空字符串的情况
+var redisPasswords = []string{"", "redis", "foobared", "123456", "password", "admin", "root"}
+
+// RESP 回复的限制
+const (
+	respMaxBulk  = 1 << 20
+	respMaxArray = 1024
+	respMaxDepth = 1 // 插件只用到平坦的数组，最多接受一层嵌套
+)
+
+// respError RESP 错误回复，如 -NOAUTH Authentication required
+type respError string
+
+// Error 错误信息
+func (e respError) Error() string {
+	return string(e)
+}
+
+// Name 插件名称
+func (p *RedisPlugin) Name() string {
+	return "redis-unauth"
+}
+
+// Description 插件描述
+func (p *RedisPlugin) Description() string {
+	if p.TestCredentials {
+		return "检测Redis未授权访问、弱口令和危险配置"
+	}
+	return "检测Redis未授权访问和危险配置"
+}
+
+// Metadata 插件元数据，开启口令测试时属于 bruteforce 类别
+func (p *RedisPlugin) Metadata() Metadata {
+	category := CategorySafe
+	if p.TestCredentials {
+		category = CategoryBruteforce
+	}
+	return Metadata{
+		Services: []string{"redis"},
+		Ports:    []int{6379},
+		Category: category,
+		References: []string{
+			"https://redis.io/docs/latest/operate/oss_and_stack/management/security/",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *RedisPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *RedisPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client := &respClient{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+
+	var findings []Finding
+	reply, err := client.do("PING")
+	var replyErr respError
+	switch {
+	case err == nil && reply == "PONG":
+		findings = append(findings, Finding{
+			ID:          "redis-unauthenticated",
+			Title:       "Redis 无需认证即可访问",
+			Severity:    "critical",
+			CVSS:        9.8,
+			Evidence:    "PING → +PONG",
+			Remediation: "设置 requirepass 或 ACL 用户口令，并通过 bind 和防火墙只允许可信地址访问",
+		})
+	case errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "DENIED"):
+		// protected mode 下拒绝非本地连接，说明没有设置口令但未暴露
+		return Result{
+			Details:  "Redis 处于保护模式，拒绝了非本地连接",
+			Evidence: string(replyErr),
+			Findings: []Finding{{ID: "redis-protected-mode", Title: "Redis 处于保护模式", Severity: "info", Evidence: string(replyErr)}},
+		}, nil
+	case errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOAUTH"):
+		if !p.TestCredentials {
+			return Result{
+				Details:  "Redis 需要认证，未测试口令",
+				Findings: []Finding{{ID: "redis-auth-required", Title: "Redis 需要认证", Severity: "info", Evidence: string(replyErr)}},
+			}, nil
+		}
+		password, ok, err := client.tryPasswords(ctx)
+		if err != nil {
+			return Result{Vulnerable: false}, err
+		}
+		if !ok {
+			return Result{
+				Details:  fmt.Sprintf("Redis 需要认证，未发现常见口令（尝试了 %d 个）", len(redisPasswords)),
+				Findings: []Finding{{ID: "redis-auth-required", Title: "Redis 需要认证", Severity: "info", Evidence: string(replyErr)}},
+			}, nil
+		}
+		findings = append(findings, Finding{
+			ID:          "redis-weak-password",
+			Title:       fmt.Sprintf("发现 Redis 弱口令: %q", password),
+			Severity:    "high",
+			CVSS:        8.8,
+			Evidence:    fmt.Sprintf("AUTH %s → +OK", password),
+			Remediation: "把 requirepass 改为足够长的随机口令，或使用 ACL 为每个应用单独设置用户",
+		})
+	case err != nil:
+		return Result{Vulnerable: false}, fmt.Errorf("不是Redis服务: %v", err)
+	default:
+		return Result{Vulnerable: false}, fmt.Errorf("不是Redis服务: PING 返回 %q", reply)
+	}
+
+	// 已取得访问权限，读取服务器信息和配置
+	info, err := client.do("INFO")
+	if err != nil {
+		return Result{Findings: findings}, nil
+	}
+	fields := parseRedisInfo(info)
+	findings = append(findings, redisInfoFinding(fields, client))
+	findings = append(findings, client.configFindings()...)
+
+	return Result{Findings: findings}, nil
+}
+
+// tryPasswords 逐个尝试口令，返回第一个成功的口令
+func (c *respClient) tryPasswords(ctx context.Context) (string, bool, error) {
+	for _, password := range redisPasswords {
+		if ctx.Err() != nil {
+			return "", false, ctx.Err()
+		}
+		reply, err := c.do("AUTH", password)
+		if err == nil && reply == "OK" {
+			return password, true, nil
+		}
+		var replyErr respError
+		if !errors.As(err, &replyErr) {
+			return "", false, err
+		}
+	}
+	return "", false, nil
+}
+
+// parseRedisInfo 解析 INFO 的 key:value 行
+func parseRedisInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// redisInfoFinding 汇总版本、角色和持久化配置
+func redisInfoFinding(fields map[string]string, client *respClient) Finding {
+	version := fields["redis_version"]
+	if version == "" {
+		version = "未知版本"
+	}
+	role := fields["role"]
+	if role == "slave" {
+		role = fmt.Sprintf("replica（主节点 %s:%s）", fields["master_host"], fields["master_port"])
+	} else if n := fields["connected_slaves"]; role == "master" && n != "" && n != "0" {
+		role = fmt.Sprintf("master（%s 个副本）", n)
+	}
+
+	var persistence []string
+	if save, err := client.configGet("save"); err == nil {
+		if save == "" {
+			persistence = append(persistence, "RDB 关闭")
+		} else {
+			persistence = append(persistence, "RDB save "+save)
+		}
+	} else if fields["rdb_last_save_time"] != "" {
+		persistence = append(persistence, "RDB 上次保存 "+fields["rdb_last_save_time"])
+	}
+	if fields["aof_enabled"] == "1" {
+		persistence = append(persistence, "AOF 开启")
+	} else if fields["aof_enabled"] == "0" {
+		persistence = append(persistence, "AOF 关闭")
+	}
+	if len(persistence) == 0 {
+		persistence = append(persistence, "未知")
+	}
+
+	var evidence []string
+	for _, key := range []string{"redis_version", "redis_mode", "os", "role", "connected_clients", "used_memory_human", "aof_enabled", "rdb_last_bgsave_status"} {
+		if v, ok := fields[key]; ok {
+			evidence = append(evidence, key+":"+v)
+		}
+	}
+	return Finding{
+		ID:       "redis-info",
+		Title:    fmt.Sprintf("Redis %s，角色 %s，持久化: %s", version, role, strings.Join(persistence, "，")),
+		Severity: "info",
+		Evidence: strings.Join(evidence, "\n"),
+	}
+}
+
+// configFindings 检查 protected-mode 和 CONFIG SET dir，CONFIG 命令被禁用或改名时跳过
+func (c *respClient) configFindings() []Finding {
+	var findings []Finding
+	if mode, err := c.configGet("protected-mode"); err == nil && mode == "no" {
+		findings = append(findings, Finding{
+			ID:          "redis-protected-mode-off",
+			Title:       "Redis 关闭了 protected-mode",
+			Severity:    "medium",
+			CVSS:        5.3,
+			Evidence:    "CONFIG GET protected-mode → no",
+			Remediation: "开启 protected-mode，或确认已设置口令且 bind 只监听可信地址",
+		})
+	}
+
+	dir, err := c.configGet("dir")
+	if err != nil {
+		return findings
+	}
+	// 写回原值，只验证命令可用，不改变配置
+	if reply, err := c.do("CONFIG", "SET", "dir", dir); err == nil && reply == "OK" {
+		findings = append(findings, Finding{
+			ID:          "redis-config-set-dir",
+			Title:       "可以通过 CONFIG SET 修改数据目录",
+			Severity:    "high",
+			CVSS:        8.8,
+			Evidence:    fmt.Sprintf("CONFIG SET dir %s → +OK", dir),
+			Remediation: "用 rename-command 禁用 CONFIG，或在 ACL 中去掉 @dangerous 权限；Redis 7 以上保持 enable-protected-configs no",
+		})
+	}
+	return findings
+}
+
+// respClient 简单的 RESP2 客户端，每条命令单独设置超时
+type respClient struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// do 发送命令并读取回复，错误回复以 respError 返回
+func (c *respClient) do(args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return "", err
+	}
+	reply, err := readRESP(c.reader, 0)
+	if err != nil {
+		return "", err
+	}
+	if items, ok := reply.([]string); ok {
+		return strings.Join(items, "\n"), nil
+	}
+	return reply.(string), nil
+}
+
+// configGet 读取一项配置，CONFIG GET 返回 [名称, 值]
+func (c *respClient) configGet(name string) (string, error) {
+	reply, err := c.do("CONFIG", "GET", name)
+	if err != nil {
+		return "", err
+	}
+	key, value, ok := strings.Cut(reply, "\n")
+	if !ok || key != name {
+		return "", fmt.Errorf("配置 %s 不存在", name)
+	}
+	return value, nil
+}
+
+// readRESP 读取一个回复：简单字符串、整数和批量字符串返回 string，数组返回 []string。
+// depth 是当前的数组嵌套层数，超过 respMaxDepth 时返回错误，避免深层嵌套耗尽栈空间
+func readRESP(r *bufio.Reader, depth int) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if line == "" {
+		return nil, fmt.Errorf("RESP 回复为空")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return nil, respError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > respMaxBulk {
+			return nil, fmt.Errorf("RESP 批量字符串长度无效: %q", line)
+		}
+		if n < 0 {
+			return "", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		if depth > respMaxDepth {
+			return nil, fmt.Errorf("RESP 数组嵌套层数超过 %d", respMaxDepth)
+		}
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > respMaxArray {
+			return nil, fmt.Errorf("RESP 数组长度无效: %q", line)
+		}
+		items := make([]string, 0, max(n, 0))
+		for range n {
+			item, err := readRESP(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("不是 RESP 回复: %q", line)
+}
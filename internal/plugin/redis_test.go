@@ -0,0 +1,319 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr string // 为 "resp" 时期望 respError
+	}{
+		{name: "简单字符串", input: "+PONG\r\n", want: "PONG"},
+		{name: "只有换行", input: "+OK\n", want: "OK"},
+		{name: "整数", input: ":42\r\n", want: "42"},
+		{name: "批量字符串", input: "$5\r\nhello\r\n", want: "hello"},
+		{name: "批量字符串含换行", input: "$7\r\na\r\nb\r\nc\r\n", want: "a\r\nb\r\nc"},
+		{name: "空批量字符串", input: "$0\r\n\r\n", want: ""},
+		{name: "空值", input: "$-1\r\n", want: ""},
+		{name: "数组", input: "*2\r\n$3\r\ndir\r\n$4\r\n/tmp\r\n", want: []string{"dir", "/tmp"}},
+		{name: "混合数组", input: "*3\r\n+a\r\n:1\r\n$1\r\nb\r\n", want: []string{"a", "1", "b"}},
+		{name: "空数组", input: "*0\r\n", want: []string{}},
+		{name: "空值数组", input: "*-1\r\n", want: []string{}},
+		{name: "嵌套数组被忽略", input: "*2\r\n*1\r\n+x\r\n+y\r\n", want: []string{"y"}},
+		{name: "嵌套两层", input: "*1\r\n*1\r\n*1\r\n+x\r\n", wantErr: "error"},
+		{name: "深层嵌套", input: strings.Repeat("*1\r\n", 100) + "+x\r\n", wantErr: "error"},
+		{name: "错误回复", input: "-NOAUTH Authentication required.\r\n", wantErr: "resp"},
+		{name: "数组中的错误", input: "*2\r\n+a\r\n-ERR boom\r\n", wantErr: "resp"},
+		{name: "空行", input: "\r\n", wantErr: "error"},
+		{name: "未知类型", input: "SSH-2.0-OpenSSH\r\n", wantErr: "error"},
+		{name: "HTTP响应", input: "HTTP/1.1 400 Bad Request\r\n", wantErr: "error"},
+		{name: "批量长度不是数字", input: "$abc\r\n", wantErr: "error"},
+		{name: "批量长度超过上限", input: "$" + strconv.Itoa(respMaxBulk+1) + "\r\n", wantErr: "error"},
+		{name: "数组长度不是数字", input: "*x\r\n", wantErr: "error"},
+		{name: "数组长度超过上限", input: "*" + strconv.Itoa(respMaxArray+1) + "\r\n", wantErr: "error"},
+		{name: "批量字符串不完整", input: "$10\r\nhello\r\n", wantErr: "error"},
+		{name: "缺少结尾换行", input: "+PONG", wantErr: "error"},
+		{name: "数组元素不足", input: "*3\r\n+a\r\n", wantErr: "error"},
+		{name: "空输入", input: "", wantErr: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRESP(bufio.NewReader(strings.NewReader(tt.input)), 0)
+			var replyErr respError
+			switch tt.wantErr {
+			case "resp":
+				if !errors.As(err, &replyErr) {
+					t.Errorf("readRESP(%q) = %v, %v，期望 RESP 错误回复", tt.input, got, err)
+				}
+				return
+			case "error":
+				if err == nil || errors.As(err, &replyErr) {
+					t.Errorf("readRESP(%q) = %v, %v，期望读取错误", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readRESP(%q) 返回错误: %v", tt.input, err)
+			}
+			switch want := tt.want.(type) {
+			case string:
+				if got != want {
+					t.Errorf("readRESP(%q) = %#v，期望 %#v", tt.input, got, want)
+				}
+			case []string:
+				items, ok := got.([]string)
+				if !ok || !slices.Equal(items, want) {
+					t.Errorf("readRESP(%q) = %#v，期望 %#v", tt.input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadRESPTruncated(t *testing.T) {
+	full := "*4\r\n+OK\r\n:7\r\n$6\r\nfoobar\r\n$-1\r\n"
+	for i := range len(full) {
+		if _, err := readRESP(bufio.NewReader(strings.NewReader(full[:i])), 0); err == nil {
+			t.Errorf("截断到 %d 字节时没有返回错误", i)
+		}
+	}
+	got, err := readRESP(bufio.NewReader(strings.NewReader(full)), 0)
+	if items, ok := got.([]string); err != nil || !ok || !slices.Equal(items, []string{"OK", "7", "foobar", ""}) {
+		t.Errorf("完整回复 = %#v, %v", got, err)
+	}
+}
+
+func TestReadRESPDepth(t *testing.T) {
+	// 数百万层嵌套的数组曾经耗尽栈空间
+	input := strings.NewReader(strings.Repeat("*1\r\n", 4<<20))
+	if _, err := readRESP(bufio.NewReader(input), 0); err == nil {
+		t.Error("深层嵌套没有返回错误")
+	}
+}
+
+func TestParseRedisInfo(t *testing.T) {
+	info := "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n\r\n# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nbad line\r\nexecutable:C:\\redis\\redis-server.exe\r\n"
+	want := map[string]string{
+		"redis_version": "7.2.4",
+		"redis_mode":    "standalone",
+		"role":          "slave",
+		"master_host":   "10.0.0.1",
+		"executable":    `C:\redis\redis-server.exe`,
+	}
+
+	got := parseRedisInfo(info)
+	if len(got) != len(want) {
+		t.Errorf("parseRedisInfo 得到 %d 项，期望 %d 项: %v", len(got), len(want), got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q，期望 %q", key, got[key], value)
+		}
+	}
+	if got := parseRedisInfo(""); len(got) != 0 {
+		t.Errorf("parseRedisInfo(\"\") = %v，期望为空", got)
+	}
+}
+
+// fakeRedis 模拟 Redis 的命令处理
+type fakeRedis struct {
+	password string // 为空时无需认证
+	denied   bool   // 保护模式下拒绝连接
+	raw      string // 不为空时对任何命令都返回这段数据
+	config   map[string]string
+	auths    int // 收到的 AUTH 命令数
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		// 客户端命令也是 RESP 数组
+		reply, err := readRESP(r, 0)
+		if err != nil {
+			return
+		}
+		args, ok := reply.([]string)
+		if !ok || len(args) == 0 {
+			return
+		}
+		if f.raw != "" {
+			conn.Write([]byte(f.raw))
+			return
+		}
+		conn.Write([]byte(f.reply(args, &authed)))
+	}
+}
+
+func (f *fakeRedis) reply(args []string, authed *bool) string {
+	cmd := strings.ToUpper(args[0])
+	switch {
+	case f.denied:
+		return "-DENIED Redis is running in protected mode\r\n"
+	case cmd == "AUTH":
+		f.auths++
+		if len(args) == 2 && args[1] == f.password {
+			*authed = true
+			return "+OK\r\n"
+		}
+		return "-WRONGPASS invalid username-password pair\r\n"
+	case !*authed:
+		return "-NOAUTH Authentication required.\r\n"
+	case cmd == "PING":
+		return "+PONG\r\n"
+	case cmd == "INFO":
+		info := "# Server\r\nredis_version:7.2.4\r\nrole:master\r\nconnected_slaves:2\r\naof_enabled:1\r\n"
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+	case cmd == "CONFIG" && f.config == nil:
+		return "-ERR unknown command 'CONFIG'\r\n"
+	case cmd == "CONFIG" && len(args) == 3 && strings.EqualFold(args[1], "GET"):
+		value, ok := f.config[args[2]]
+		if !ok {
+			return "*0\r\n"
+		}
+		return fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(args[2]), args[2], len(value), value)
+	case cmd == "CONFIG" && len(args) == 4 && strings.EqualFold(args[1], "SET"):
+		if f.config[args[2]] != args[3] {
+			return "-ERR config changed\r\n"
+		}
+		return "+OK\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+// serveRedis 启动本地的模拟服务，返回端口
+func serveRedis(t *testing.T, f *fakeRedis) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestRedisScan(t *testing.T) {
+	config := map[string]string{"save": "3600 1", "protected-mode": "no", "dir": "/var/lib/redis"}
+
+	tests := []struct {
+		name        string
+		server      fakeRedis
+		credentials bool
+		findings    []string
+		title       string // redis-info 的标题
+		auths       int
+		wantErr     bool
+	}{
+		{
+			name:     "无需认证",
+			server:   fakeRedis{config: config},
+			findings: []string{"redis-unauthenticated", "redis-info", "redis-protected-mode-off", "redis-config-set-dir"},
+			title:    "Redis 7.2.4，角色 master（2 个副本），持久化: RDB save 3600 1，AOF 开启",
+		},
+		{
+			name:     "CONFIG被禁用",
+			server:   fakeRedis{},
+			findings: []string{"redis-unauthenticated", "redis-info"},
+			title:    "Redis 7.2.4，角色 master（2 个副本），持久化: AOF 开启",
+		},
+		{
+			name:        "弱口令",
+			server:      fakeRedis{password: "foobared", config: map[string]string{"dir": "/data"}},
+			credentials: true,
+			findings:    []string{"redis-weak-password", "redis-info", "redis-config-set-dir"},
+			auths:       slices.Index(redisPasswords, "foobared") + 1,
+		},
+		{name: "未开启口令测试", server: fakeRedis{password: "foobared"}, findings: []string{"redis-auth-required"}},
+		{
+			name:        "需要认证",
+			server:      fakeRedis{password: "Zx9!long-random"},
+			credentials: true,
+			findings:    []string{"redis-auth-required"},
+			auths:       len(redisPasswords),
+		},
+		{name: "保护模式", server: fakeRedis{denied: true}, findings: []string{"redis-protected-mode"}},
+		{name: "不是Redis", server: fakeRedis{raw: "HTTP/1.1 400 Bad Request\r\n\r\n"}, wantErr: true},
+		{name: "PING返回其他内容", server: fakeRedis{raw: "+HELLO\r\n"}, wantErr: true},
+		{name: "回复不完整", server: fakeRedis{raw: "$100\r\nshort"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveRedis(t, &tt.server)
+			result, err := (&RedisPlugin{TestCredentials: tt.credentials}).ScanContext(context.Background(), "127.0.0.1", port, 2*time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanContext 返回错误: %v", err)
+			}
+			var ids []string
+			for _, f := range result.Findings {
+				ids = append(ids, f.ID)
+				if f.ID == "redis-info" && tt.title != "" && f.Title != tt.title {
+					t.Errorf("redis-info 标题 = %q，期望 %q", f.Title, tt.title)
+				}
+			}
+			if !slices.Equal(ids, tt.findings) {
+				t.Errorf("发现 = %v，期望 %v", ids, tt.findings)
+			}
+			if tt.server.auths != tt.auths {
+				t.Errorf("尝试了 %d 个口令，期望 %d 个", tt.server.auths, tt.auths)
+			}
+		})
+	}
+}
+
+func TestRedisCategory(t *testing.T) {
+	if c := (&RedisPlugin{}).Metadata().Category; c != CategorySafe {
+		t.Errorf("默认类别 = %s，期望 %s", c, CategorySafe)
+	}
+	if c := (&RedisPlugin{TestCredentials: true}).Metadata().Category; c != CategoryBruteforce {
+		t.Errorf("开启口令测试后类别 = %s，期望 %s", c, CategoryBruteforce)
+	}
+}
+
+func FuzzReadRESP(f *testing.F) {
+	f.Add("+PONG\r\n")
+	f.Add("*2\r\n$3\r\ndir\r\n$4\r\n/tmp\r\n")
+	f.Add("$-1\r\n")
+	f.Add("-ERR\r\n")
+	f.Add("*1\r\n*1\r\n*1\r\n+x\r\n")
+	f.Fuzz(func(t *testing.T, input string) {
+		reply, err := readRESP(bufio.NewReader(strings.NewReader(input)), 0)
+		if err != nil {
+			return
+		}
+		switch reply.(type) {
+		case string, []string:
+		default:
+			t.Errorf("readRESP(%q) 返回了 %T", input, reply)
+		}
+	})
+}
@@ -0,0 +1,821 @@
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// 脚本插件的限制
+const (
+	scriptMaxSteps    = 100_000_000 // 单次扫描最多执行的 Starlark 指令数
+	scriptMaxConns    = 32          // 单次扫描最多建立的连接数
+	scriptMaxRead     = 1 << 20     // 单次读取或HTTP响应的上限
+	scriptOutputLimit = 16 << 10    // print 输出的保留上限
+)
+
+// scriptOptions 脚本的语法选项
+var scriptOptions = &syntax.FileOptions{Set: true, While: true, TopLevelControl: true}
+
+// ScriptPlugin 使用 Starlark 编写的插件（.star 文件）
+//
+//	name = "redis-ping"
+//	description = "检测未授权访问的Redis"
+//	services = ["redis"]
+//	ports = [6379]
+//	category = "safe"
+//
+//	def scan(target, port, timeout):
+//	    conn = tcp.connect()
+//	    conn.send("PING\r\n")
+//	    if re.search(r"^\+PONG", conn.recv()):
+//	        return result(vulnerable = True, severity = "high", details = "无需认证")
+//	    return result(details = "需要认证")
+//
+// 脚本只能访问被扫描的目标：
+//
+//	tcp.connect(port=端口, tls=False)     返回连接，有 send(data)、recv(n=4096)、close()
+//	udp.connect(port=端口)                同上
+//	http.request(method, path, port=端口, tls=None, headers={}, body="", follow_redirects=False)
+//	http.get(path, ...)、http.post(path, body, ...)
+//	                                      tls 为 None 时用 TLS 握手判断端口使用 http 还是 https
+//	                                      返回 status、headers（小写名称）、body、url
+//	re.search(pattern, text)              返回 (整体, 分组...) 或 None
+//	re.findall(pattern, text)             有分组时返回第一个分组的列表
+//	re.match(pattern, text)               返回 True/False
+//	result(vulnerable, details, severity, evidence, remediation, findings=[])
+//	finding(id, title, severity="info", cvss=0, evidence="", remediation="")
+//	                                      result 的 findings 中的单个问题
+//
+// 可选的全局变量 category（safe、intrusive、bruteforce）、author、references 作为插件元数据
+//
+// load 不可用，脚本无法访问文件系统；print 的输出在扫描失败时附加到错误中
+type ScriptPlugin struct {
+	Path        string // 脚本文件
+	name        string
+	description string
+	meta        Metadata
+	scan        starlark.Callable
+}
+
+// LoadScripts 加载目录（含子目录）下的所有 .star 脚本
+// 单个脚本出错时跳过该脚本，返回其余脚本和所有错误
+func LoadScripts(dir string) ([]*ScriptPlugin, error) {
+	var plugins []*ScriptPlugin
+	var errs []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isScriptFile(path) {
+			return nil
+		}
+		p, err := LoadScript(path)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		plugins = append(plugins, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取插件目录失败: %v", err)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// isScriptFile 按扩展名判断是否为脚本
+func isScriptFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".star"
+}
+
+// LoadScript 加载一个脚本，读取其中的 name、description、元数据和 scan
+func LoadScript(path string) (*ScriptPlugin, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取脚本失败: %v", err)
+	}
+
+	thread := &starlark.Thread{Name: path, Print: func(*starlark.Thread, string) {}}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	globals, err := starlark.ExecFileOptions(scriptOptions, thread, path, src, scriptBuiltins)
+	if err != nil {
+		return nil, fmt.Errorf("加载脚本 %s 失败: %v", path, err)
+	}
+
+	p := &ScriptPlugin{Path: path}
+	if err := p.readGlobals(globals); err != nil {
+		return nil, fmt.Errorf("脚本 %s 无效: %v", path, err)
+	}
+	return p, nil
+}
+
+// readGlobals 读取脚本定义的插件信息
+func (p *ScriptPlugin) readGlobals(globals starlark.StringDict) error {
+	name, ok := starlark.AsString(globals["name"])
+	if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("name 必须是不含空白字符的字符串")
+	}
+	p.name = name
+
+	if v, found := globals["description"]; found {
+		if p.description, ok = starlark.AsString(v); !ok {
+			return fmt.Errorf("description 必须是字符串")
+		}
+	}
+	if err := readStringList(globals, "services", &p.meta.Services); err != nil {
+		return err
+	}
+	if err := readStringList(globals, "references", &p.meta.References); err != nil {
+		return err
+	}
+	if v, found := globals["ports"]; found {
+		if err := starlarkList(v, func(item starlark.Value) error {
+			port, err := starlark.AsInt32(item)
+			if err != nil {
+				return fmt.Errorf("ports 必须是整数列表")
+			}
+			p.meta.Ports = append(p.meta.Ports, port)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, found := globals["author"]; found {
+		if p.meta.Author, ok = starlark.AsString(v); !ok {
+			return fmt.Errorf("author 必须是字符串")
+		}
+	}
+	var category string
+	if v, found := globals["category"]; found {
+		if category, ok = starlark.AsString(v); !ok {
+			return fmt.Errorf("category 必须是字符串")
+		}
+	}
+	category, err := validCategory(category)
+	if err != nil {
+		return err
+	}
+	p.meta.Category = category
+
+	scan, ok := globals["scan"].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("缺少 scan(target, port, timeout) 函数")
+	}
+	p.scan = scan
+	return nil
+}
+
+// readStringList 读取字符串列表类型的全局变量
+func readStringList(globals starlark.StringDict, name string, dst *[]string) error {
+	v, found := globals[name]
+	if !found {
+		return nil
+	}
+	return starlarkList(v, func(item starlark.Value) error {
+		s, ok := starlark.AsString(item)
+		if !ok {
+			return fmt.Errorf("%s 必须是字符串列表", name)
+		}
+		*dst = append(*dst, s)
+		return nil
+	})
+}
+
+// starlarkList 遍历列表或元组
+func starlarkList(v starlark.Value, fn func(starlark.Value) error) error {
+	iterable, ok := v.(starlark.Indexable)
+	if !ok {
+		return fmt.Errorf("%s 不是列表", v.Type())
+	}
+	for i := 0; i < iterable.Len(); i++ {
+		if err := fn(iterable.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name 插件名称
+func (p *ScriptPlugin) Name() string {
+	return p.name
+}
+
+// Description 插件描述
+func (p *ScriptPlugin) Description() string {
+	if p.description == "" {
+		return "脚本插件: " + filepath.Base(p.Path)
+	}
+	return p.description
+}
+
+// Metadata 插件元数据
+func (p *ScriptPlugin) Metadata() Metadata {
+	return p.meta
+}
+
+// Scan 执行扫描
+func (p *ScriptPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 调用脚本的 scan 函数
+// 与外部插件相同，脚本最长运行 timeout 的 10 倍（至少 10 秒）
+func (p *ScriptPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, max(10*timeout, minScanTimeout))
+	defer cancel()
+
+	state := &scriptScan{ctx: ctx, target: target, port: port, timeout: timeout}
+	defer state.closeAll()
+
+	output := &tailBuffer{limit: scriptOutputLimit}
+	thread := &starlark.Thread{
+		Name:  p.name,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(output, msg) },
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	thread.SetLocal(scriptScanKey, state)
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel("超时或被取消")
+		state.closeAll()
+	})
+	defer stop()
+
+	args := starlark.Tuple{starlark.String(target), starlark.MakeInt(port), starlark.Float(timeout.Seconds())}
+	v, err := starlark.Call(thread, p.scan, args, nil)
+	if err != nil {
+		// 只保留脚本中出错的位置，不输出完整的调用栈
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			for i := range len(evalErr.CallStack) {
+				if frame := evalErr.CallStack.At(i); frame.Pos.IsValid() {
+					err = fmt.Errorf("%s: %s", frame.Pos, evalErr.Msg)
+					break
+				}
+			}
+		}
+		return Result{Vulnerable: false}, withOutput(fmt.Errorf("脚本插件 %s 执行失败: %v", p.name, err), "print", output)
+	}
+	result, err := toResult(v)
+	if err != nil {
+		return Result{Vulnerable: false}, fmt.Errorf("脚本插件 %s: %v", p.name, err)
+	}
+	return result, nil
+}
+
+// toResult 把 scan 的返回值转换为 Result，返回 None 表示未发现问题
+func toResult(v starlark.Value) (Result, error) {
+	if v == starlark.None {
+		return Result{Vulnerable: false}, nil
+	}
+	s, ok := v.(*starlarkstruct.Struct)
+	if !ok || s.Constructor() != resultConstructor {
+		return Result{}, fmt.Errorf("scan 必须返回 result(...) 或 None，实际返回 %s", v.Type())
+	}
+
+	var r Result
+	if attr, _ := s.Attr("vulnerable"); attr != nil {
+		r.Vulnerable = bool(attr.Truth())
+	}
+	structStrings(s, map[string]*string{
+		"details":     &r.Details,
+		"severity":    &r.Severity,
+		"evidence":    &r.Evidence,
+		"remediation": &r.Remediation,
+	})
+	if attr, _ := s.Attr("findings"); attr != nil {
+		if err := starlarkList(attr, func(item starlark.Value) error {
+			fv, ok := item.(*starlarkstruct.Struct)
+			if !ok || fv.Constructor() != findingConstructor {
+				return fmt.Errorf("findings 必须是 finding(...) 的列表，实际包含 %s", item.Type())
+			}
+			var f Finding
+			structStrings(fv, map[string]*string{
+				"id":          &f.ID,
+				"title":       &f.Title,
+				"severity":    &f.Severity,
+				"evidence":    &f.Evidence,
+				"remediation": &f.Remediation,
+			})
+			if attr, _ := fv.Attr("cvss"); attr != nil {
+				if cvss, ok := starlark.AsFloat(attr); ok {
+					f.CVSS = cvss
+				}
+			}
+			r.Findings = append(r.Findings, f)
+			return nil
+		}); err != nil {
+			return Result{}, err
+		}
+	}
+	return r, nil
+}
+
+// structStrings 读取结构体的字符串字段
+func structStrings(s *starlarkstruct.Struct, fields map[string]*string) {
+	for name, field := range fields {
+		if attr, _ := s.Attr(name); attr != nil {
+			*field, _ = starlark.AsString(attr)
+		}
+	}
+}
+
+// scriptScanKey 线程中保存扫描状态的键
+const scriptScanKey = "netscanner.scan"
+
+// scriptScan 一次脚本扫描的状态，网络函数只能访问 target
+type scriptScan struct {
+	ctx     context.Context
+	target  string
+	port    int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	conns   []net.Conn
+	opened  int
+	schemes map[int]string // 已探测的端口协议，http 或 https
+}
+
+// scanState 取出当前扫描的状态，在 scan 之外调用网络函数时返回错误
+func scanState(thread *starlark.Thread, fn string) (*scriptScan, error) {
+	state, ok := thread.Local(scriptScanKey).(*scriptScan)
+	if !ok {
+		return nil, fmt.Errorf("%s: 只能在 scan() 中调用", fn)
+	}
+	return state, nil
+}
+
+// track 记录新连接，超过上限时拒绝
+func (s *scriptScan) track(fn string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.opened >= scriptMaxConns {
+		return fmt.Errorf("%s: 连接数超过上限 %d", fn, scriptMaxConns)
+	}
+	s.opened++
+	return nil
+}
+
+// add 保存连接，扫描结束时统一关闭
+func (s *scriptScan) add(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns = append(s.conns, conn)
+}
+
+// closeAll 关闭脚本打开的所有连接
+func (s *scriptScan) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// scheme 探测端口使用 http 还是 https，结果在本次扫描中缓存
+func (s *scriptScan) scheme(fn string, port int) (string, error) {
+	if port == 0 {
+		port = s.port
+	}
+	s.mu.Lock()
+	scheme, ok := s.schemes[port]
+	s.mu.Unlock()
+	if ok {
+		return scheme, nil
+	}
+
+	if err := s.track(fn); err != nil {
+		return "", err
+	}
+	scheme, err := detectHTTPScheme(s.ctx, s.address(port), s.timeout)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", fn, err)
+	}
+	s.mu.Lock()
+	if s.schemes == nil {
+		s.schemes = make(map[int]string)
+	}
+	s.schemes[port] = scheme
+	s.mu.Unlock()
+	return scheme, nil
+}
+
+// address 目标上某个端口的地址，0 表示被扫描的端口
+func (s *scriptScan) address(port int) string {
+	if port == 0 {
+		port = s.port
+	}
+	return net.JoinHostPort(s.target, strconv.Itoa(port))
+}
+
+// resultConstructor、findingConstructor result(...) 和 finding(...) 返回的结构体的构造器
+var (
+	resultConstructor  = starlark.String("result")
+	findingConstructor = starlark.String("finding")
+)
+
+// scriptBuiltins 脚本可用的内置函数和模块
+var scriptBuiltins = starlark.StringDict{
+	"result":  starlark.NewBuiltin("result", scriptResult),
+	"finding": starlark.NewBuiltin("finding", scriptFinding),
+	"tcp": &starlarkstruct.Module{Name: "tcp", Members: starlark.StringDict{
+		"connect": starlark.NewBuiltin("tcp.connect", tcpConnect),
+	}},
+	"udp": &starlarkstruct.Module{Name: "udp", Members: starlark.StringDict{
+		"connect": starlark.NewBuiltin("udp.connect", udpConnect),
+	}},
+	"http": &starlarkstruct.Module{Name: "http", Members: starlark.StringDict{
+		"request": starlark.NewBuiltin("http.request", httpRequest),
+		"get":     starlark.NewBuiltin("http.get", httpMethod(http.MethodGet)),
+		"post":    starlark.NewBuiltin("http.post", httpMethod(http.MethodPost)),
+	}},
+	"re": &starlarkstruct.Module{Name: "re", Members: starlark.StringDict{
+		"search":  starlark.NewBuiltin("re.search", reSearch),
+		"findall": starlark.NewBuiltin("re.findall", reFindall),
+		"match":   starlark.NewBuiltin("re.match", reMatch),
+	}},
+}
+
+// scriptResult result(vulnerable=False, details="", severity="", evidence="", remediation="", findings=[])
+func scriptResult(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var vulnerable bool
+	var details, severity, evidence, remediation string
+	var findings starlark.Value = starlark.NewList(nil)
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"vulnerable?", &vulnerable, "details?", &details, "severity?", &severity,
+		"evidence?", &evidence, "remediation?", &remediation, "findings?", &findings); err != nil {
+		return nil, err
+	}
+	if _, ok := findings.(starlark.Indexable); !ok {
+		return nil, fmt.Errorf("%s: findings 必须是列表", b.Name())
+	}
+	if vulnerable && severity == "" {
+		severity = "medium"
+	}
+	return starlarkstruct.FromStringDict(resultConstructor, starlark.StringDict{
+		"vulnerable":  starlark.Bool(vulnerable),
+		"details":     starlark.String(details),
+		"severity":    starlark.String(severity),
+		"evidence":    starlark.String(evidence),
+		"remediation": starlark.String(remediation),
+		"findings":    findings,
+	}), nil
+}
+
+// scriptFinding finding(id, title, severity="info", cvss=0, evidence="", remediation="")
+func scriptFinding(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id, title, evidence, remediation string
+	severity := "info"
+	var cvss starlark.Value = starlark.MakeInt(0)
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"id", &id, "title", &title, "severity?", &severity, "cvss?", &cvss,
+		"evidence?", &evidence, "remediation?", &remediation); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, fmt.Errorf("%s: id 不能为空", b.Name())
+	}
+	if severityRank(severity) == 0 {
+		return nil, fmt.Errorf("%s: 不支持的风险等级 %q", b.Name(), severity)
+	}
+	score, ok := starlark.AsFloat(cvss)
+	if !ok || score < 0 || score > 10 {
+		return nil, fmt.Errorf("%s: cvss 必须是 0 到 10 之间的数字", b.Name())
+	}
+	return starlarkstruct.FromStringDict(findingConstructor, starlark.StringDict{
+		"id":          starlark.String(id),
+		"title":       starlark.String(title),
+		"severity":    starlark.String(severity),
+		"cvss":        starlark.Float(score),
+		"evidence":    starlark.String(evidence),
+		"remediation": starlark.String(remediation),
+	}), nil
+}
+
+// tcpConnect tcp.connect(port=被扫描的端口, tls=False)
+func tcpConnect(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var port int
+	var useTLS bool
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "port?", &port, "tls?", &useTLS); err != nil {
+		return nil, err
+	}
+	state, err := scanState(thread, b.Name())
+	if err != nil {
+		return nil, err
+	}
+	if err := state.track(b.Name()); err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: state.timeout}
+	var conn net.Conn
+	if useTLS {
+		tlsDialer := tls.Dialer{NetDialer: &dialer, Config: &tls.Config{InsecureSkipVerify: true}}
+		conn, err = tlsDialer.DialContext(state.ctx, "tcp", state.address(port))
+	} else {
+		conn, err = dialer.DialContext(state.ctx, "tcp", state.address(port))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	state.add(conn)
+	return &scriptConn{conn: conn, timeout: state.timeout, kind: "tcp"}, nil
+}
+
+// udpConnect udp.connect(port=被扫描的端口)
+func udpConnect(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var port int
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "port?", &port); err != nil {
+		return nil, err
+	}
+	state, err := scanState(thread, b.Name())
+	if err != nil {
+		return nil, err
+	}
+	if err := state.track(b.Name()); err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: state.timeout}
+	conn, err := dialer.DialContext(state.ctx, "udp", state.address(port))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	state.add(conn)
+	return &scriptConn{conn: conn, timeout: state.timeout, kind: "udp"}, nil
+}
+
+// scriptConn 脚本中的连接对象
+type scriptConn struct {
+	conn    net.Conn
+	timeout time.Duration
+	kind    string
+}
+
+func (c *scriptConn) String() string        { return fmt.Sprintf("<%s conn %s>", c.kind, c.conn.RemoteAddr()) }
+func (c *scriptConn) Type() string          { return c.kind + "_conn" }
+func (c *scriptConn) Freeze()               {}
+func (c *scriptConn) Truth() starlark.Bool  { return starlark.True }
+func (c *scriptConn) Hash() (uint32, error) { return 0, fmt.Errorf("%s 不可哈希", c.Type()) }
+func (c *scriptConn) AttrNames() []string   { return []string{"close", "recv", "send"} }
+
+// Attr 连接的方法
+func (c *scriptConn) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "send":
+		return starlark.NewBuiltin("send", c.send), nil
+	case "recv":
+		return starlark.NewBuiltin("recv", c.recv), nil
+	case "close":
+		return starlark.NewBuiltin("close", c.close), nil
+	}
+	return nil, nil
+}
+
+// send conn.send(data)，返回写入的字节数
+func (c *scriptConn) send(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var data starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &data); err != nil {
+		return nil, err
+	}
+	var payload []byte
+	switch v := data.(type) {
+	case starlark.String:
+		payload = []byte(v)
+	case starlark.Bytes:
+		payload = []byte(v)
+	default:
+		return nil, fmt.Errorf("send: 需要 string 或 bytes，实际为 %s", data.Type())
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	n, err := c.conn.Write(payload)
+	if err != nil {
+		return nil, fmt.Errorf("send: %v", err)
+	}
+	return starlark.MakeInt(n), nil
+}
+
+// recv conn.recv(n=4096)，超时或连接关闭时返回空字符串
+func (c *scriptConn) recv(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	size := 4096
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "n?", &size); err != nil {
+		return nil, err
+	}
+	size = min(max(size, 1), scriptMaxRead)
+
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	buffer := make([]byte, size)
+	n, err := c.conn.Read(buffer)
+	if err != nil && n == 0 {
+		var netErr net.Error
+		if errors.Is(err, io.EOF) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return starlark.String(""), nil
+		}
+		return nil, fmt.Errorf("recv: %v", err)
+	}
+	return starlark.String(buffer[:n]), nil
+}
+
+// close conn.close()
+func (c *scriptConn) close(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	c.conn.Close()
+	return starlark.None, nil
+}
+
+// httpMethod http.get(path, ...)、http.post(path, body, ...)
+func httpMethod(method string) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+	return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return httpRequest(thread, b, append(starlark.Tuple{starlark.String(method)}, args...), kwargs)
+	}
+}
+
+// httpRequest http.request(method, path, port=被扫描的端口, tls=None, headers={}, body="", follow_redirects=False)
+// tls 为 None 时自动探测协议；重定向只跟随到同一目标
+func httpRequest(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var method, path, body string
+	var port int
+	var follow bool
+	var useTLS starlark.Value = starlark.None
+	var headers *starlark.Dict
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"method", &method, "path", &path, "body?", &body, "port?", &port, "tls?", &useTLS,
+		"headers?", &headers, "follow_redirects?", &follow); err != nil {
+		return nil, err
+	}
+	state, err := scanState(thread, b.Name())
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("%s: path 必须以 / 开头", b.Name())
+	}
+
+	var scheme string
+	switch v := useTLS.(type) {
+	case starlark.NoneType:
+		if scheme, err = state.scheme(b.Name(), port); err != nil {
+			return nil, err
+		}
+	case starlark.Bool:
+		scheme = "http"
+		if v {
+			scheme = "https"
+		}
+	default:
+		return nil, fmt.Errorf("%s: tls 必须是 True、False 或 None", b.Name())
+	}
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(state.ctx, strings.ToUpper(method), scheme+"://"+state.address(port)+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	if headers != nil {
+		for _, item := range headers.Items() {
+			name, ok1 := starlark.AsString(item[0])
+			value, ok2 := starlark.AsString(item[1])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("%s: headers 必须是字符串字典", b.Name())
+			}
+			req.Header.Set(name, value)
+		}
+		if host := req.Header.Get("Host"); host != "" {
+			req.Host = host
+		}
+	}
+
+	// 请求和重定向建立的每个连接都与 tcp.connect 一样计入上限，并在扫描结束时关闭；
+	// 不保持连接，请求结束后连接即关闭
+	dialer := net.Dialer{Timeout: state.timeout}
+	client := &http.Client{
+		Timeout: state.timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if err := state.track(b.Name()); err != nil {
+					return nil, err
+				}
+				conn, err := dialer.DialContext(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				state.add(conn)
+				return conn, nil
+			},
+		},
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			if !follow || r.URL.Hostname() != state.target || len(via) >= 10 {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, scriptMaxRead))
+
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	respHeaders := starlark.NewDict(len(names))
+	for _, name := range names {
+		respHeaders.SetKey(starlark.String(strings.ToLower(name)), starlark.String(strings.Join(resp.Header.Values(name), ", ")))
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("http_response"), starlark.StringDict{
+		"status":  starlark.MakeInt(resp.StatusCode),
+		"headers": respHeaders,
+		"body":    starlark.String(data),
+		"url":     starlark.String(resp.Request.URL.String()),
+	}), nil
+}
+
+// compileScriptRegexp 编译脚本中的正则表达式
+func compileScriptRegexp(fn, pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: 正则表达式无效: %v", fn, err)
+	}
+	return re, nil
+}
+
+// reSearch re.search(pattern, text)，返回 (整体, 分组...) 或 None
+func reSearch(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &text); err != nil {
+		return nil, err
+	}
+	re, err := compileScriptRegexp(b.Name(), pattern)
+	if err != nil {
+		return nil, err
+	}
+	groups := re.FindStringSubmatch(text)
+	if groups == nil {
+		return starlark.None, nil
+	}
+	tuple := make(starlark.Tuple, len(groups))
+	for i, g := range groups {
+		tuple[i] = starlark.String(g)
+	}
+	return tuple, nil
+}
+
+// reFindall re.findall(pattern, text)，有分组时返回第一个分组
+func reFindall(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &text); err != nil {
+		return nil, err
+	}
+	re, err := compileScriptRegexp(b.Name(), pattern)
+	if err != nil {
+		return nil, err
+	}
+	var values []starlark.Value
+	for _, groups := range re.FindAllStringSubmatch(text, -1) {
+		values = append(values, starlark.String(groups[min(1, len(groups)-1)]))
+	}
+	return starlark.NewList(values), nil
+}
+
+// reMatch re.match(pattern, text)，返回是否匹配
+func reMatch(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &text); err != nil {
+		return nil, err
+	}
+	re, err := compileScriptRegexp(b.Name(), pattern)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Bool(re.MatchString(text)), nil
+}
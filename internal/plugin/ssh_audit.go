@@ -0,0 +1,513 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHAuditPlugin SSH服务审计插件
+// 解析版本信息和服务器的 KEXINIT，列出支持的算法并标记弱算法，记录主机密钥指纹。
+// TestCredentials 为 true 时额外尝试少量常见口令，默认关闭以免触发账号锁定
+type SSHAuditPlugin struct {
+	TestCredentials bool
+}
+
+// sshClientVersion 扫描时发送的客户端版本
+const sshClientVersion = "SSH-2.0-NetSecScanner"
+
+// SSH 报文的限制
+const (
+	sshMaxBannerLines = 32    // 版本行之前允许的其他行数（RFC 4253 4.2）
+	sshMaxPacket      = 35000 // RFC 4253 6.1 要求支持的最大报文长度
+	sshMsgKexInit     = 20
+)
+
+// sshKexInit 服务器 KEXINIT 中的算法列表，两个方向分开列出
+type sshKexInit struct {
+	KexAlgos                []string
+	HostKeyAlgos            []string
+	CiphersClientServer     []string
+	CiphersServerClient     []string
+	MACsClientServer        []string
+	MACsServerClient        []string
+	CompressionClientServer []string
+	CompressionServerClient []string
+}
+
+// sshWeakAlgorithm 弱算法的匹配规则
+type sshWeakAlgorithm struct {
+	match  func(string) bool
+	reason string
+}
+
+// 各类弱算法，依据 RFC 9142、RFC 8758 和 OpenSSH 的弃用列表
+var (
+	sshWeakKex = []sshWeakAlgorithm{
+		{func(a string) bool { return a == "diffie-hellman-group1-sha1" }, "1024 位 DH 组"},
+		{func(a string) bool { return strings.HasSuffix(a, "-sha1") }, "SHA1"},
+		{func(a string) bool { return strings.HasPrefix(a, "gss-") && strings.Contains(a, "sha1") }, "SHA1"},
+	}
+	sshWeakHostKeys = []sshWeakAlgorithm{
+		{func(a string) bool { return strings.HasPrefix(a, "ssh-dss") }, "DSA"},
+		{func(a string) bool { return a == "ssh-rsa" || a == "ssh-rsa-cert-v01@openssh.com" }, "SHA1 签名"},
+	}
+	sshWeakCiphers = []sshWeakAlgorithm{
+		{func(a string) bool { return strings.HasSuffix(a, "-cbc") || a == "rijndael-cbc@lysator.liu.se" }, "CBC 模式"},
+		{func(a string) bool { return strings.HasPrefix(a, "arcfour") }, "RC4"},
+		{func(a string) bool {
+			return strings.HasPrefix(a, "3des") || strings.HasPrefix(a, "blowfish") || strings.HasPrefix(a, "cast128")
+		}, "64 位分组"},
+		{func(a string) bool { return a == "none" }, "不加密"},
+	}
+	sshWeakMACs = []sshWeakAlgorithm{
+		{func(a string) bool { return strings.Contains(a, "md5") }, "MD5"},
+		{func(a string) bool { return strings.HasPrefix(a, "hmac-sha1") }, "SHA1"},
+		{func(a string) bool { return strings.Contains(a, "-96") || strings.HasPrefix(a, "umac-64") }, "截断的 MAC"},
+		{func(a string) bool { return a == "none" }, "无完整性保护"},
+	}
+)
+
+// sshCredentials 开启口令测试时尝试的账号
+var sshCredentials = []struct {
+	username string
+	password string
+}{
+	{"root", "root"},
+	{"root", "toor"},
+	{"root", "123456"},
+	{"root", "password"},
+	{"admin", "admin"},
+	{"admin", "123456"},
+	{"test", "test"},
+	{"pi", "raspberry"},
+}
+
+// Name 插件名称
+func (p *SSHAuditPlugin) Name() string {
+	return "ssh-audit"
+}
+
+// Description 插件描述
+func (p *SSHAuditPlugin) Description() string {
+	if p.TestCredentials {
+		return "审计SSH服务的算法和主机密钥，并测试弱口令"
+	}
+	return "审计SSH服务的算法和主机密钥"
+}
+
+// Metadata 插件元数据，开启口令测试时属于 bruteforce 类别
+func (p *SSHAuditPlugin) Metadata() Metadata {
+	category := CategorySafe
+	if p.TestCredentials {
+		category = CategoryBruteforce
+	}
+	return Metadata{
+		Services: []string{"ssh"},
+		Ports:    []int{22},
+		Category: category,
+		References: []string{
+			"https://www.rfc-editor.org/rfc/rfc9142",
+			"https://www.openssh.com/legacy.html",
+		},
+	}
+}
+
+// Scan 执行扫描
+func (p *SSHAuditPlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 执行可取消的扫描
+func (p *SSHAuditPlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	banner, kex, err := readSSHKexInit(ctx, address, timeout)
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+
+	findings := []Finding{sshVersionFinding(banner)}
+	if strings.HasPrefix(banner, "SSH-1.") {
+		findings = append(findings, Finding{
+			ID:          "ssh-protocol-v1",
+			Title:       "服务器支持 SSH 协议 1",
+			Severity:    "high",
+			CVSS:        7.4,
+			Evidence:    banner,
+			Remediation: "关闭 SSH 协议 1（OpenSSH 设置 Protocol 2）",
+		})
+	}
+	if len(kex.KexAlgos) > 0 {
+		findings = append(findings, Finding{
+			ID:       "ssh-algorithms",
+			Title:    fmt.Sprintf("支持 %d 种密钥交换、%d 种主机密钥、%d 种加密和 %d 种 MAC 算法", len(kex.KexAlgos), len(kex.HostKeyAlgos), len(kex.Ciphers()), len(kex.MACs())),
+			Severity: "info",
+			Evidence: kex.String(),
+		})
+		findings = append(findings, weakAlgorithmFindings(kex)...)
+	}
+
+	hostKeys, err := collectHostKeys(ctx, address, kex.HostKeyAlgos, timeout)
+	if err != nil && ctx.Err() != nil {
+		return Result{Vulnerable: false}, ctx.Err()
+	}
+	findings = append(findings, hostKeys...)
+
+	if p.TestCredentials {
+		weak, err := testSSHPasswords(ctx, address, timeout)
+		if err != nil && ctx.Err() != nil {
+			return Result{Vulnerable: false}, ctx.Err()
+		}
+		if weak != nil {
+			findings = append(findings, *weak)
+		}
+	}
+
+	return Result{Evidence: banner, Findings: findings}, nil
+}
+
+// sshVersionFinding 解析版本行：SSH-协议版本-软件版本 注释
+func sshVersionFinding(banner string) Finding {
+	title := "SSH 版本: " + banner
+	if rest, ok := strings.CutPrefix(banner, "SSH-"); ok {
+		proto, software, _ := strings.Cut(rest, "-")
+		software, comment, _ := strings.Cut(software, " ")
+		title = fmt.Sprintf("SSH 协议 %s，软件 %s", proto, software)
+		if comment != "" {
+			title += "（" + comment + "）"
+		}
+	}
+	return Finding{ID: "ssh-version", Title: title, Severity: "info", Evidence: banner}
+}
+
+// weakAlgorithmFindings 按类别检查弱算法，每类一个问题
+func weakAlgorithmFindings(kex *sshKexInit) []Finding {
+	checks := []struct {
+		id          string
+		kind        string
+		offered     []string
+		rules       []sshWeakAlgorithm
+		severity    string
+		cvss        float64
+		remediation string
+	}{
+		{"ssh-weak-kex", "密钥交换", kex.KexAlgos, sshWeakKex, "medium", 5.9,
+			"禁用 diffie-hellman-group1-sha1 和基于 SHA1 的密钥交换，优先使用 curve25519-sha256、sntrup761x25519-sha512"},
+		{"ssh-weak-hostkey", "主机密钥", kex.HostKeyAlgos, sshWeakHostKeys, "medium", 5.3,
+			"删除 DSA 主机密钥，RSA 密钥只允许 rsa-sha2-256/rsa-sha2-512 签名，优先使用 ssh-ed25519"},
+		{"ssh-weak-cipher", "加密", kex.Ciphers(), sshWeakCiphers, "low", 3.7,
+			"禁用 CBC、arcfour、3des 等算法，只保留 chacha20-poly1305、aes-gcm 和 aes-ctr"},
+		{"ssh-weak-mac", "MAC", kex.MACs(), sshWeakMACs, "low", 3.7,
+			"禁用 hmac-sha1、hmac-md5 和截断的 MAC，优先使用 hmac-sha2-256-etm@openssh.com 等 ETM 算法"},
+	}
+
+	var findings []Finding
+	for _, c := range checks {
+		var weak []string
+		for _, algo := range c.offered {
+			for _, rule := range c.rules {
+				if rule.match(algo) {
+					weak = append(weak, fmt.Sprintf("%s（%s）", algo, rule.reason))
+					break
+				}
+			}
+		}
+		if len(weak) == 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			ID:          c.id,
+			Title:       fmt.Sprintf("支持 %d 种弱%s算法", len(weak), c.kind),
+			Severity:    c.severity,
+			CVSS:        c.cvss,
+			Evidence:    strings.Join(weak, "\n"),
+			Remediation: c.remediation,
+		})
+	}
+	return findings
+}
+
+// readSSHKexInit 发送客户端版本，读取服务器的版本行和 KEXINIT，不进行密钥交换
+func readSSHKexInit(ctx context.Context, address string, timeout time.Duration) (string, *sshKexInit, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(2 * timeout))
+	if _, err := conn.Write([]byte(sshClientVersion + "\r\n")); err != nil {
+		return "", nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	var banner string
+	for range sshMaxBannerLines {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", nil, fmt.Errorf("读取SSH版本失败: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			banner = line
+			break
+		}
+	}
+	if banner == "" {
+		return "", nil, fmt.Errorf("不是SSH服务")
+	}
+	// SSH-1 服务器不发送 KEXINIT
+	if strings.HasPrefix(banner, "SSH-1.") && !strings.HasPrefix(banner, "SSH-1.99-") {
+		return banner, &sshKexInit{}, nil
+	}
+
+	payload, err := readSSHPacket(reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("读取KEXINIT失败: %v", err)
+	}
+	kex, err := parseSSHKexInit(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("解析KEXINIT失败: %v", err)
+	}
+	return banner, kex, nil
+}
+
+// readSSHPacket 读取一个未加密的二进制报文，返回载荷
+func readSSHPacket(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	padding := uint32(header[4])
+	if length < padding+1 || length > sshMaxPacket {
+		return nil, fmt.Errorf("报文长度无效: %d", length)
+	}
+	packet := make([]byte, length-1)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+	return packet[:length-1-padding], nil
+}
+
+// parseSSHKexInit 解析 KEXINIT 载荷（RFC 4253 7.1）
+func parseSSHKexInit(payload []byte) (*sshKexInit, error) {
+	if len(payload) < 17 || payload[0] != sshMsgKexInit {
+		return nil, fmt.Errorf("不是KEXINIT报文")
+	}
+	data := payload[17:] // 消息类型和 16 字节 cookie
+
+	kex := &sshKexInit{}
+	for _, list := range []*[]string{
+		&kex.KexAlgos, &kex.HostKeyAlgos,
+		&kex.CiphersClientServer, &kex.CiphersServerClient,
+		&kex.MACsClientServer, &kex.MACsServerClient,
+		&kex.CompressionClientServer, &kex.CompressionServerClient,
+	} {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("算法列表不完整")
+		}
+		n := binary.BigEndian.Uint32(data)
+		if uint32(len(data)-4) < n {
+			return nil, fmt.Errorf("算法列表不完整")
+		}
+		if n > 0 {
+			*list = strings.Split(string(data[4:4+n]), ",")
+		}
+		data = data[4+n:]
+	}
+	return kex, nil
+}
+
+// String 按类别列出算法
+func (k *sshKexInit) String() string {
+	var b strings.Builder
+	for _, line := range []struct {
+		label string
+		algos []string
+	}{
+		{"kex", k.KexAlgos},
+		{"hostkey", k.HostKeyAlgos},
+		{"cipher", k.Ciphers()},
+		{"mac", k.MACs()},
+		{"compression", mergeAlgorithms(k.CompressionClientServer, k.CompressionServerClient)},
+	} {
+		fmt.Fprintf(&b, "%s: %s\n", line.label, strings.Join(line.algos, ","))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Ciphers 两个方向支持的加密算法
+func (k *sshKexInit) Ciphers() []string {
+	return mergeAlgorithms(k.CiphersClientServer, k.CiphersServerClient)
+}
+
+// MACs 两个方向支持的 MAC 算法
+func (k *sshKexInit) MACs() []string {
+	return mergeAlgorithms(k.MACsClientServer, k.MACsServerClient)
+}
+
+// mergeAlgorithms 合并两个算法列表并去重，保持顺序
+func mergeAlgorithms(a, b []string) []string {
+	merged := slices.Clone(a)
+	for _, algo := range b {
+		if !slices.Contains(merged, algo) {
+			merged = append(merged, algo)
+		}
+	}
+	return merged
+}
+
+// errHostKeyCaptured 取得主机密钥后中止握手
+var errHostKeyCaptured = errors.New("已取得主机密钥")
+
+// collectHostKeys 对每种密钥类型各握手一次，记录主机密钥指纹
+func collectHostKeys(ctx context.Context, address string, offered []string, timeout time.Duration) ([]Finding, error) {
+	supported := append(ssh.SupportedAlgorithms().HostKeys, ssh.InsecureAlgorithms().HostKeys...)
+
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, algo := range offered {
+		// rsa-sha2-256、rsa-sha2-512 和 ssh-rsa 是同一个密钥
+		keyType := algo
+		if strings.HasPrefix(algo, "rsa-sha2-") {
+			keyType = ssh.KeyAlgoRSA
+		}
+		if seen[keyType] || !slices.Contains(supported, algo) || strings.Contains(algo, "-cert-") {
+			continue
+		}
+		seen[keyType] = true
+
+		var key ssh.PublicKey
+		config := sshClientConfig(timeout)
+		config.HostKeyAlgorithms = []string{algo}
+		config.HostKeyCallback = func(_ string, _ net.Addr, k ssh.PublicKey) error {
+			key = k
+			return errHostKeyCaptured
+		}
+		sshHandshake(ctx, address, config, timeout)
+		if key == nil {
+			if ctx.Err() != nil {
+				return findings, ctx.Err()
+			}
+			// 服务器声明了该算法但握手失败时跳过
+			continue
+		}
+
+		title := "主机密钥 " + key.Type()
+		if bits := sshKeyBits(key); bits > 0 {
+			title += fmt.Sprintf("（%d 位）", bits)
+		}
+		findings = append(findings, Finding{
+			ID:       "ssh-hostkey-" + strings.TrimPrefix(key.Type(), "ssh-"),
+			Title:    title,
+			Severity: "info",
+			Evidence: fmt.Sprintf("%s %s\n%s", key.Type(), ssh.FingerprintSHA256(key), ssh.FingerprintLegacyMD5(key)),
+		})
+		if bits := sshKeyBits(key); key.Type() == ssh.KeyAlgoRSA && bits < 2048 {
+			findings = append(findings, Finding{
+				ID:          "ssh-weak-hostkey-size",
+				Title:       fmt.Sprintf("RSA 主机密钥只有 %d 位", bits),
+				Severity:    "medium",
+				CVSS:        5.3,
+				Evidence:    ssh.FingerprintSHA256(key),
+				Remediation: "重新生成至少 3072 位的 RSA 主机密钥，或改用 ssh-ed25519",
+			})
+		}
+	}
+	return findings, nil
+}
+
+// sshKeyBits RSA 密钥的位数，其他类型返回 0
+func sshKeyBits(key ssh.PublicKey) int {
+	if ck, ok := key.(ssh.CryptoPublicKey); ok {
+		if rk, ok := ck.CryptoPublicKey().(*rsa.PublicKey); ok {
+			return rk.N.BitLen()
+		}
+	}
+	return 0
+}
+
+// testSSHPasswords 逐个尝试常见口令，每次尝试使用新连接，返回第一个成功的账号
+func testSSHPasswords(ctx context.Context, address string, timeout time.Duration) (*Finding, error) {
+	for _, cred := range sshCredentials {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		config := sshClientConfig(timeout)
+		config.User = cred.username
+		config.Auth = []ssh.AuthMethod{ssh.Password(cred.password)}
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+
+		err := sshHandshake(ctx, address, config, timeout)
+		if err == nil {
+			return &Finding{
+				ID:          "ssh-weak-password",
+				Title:       fmt.Sprintf("发现弱口令: %s/%s", cred.username, cred.password),
+				Severity:    "critical",
+				CVSS:        9.8,
+				Evidence:    fmt.Sprintf("以 %s/%s 通过口令认证", cred.username, cred.password),
+				Remediation: "修改该账户的口令，并关闭口令认证（PasswordAuthentication no），改用公钥认证",
+			}, nil
+		}
+		// 服务器不接受口令认证时不再继续尝试
+		if strings.Contains(err.Error(), "no supported methods remain") && !strings.Contains(err.Error(), "password") {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// sshClientConfig 审计用的客户端配置，允许不安全的算法以便与老旧服务器握手
+func sshClientConfig(timeout time.Duration) *ssh.ClientConfig {
+	supported, insecure := ssh.SupportedAlgorithms(), ssh.InsecureAlgorithms()
+	return &ssh.ClientConfig{
+		Config: ssh.Config{
+			KeyExchanges: append(supported.KeyExchanges, insecure.KeyExchanges...),
+			Ciphers:      append(supported.Ciphers, insecure.Ciphers...),
+			MACs:         append(supported.MACs, insecure.MACs...),
+		},
+		ClientVersion: sshClientVersion,
+		Timeout:       timeout,
+	}
+}
+
+// sshHandshake 建立SSH连接并完成握手和认证，成功后立即断开
+func sshHandshake(ctx context.Context, address string, config *ssh.ClientConfig, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(3 * timeout))
+	client, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		return err
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		for ch := range chans {
+			ch.Reject(ssh.Prohibited, "")
+		}
+	}()
+	return client.Close()
+}
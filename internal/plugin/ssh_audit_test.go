@@ -0,0 +1,290 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildKexInit 按 RFC 4253 7.1 构造 KEXINIT 载荷，lists 依次为八个算法列表
+func buildKexInit(lists ...string) []byte {
+	payload := append([]byte{sshMsgKexInit}, make([]byte, 16)...)
+	for _, list := range lists {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(list)))
+		payload = append(payload, list...)
+	}
+	// first_kex_packet_follows 和保留字段
+	return append(payload, 0, 0, 0, 0, 0)
+}
+
+// buildSSHPacket 把载荷封装为未加密的二进制报文
+func buildSSHPacket(payload []byte, padding int) []byte {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+padding+1))
+	packet = append(packet, byte(padding))
+	packet = append(packet, payload...)
+	return append(packet, make([]byte, padding)...)
+}
+
+// openSSHKexInit 常见 OpenSSH 服务器的算法列表
+var openSSHKexInit = buildKexInit(
+	"curve25519-sha256,diffie-hellman-group14-sha1",
+	"rsa-sha2-512,ssh-ed25519",
+	"chacha20-poly1305@openssh.com,aes128-ctr",
+	"chacha20-poly1305@openssh.com,aes256-cbc",
+	"hmac-sha2-256-etm@openssh.com",
+	"hmac-sha2-256-etm@openssh.com,hmac-sha1",
+	"none,zlib@openssh.com",
+	"none",
+)
+
+func TestParseSSHKexInit(t *testing.T) {
+	kex, err := parseSSHKexInit(openSSHKexInit)
+	if err != nil {
+		t.Fatalf("parseSSHKexInit 返回错误: %v", err)
+	}
+	if !slices.Equal(kex.KexAlgos, []string{"curve25519-sha256", "diffie-hellman-group14-sha1"}) {
+		t.Errorf("KexAlgos = %v", kex.KexAlgos)
+	}
+	if !slices.Equal(kex.HostKeyAlgos, []string{"rsa-sha2-512", "ssh-ed25519"}) {
+		t.Errorf("HostKeyAlgos = %v", kex.HostKeyAlgos)
+	}
+	if got := kex.Ciphers(); !slices.Equal(got, []string{"chacha20-poly1305@openssh.com", "aes128-ctr", "aes256-cbc"}) {
+		t.Errorf("Ciphers() = %v", got)
+	}
+	if got := kex.MACs(); !slices.Equal(got, []string{"hmac-sha2-256-etm@openssh.com", "hmac-sha1"}) {
+		t.Errorf("MACs() = %v", got)
+	}
+	if !slices.Equal(kex.CompressionServerClient, []string{"none"}) {
+		t.Errorf("CompressionServerClient = %v", kex.CompressionServerClient)
+	}
+	if s := kex.String(); !strings.Contains(s, "compression: none,zlib@openssh.com") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestParseSSHKexInitMalformed(t *testing.T) {
+	// 列表长度超出剩余数据
+	overflow := append([]byte{sshMsgKexInit}, make([]byte, 16)...)
+	overflow = binary.BigEndian.AppendUint32(overflow, 0xffffffff)
+	overflow = append(overflow, "curve25519-sha256"...)
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "空载荷", payload: nil},
+		{name: "只有消息类型", payload: []byte{sshMsgKexInit}},
+		{name: "cookie不完整", payload: append([]byte{sshMsgKexInit}, make([]byte, 10)...)},
+		{name: "消息类型错误", payload: append([]byte{21}, openSSHKexInit[1:]...)},
+		{name: "缺少算法列表", payload: append([]byte{sshMsgKexInit}, make([]byte, 16)...)},
+		{name: "列表长度被截断", payload: append(append([]byte{sshMsgKexInit}, make([]byte, 16)...), 0, 0)},
+		{name: "列表长度溢出", payload: overflow},
+		{name: "只有前三个列表", payload: buildKexInit("a", "b", "c")[:17+3*5]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if kex, err := parseSSHKexInit(tt.payload); err == nil {
+				t.Errorf("期望返回错误，得到 %+v", kex)
+			}
+		})
+	}
+
+	// 截断到任意长度都不能崩溃
+	for i := range openSSHKexInit {
+		parseSSHKexInit(openSSHKexInit[:i])
+	}
+}
+
+func TestParseSSHKexInitEmptyLists(t *testing.T) {
+	kex, err := parseSSHKexInit(buildKexInit("", "", "", "", "", "", "", ""))
+	if err != nil {
+		t.Fatalf("空算法列表返回错误: %v", err)
+	}
+	if kex.KexAlgos != nil || len(kex.Ciphers()) != 0 || len(weakAlgorithmFindings(kex)) != 0 {
+		t.Errorf("空算法列表解析错误: %+v", kex)
+	}
+}
+
+func TestReadSSHPacket(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "正常报文", data: buildSSHPacket([]byte("payload"), 4), want: []byte("payload")},
+		{name: "无填充", data: buildSSHPacket([]byte{sshMsgKexInit}, 0), want: []byte{sshMsgKexInit}},
+		{name: "空数据", data: nil, wantErr: true},
+		{name: "报文头不完整", data: []byte{0, 0, 0}, wantErr: true},
+		{name: "报文体不完整", data: buildSSHPacket([]byte("payload"), 4)[:9], wantErr: true},
+		{name: "长度为0", data: []byte{0, 0, 0, 0, 0}, wantErr: true},
+		{name: "填充超过长度", data: []byte{0, 0, 0, 4, 10, 1, 2, 3}, wantErr: true},
+		{name: "长度超过上限", data: []byte{0, 0x10, 0, 0, 4}, wantErr: true},
+		{name: "长度溢出", data: []byte{0xff, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSSHPacket(bytes.NewReader(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readSSHPacket 返回错误: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("readSSHPacket = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeakAlgorithmFindings(t *testing.T) {
+	kex := &sshKexInit{
+		KexAlgos:            []string{"curve25519-sha256", "diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1"},
+		HostKeyAlgos:        []string{"ssh-ed25519", "rsa-sha2-256"},
+		CiphersClientServer: []string{"aes128-ctr", "arcfour256"},
+		CiphersServerClient: []string{"aes128-ctr", "3des-cbc"},
+		MACsClientServer:    []string{"hmac-sha2-256", "hmac-md5-96"},
+	}
+
+	findings := weakAlgorithmFindings(kex)
+	byID := make(map[string]Finding)
+	for _, f := range findings {
+		byID[f.ID] = f
+	}
+
+	if _, ok := byID["ssh-weak-hostkey"]; ok {
+		t.Error("只支持 ed25519 和 rsa-sha2 时不应报告弱主机密钥算法")
+	}
+	tests := []struct {
+		id       string
+		title    string
+		evidence []string
+	}{
+		{id: "ssh-weak-kex", title: "支持 2 种弱密钥交换算法", evidence: []string{"diffie-hellman-group1-sha1（1024 位 DH 组）", "diffie-hellman-group14-sha1（SHA1）"}},
+		{id: "ssh-weak-cipher", title: "支持 2 种弱加密算法", evidence: []string{"arcfour256（RC4）", "3des-cbc（CBC 模式）"}},
+		{id: "ssh-weak-mac", title: "支持 1 种弱MAC算法", evidence: []string{"hmac-md5-96（MD5）"}},
+	}
+	for _, tt := range tests {
+		f, ok := byID[tt.id]
+		if !ok {
+			t.Errorf("缺少 %s", tt.id)
+			continue
+		}
+		if f.Title != tt.title || f.Evidence != strings.Join(tt.evidence, "\n") {
+			t.Errorf("%s = %q / %q，期望 %q / %q", tt.id, f.Title, f.Evidence, tt.title, strings.Join(tt.evidence, "\n"))
+		}
+	}
+}
+
+func TestSSHVersionFinding(t *testing.T) {
+	tests := []struct {
+		banner string
+		title  string
+	}{
+		{banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13", title: "SSH 协议 2.0，软件 OpenSSH_9.6p1（Ubuntu-3ubuntu13）"},
+		{banner: "SSH-1.99-dropbear_2022.83", title: "SSH 协议 1.99，软件 dropbear_2022.83"},
+		{banner: "SSH-2.0", title: "SSH 协议 2.0，软件 "},
+		{banner: "garbage", title: "SSH 版本: garbage"},
+	}
+	for _, tt := range tests {
+		if f := sshVersionFinding(tt.banner); f.Title != tt.title || f.Evidence != tt.banner {
+			t.Errorf("sshVersionFinding(%q) = %q，期望 %q", tt.banner, f.Title, tt.title)
+		}
+	}
+}
+
+// serveSSH 启动只回复固定数据的本地服务，返回地址
+func serveSSH(t *testing.T, reply []byte) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				bufio.NewReader(conn).ReadString('\n')
+				conn.Write(reply)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestReadSSHKexInit(t *testing.T) {
+	packet := buildSSHPacket(openSSHKexInit, 6)
+	manyLines := strings.Repeat("hello\r\n", sshMaxBannerLines) + "SSH-2.0-late\r\n"
+
+	tests := []struct {
+		name    string
+		reply   []byte
+		banner  string
+		kexAlgo string
+		wantErr bool
+	}{
+		{name: "OpenSSH", reply: append([]byte("SSH-2.0-OpenSSH_9.6\r\n"), packet...), banner: "SSH-2.0-OpenSSH_9.6", kexAlgo: "curve25519-sha256"},
+		{name: "版本行之前有其他行", reply: append([]byte("welcome\r\nSSH-2.0-Test\n"), packet...), banner: "SSH-2.0-Test", kexAlgo: "curve25519-sha256"},
+		{name: "SSH-1不发送KEXINIT", reply: []byte("SSH-1.5-OldServer\r\n"), banner: "SSH-1.5-OldServer"},
+		{name: "SSH-1.99需要KEXINIT", reply: append([]byte("SSH-1.99-Compat\r\n"), packet...), banner: "SSH-1.99-Compat", kexAlgo: "curve25519-sha256"},
+		{name: "不是SSH服务", reply: []byte("220 ftp ready\r\n"), wantErr: true},
+		{name: "版本行之前行数过多", reply: []byte(manyLines), wantErr: true},
+		{name: "KEXINIT被截断", reply: append([]byte("SSH-2.0-Cut\r\n"), packet[:40]...), wantErr: true},
+		{name: "不是KEXINIT", reply: append([]byte("SSH-2.0-Odd\r\n"), buildSSHPacket([]byte{1, 2, 3}, 4)...), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveSSH(t, tt.reply)
+			banner, kex, err := readSSHKexInit(context.Background(), addr, time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %q %+v", banner, kex)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readSSHKexInit 返回错误: %v", err)
+			}
+			if banner != tt.banner {
+				t.Errorf("banner = %q，期望 %q", banner, tt.banner)
+			}
+			got := ""
+			if len(kex.KexAlgos) > 0 {
+				got = kex.KexAlgos[0]
+			}
+			if got != tt.kexAlgo {
+				t.Errorf("KexAlgos[0] = %q，期望 %q", got, tt.kexAlgo)
+			}
+		})
+	}
+}
+
+func FuzzParseSSHKexInit(f *testing.F) {
+	f.Add(openSSHKexInit)
+	f.Add([]byte{sshMsgKexInit})
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		if kex, err := parseSSHKexInit(payload); err == nil {
+			weakAlgorithmFindings(kex)
+			_ = kex.String()
+		}
+	})
+}
@@ -0,0 +1,631 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 模板响应的读取上限
+const (
+	defaultReadSize = 4096
+	maxReadSize     = 1 << 20
+	evidenceLimit   = 512
+)
+
+// Template 声明式检测模板，使用YAML或JSON编写
+//
+//	id: git-config-exposure
+//	description: 检测网站根目录下暴露的 .git/config
+//	category: safe
+//	severity: medium
+//	remediation: 禁止通过Web访问 .git 目录
+//	services: [http, https]
+//	ports: [80]
+//	protocol: http
+//	request:
+//	  path: /.git/config
+//	matchers:
+//	  - type: status
+//	    status: [200]
+//	  - type: word
+//	    words: ["[core]"]
+//	matchers-condition: and
+type Template struct {
+	ID          string   `yaml:"id"`
+	Description string   `yaml:"description"`
+	Author      string   `yaml:"author"`
+	References  []string `yaml:"references"`
+	Category    string   `yaml:"category"` // safe、intrusive、bruteforce，默认 safe
+	Severity    string   `yaml:"severity"` // critical、high、medium、low、info，默认 info
+	CVSS        float64  `yaml:"cvss"`
+	Remediation string   `yaml:"remediation"`
+	Services    []string `yaml:"services"` // 安全扫描模式下对这些服务自动运行
+	Ports       []int    `yaml:"ports"`    // 默认端口
+
+	Protocol string          `yaml:"protocol"` // tcp 或 http
+	TLS      bool            `yaml:"tls"`      // 使用TLS连接（http 即 https），不校验证书；http 协议未设置时按端口自动探测
+	Request  TemplateRequest `yaml:"request"`  // http 请求
+	Payload  string          `yaml:"payload"`  // tcp 连接后发送的数据，为空时只读取banner
+	ReadSize int             `yaml:"read-size"`
+
+	MatchersCondition string      `yaml:"matchers-condition"` // 匹配器之间的关系：or（默认）、and
+	Matchers          []Matcher   `yaml:"matchers"`
+	Extractors        []Extractor `yaml:"extractors"`
+}
+
+// TemplateRequest http 模板的请求
+// path、headers、body 和 tcp 的 payload 中可以使用 {{Host}}、{{Port}}、{{BaseURL}}
+type TemplateRequest struct {
+	Method          string            `yaml:"method"` // 默认 GET
+	Path            string            `yaml:"path"`   // 默认 /
+	Headers         map[string]string `yaml:"headers"`
+	Body            string            `yaml:"body"`
+	FollowRedirects bool              `yaml:"follow-redirects"`
+}
+
+// Matcher 匹配器
+type Matcher struct {
+	Type            string   `yaml:"type"`      // status、word、regex、header
+	Part            string   `yaml:"part"`      // word、regex 匹配的部分：body（默认）、header、all；tcp 模板只有响应数据
+	Status          []int    `yaml:"status"`    // status 匹配器的状态码
+	Words           []string `yaml:"words"`     // word 和 header 匹配器的关键字
+	Regex           []string `yaml:"regex"`     // regex 和 header 匹配器的正则表达式
+	Header          string   `yaml:"header"`    // header 匹配器检查的响应头，未指定 words、regex 时只要求存在
+	Condition       string   `yaml:"condition"` // 多个 words、regex 之间的关系：or（默认）、and
+	Negative        bool     `yaml:"negative"`  // 取反
+	CaseInsensitive bool     `yaml:"case-insensitive"`
+
+	regexps []*regexp.Regexp
+}
+
+// Extractor 提取器，匹配成功时从响应中提取数据写入检测结果
+type Extractor struct {
+	Name   string   `yaml:"name"`
+	Type   string   `yaml:"type"`   // regex 或 header
+	Part   string   `yaml:"part"`   // 同 Matcher.Part
+	Regex  []string `yaml:"regex"`  // regex 提取器的正则表达式
+	Group  int      `yaml:"group"`  // 提取的分组，默认整个匹配
+	Header string   `yaml:"header"` // header 提取器提取的响应头
+
+	regexps []*regexp.Regexp
+}
+
+// templateResponse 模板匹配的响应数据
+type templateResponse struct {
+	status int
+	header http.Header
+	head   string // 状态行和响应头
+	body   []byte
+}
+
+// part 返回匹配的部分
+func (r *templateResponse) part(name string) string {
+	switch name {
+	case "header":
+		return r.head
+	case "all":
+		return r.head + "\r\n" + string(r.body)
+	default:
+		return string(r.body)
+	}
+}
+
+// TemplatePlugin 由模板生成的插件
+type TemplatePlugin struct {
+	Template
+	Path string // 模板文件
+}
+
+// Name 插件名称
+func (p *TemplatePlugin) Name() string {
+	return p.ID
+}
+
+// Description 插件描述
+func (p *TemplatePlugin) Description() string {
+	if p.Template.Description == "" {
+		return "模板检测: " + p.ID
+	}
+	return p.Template.Description
+}
+
+// Metadata 插件元数据
+func (p *TemplatePlugin) Metadata() Metadata {
+	return Metadata{
+		Services:   p.Services,
+		Ports:      p.Ports,
+		Category:   p.Category,
+		Author:     p.Author,
+		References: p.References,
+	}
+}
+
+// LoadTemplates 加载目录（含子目录）下的所有 .yaml、.yml、.json 模板
+// 单个模板出错时跳过该模板，返回其余模板和所有错误
+func LoadTemplates(dir string) ([]*TemplatePlugin, error) {
+	var plugins []*TemplatePlugin
+	var errs []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isTemplateFile(path) {
+			return nil
+		}
+		p, err := LoadTemplate(path)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		plugins = append(plugins, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取模板目录失败: %v", err)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// isTemplateFile 按扩展名判断是否为模板
+func isTemplateFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// LoadTemplate 加载一个模板文件，JSON是YAML的子集，两种格式使用同一个解析器
+func LoadTemplate(path string) (*TemplatePlugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取模板失败: %v", err)
+	}
+
+	var t Template
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&t); err != nil {
+		return nil, fmt.Errorf("解析模板 %s 失败: %v", path, err)
+	}
+	if err := t.compile(); err != nil {
+		return nil, fmt.Errorf("模板 %s 无效: %v", path, err)
+	}
+	return &TemplatePlugin{Template: t, Path: path}, nil
+}
+
+// compile 检查模板、补全默认值并编译正则表达式
+func (t *Template) compile() error {
+	if t.ID == "" || strings.ContainsAny(t.ID, " \t\r\n") {
+		return fmt.Errorf("id 不能为空且不能包含空白字符")
+	}
+	if t.Severity == "" {
+		t.Severity = "info"
+	}
+	if !slices.Contains([]string{"critical", "high", "medium", "low", "info"}, t.Severity) {
+		return fmt.Errorf("不支持的风险等级: %s", t.Severity)
+	}
+	if t.CVSS < 0 || t.CVSS > 10 {
+		return fmt.Errorf("cvss 必须在 0 到 10 之间")
+	}
+	category, err := validCategory(t.Category)
+	if err != nil {
+		return err
+	}
+	t.Category = category
+	if t.Protocol != "tcp" && t.Protocol != "http" {
+		return fmt.Errorf("不支持的协议: %q（可选 tcp、http）", t.Protocol)
+	}
+	if t.ReadSize <= 0 {
+		t.ReadSize = defaultReadSize
+	}
+	t.ReadSize = min(t.ReadSize, maxReadSize)
+	if t.Request.Method == "" {
+		t.Request.Method = http.MethodGet
+	}
+	if t.Request.Path == "" {
+		t.Request.Path = "/"
+	}
+	if err := checkCondition(t.MatchersCondition); err != nil {
+		return fmt.Errorf("matchers-condition: %v", err)
+	}
+	if len(t.Matchers) == 0 {
+		return fmt.Errorf("至少需要一个匹配器")
+	}
+
+	for i := range t.Matchers {
+		m := &t.Matchers[i]
+		if err := m.compile(t.Protocol); err != nil {
+			return fmt.Errorf("第 %d 个匹配器: %v", i+1, err)
+		}
+	}
+	for i := range t.Extractors {
+		e := &t.Extractors[i]
+		if err := e.compile(t.Protocol); err != nil {
+			return fmt.Errorf("第 %d 个提取器: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// compile 检查匹配器并编译正则表达式
+func (m *Matcher) compile(protocol string) error {
+	if err := checkCondition(m.Condition); err != nil {
+		return err
+	}
+	if err := checkPart(m.Part, protocol); err != nil {
+		return err
+	}
+
+	switch m.Type {
+	case "status":
+		if protocol != "http" {
+			return fmt.Errorf("status 匹配器只能用于 http 模板")
+		}
+		if len(m.Status) == 0 {
+			return fmt.Errorf("status 不能为空")
+		}
+	case "word":
+		if len(m.Words) == 0 {
+			return fmt.Errorf("words 不能为空")
+		}
+	case "regex":
+		if len(m.Regex) == 0 {
+			return fmt.Errorf("regex 不能为空")
+		}
+	case "header":
+		if protocol != "http" {
+			return fmt.Errorf("header 匹配器只能用于 http 模板")
+		}
+		if m.Header == "" {
+			return fmt.Errorf("header 不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的匹配器类型: %q（可选 status、word、regex、header）", m.Type)
+	}
+
+	regexps, err := compileRegexps(m.Regex, m.CaseInsensitive)
+	if err != nil {
+		return err
+	}
+	m.regexps = regexps
+	return nil
+}
+
+// compile 检查提取器并编译正则表达式
+func (e *Extractor) compile(protocol string) error {
+	if err := checkPart(e.Part, protocol); err != nil {
+		return err
+	}
+	switch e.Type {
+	case "regex":
+		if len(e.Regex) == 0 {
+			return fmt.Errorf("regex 不能为空")
+		}
+	case "header":
+		if protocol != "http" {
+			return fmt.Errorf("header 提取器只能用于 http 模板")
+		}
+		if e.Header == "" {
+			return fmt.Errorf("header 不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的提取器类型: %q（可选 regex、header）", e.Type)
+	}
+	if e.Name == "" {
+		e.Name = e.Type
+	}
+
+	regexps, err := compileRegexps(e.Regex, false)
+	if err != nil {
+		return err
+	}
+	for _, re := range regexps {
+		if e.Group > re.NumSubexp() {
+			return fmt.Errorf("正则表达式 %s 没有第 %d 个分组", re, e.Group)
+		}
+	}
+	e.regexps = regexps
+	return nil
+}
+
+// checkCondition 检查 and、or 条件
+func checkCondition(condition string) error {
+	if condition != "" && condition != "and" && condition != "or" {
+		return fmt.Errorf("不支持的条件: %s（可选 and、or）", condition)
+	}
+	return nil
+}
+
+// checkPart 检查匹配部分，tcp 模板只有响应数据
+func checkPart(part, protocol string) error {
+	switch part {
+	case "", "body":
+		return nil
+	case "header", "all":
+		if protocol == "http" {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的匹配部分: %s", part)
+}
+
+// compileRegexps 编译正则表达式
+func compileRegexps(patterns []string, caseInsensitive bool) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if caseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式无效: %v", err)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// Scan 执行扫描
+func (p *TemplatePlugin) Scan(target string, port int, timeout time.Duration) (Result, error) {
+	return p.ScanContext(context.Background(), target, port, timeout)
+}
+
+// ScanContext 发送模板中的请求并匹配响应
+func (p *TemplatePlugin) ScanContext(ctx context.Context, target string, port int, timeout time.Duration) (Result, error) {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+	scheme := "http"
+	if p.TLS {
+		scheme = "https"
+	} else if p.Protocol == "http" {
+		// 同一模板可能用于 http 和 https 端口，用 TLS 握手判断
+		detected, err := detectHTTPScheme(ctx, address, timeout)
+		if err != nil {
+			return Result{Vulnerable: false}, err
+		}
+		scheme = detected
+	}
+	vars := strings.NewReplacer(
+		"{{Host}}", target,
+		"{{Port}}", strconv.Itoa(port),
+		"{{BaseURL}}", scheme+"://"+address,
+	)
+
+	var resp *templateResponse
+	var err error
+	if p.Protocol == "http" {
+		resp, err = p.sendHTTP(ctx, scheme+"://"+address, vars, timeout)
+	} else {
+		resp, err = p.sendTCP(ctx, address, vars, timeout)
+	}
+	if err != nil {
+		return Result{Vulnerable: false}, err
+	}
+
+	if !p.match(resp) {
+		return Result{Vulnerable: false, Details: "未命中模板: " + p.ID}, nil
+	}
+
+	details := p.Description()
+	var extracted []string
+	for _, e := range p.Extractors {
+		if values := e.extract(resp); len(values) > 0 {
+			extracted = append(extracted, e.Name+"="+strings.Join(values, ","))
+		}
+	}
+	if len(extracted) > 0 {
+		details += "（" + strings.Join(extracted, "; ") + "）"
+	}
+
+	finding := Finding{
+		ID:          p.ID,
+		Title:       details,
+		Severity:    p.Severity,
+		CVSS:        p.CVSS,
+		Evidence:    evidence(resp),
+		Remediation: p.Remediation,
+	}
+	return Result{
+		Details:     details,
+		Evidence:    finding.Evidence,
+		Remediation: p.Remediation,
+		Findings:    []Finding{finding},
+	}, nil
+}
+
+// sendHTTP 发送 http 请求
+func (p *TemplatePlugin) sendHTTP(ctx context.Context, baseURL string, vars *strings.Replacer, timeout time.Duration) (*templateResponse, error) {
+	var body io.Reader
+	if p.Request.Body != "" {
+		body = strings.NewReader(vars.Replace(p.Request.Body))
+	}
+	req, err := http.NewRequestWithContext(ctx, p.Request.Method, baseURL+vars.Replace(p.Request.Path), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range p.Request.Headers {
+		req.Header.Set(name, vars.Replace(value))
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	// 每次请求都新建客户端，不保持连接，避免留下空闲连接
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+	if !p.Request.FollowRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(p.ReadSize)))
+	if err != nil && len(data) == 0 {
+		return nil, err
+	}
+
+	var head strings.Builder
+	fmt.Fprintf(&head, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(&head)
+	return &templateResponse{status: resp.StatusCode, header: resp.Header, head: head.String(), body: data}, nil
+}
+
+// sendTCP 建立连接，发送 payload 并读取响应
+func (p *TemplatePlugin) sendTCP(ctx context.Context, address string, vars *strings.Replacer, timeout time.Duration) (*templateResponse, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if p.TLS {
+		tlsDialer := tls.Dialer{NetDialer: &dialer, Config: &tls.Config{InsecureSkipVerify: true}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if p.Payload != "" {
+		if _, err := conn.Write([]byte(vars.Replace(p.Payload))); err != nil {
+			return nil, err
+		}
+	}
+
+	// 第一次读取等待到超时，之后数据停止到达即结束
+	buffer := make([]byte, p.ReadSize)
+	n, err := conn.Read(buffer)
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	for n < len(buffer) {
+		conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		m, err := conn.Read(buffer[n:])
+		n += m
+		if err != nil {
+			break
+		}
+	}
+	return &templateResponse{body: buffer[:n]}, ctx.Err()
+}
+
+// match 按 matchers-condition 组合所有匹配器
+func (p *TemplatePlugin) match(resp *templateResponse) bool {
+	for _, m := range p.Matchers {
+		ok := m.match(resp)
+		if p.MatchersCondition == "and" && !ok {
+			return false
+		}
+		if p.MatchersCondition != "and" && ok {
+			return true
+		}
+	}
+	return p.MatchersCondition == "and"
+}
+
+// match 执行一个匹配器
+func (m *Matcher) match(resp *templateResponse) bool {
+	var ok bool
+	switch m.Type {
+	case "status":
+		ok = slices.Contains(m.Status, resp.status)
+	case "word":
+		ok = m.matchText(resp.part(m.Part))
+	case "regex":
+		ok = m.matchText(resp.part(m.Part))
+	case "header":
+		values := resp.header.Values(m.Header)
+		if len(m.Words) == 0 && len(m.regexps) == 0 {
+			ok = len(values) > 0
+		} else {
+			ok = len(values) > 0 && m.matchText(strings.Join(values, "\n"))
+		}
+	}
+	return ok != m.Negative
+}
+
+// matchText 按 condition 匹配 words 和 regex
+func (m *Matcher) matchText(text string) bool {
+	checks := make([]bool, 0, len(m.Words)+len(m.regexps))
+	for _, word := range m.Words {
+		if m.CaseInsensitive {
+			checks = append(checks, strings.Contains(strings.ToLower(text), strings.ToLower(word)))
+		} else {
+			checks = append(checks, strings.Contains(text, word))
+		}
+	}
+	for _, re := range m.regexps {
+		checks = append(checks, re.MatchString(text))
+	}
+
+	if m.Condition == "and" {
+		return !slices.Contains(checks, false)
+	}
+	return slices.Contains(checks, true)
+}
+
+// extract 执行一个提取器，返回去重后的值
+func (e *Extractor) extract(resp *templateResponse) []string {
+	var values []string
+	if e.Type == "header" {
+		values = resp.header.Values(e.Header)
+	} else {
+		text := resp.part(e.Part)
+		for _, re := range e.regexps {
+			for _, groups := range re.FindAllStringSubmatch(text, -1) {
+				values = append(values, strings.TrimSpace(groups[e.Group]))
+			}
+		}
+	}
+
+	var unique []string
+	for _, v := range values {
+		if v != "" && !slices.Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// evidence 截取响应作为证据
+func evidence(resp *templateResponse) string {
+	text := resp.head + string(resp.body)
+	if len(text) > evidenceLimit {
+		text = text[:evidenceLimit] + "..."
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(text, ""))
+}
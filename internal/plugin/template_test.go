@@ -0,0 +1,315 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseTemplate 把模板写入临时文件后加载
+func parseTemplate(t *testing.T, src string) (*TemplatePlugin, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "template.yaml")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadTemplate(path)
+}
+
+// mustTemplate 加载模板，失败时结束测试
+func mustTemplate(t *testing.T, src string) *TemplatePlugin {
+	t.Helper()
+	p, err := parseTemplate(t, src)
+	if err != nil {
+		t.Fatalf("加载模板失败: %v\n%s", err, src)
+	}
+	return p
+}
+
+func TestTemplateCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{name: "最小http模板", src: "id: a\nprotocol: http\nmatchers: [{type: status, status: [200]}]"},
+		{name: "最小tcp模板", src: "id: a\nprotocol: tcp\nmatchers: [{type: word, words: [x]}]"},
+		{name: "JSON格式", src: `{"id": "a", "protocol": "tcp", "matchers": [{"type": "regex", "regex": ["^x"]}]}`},
+		{name: "缺少id", src: "protocol: http\nmatchers: [{type: status, status: [200]}]", wantErr: true},
+		{name: "id包含空格", src: "id: a b\nprotocol: http\nmatchers: [{type: status, status: [200]}]", wantErr: true},
+		{name: "未知字段", src: "id: a\nprotocol: http\npath: /x\nmatchers: [{type: status, status: [200]}]", wantErr: true},
+		{name: "不支持的协议", src: "id: a\nprotocol: udp\nmatchers: [{type: word, words: [x]}]", wantErr: true},
+		{name: "不支持的风险等级", src: "id: a\nseverity: urgent\nprotocol: tcp\nmatchers: [{type: word, words: [x]}]", wantErr: true},
+		{name: "cvss超出范围", src: "id: a\ncvss: 11\nprotocol: tcp\nmatchers: [{type: word, words: [x]}]", wantErr: true},
+		{name: "不支持的类别", src: "id: a\ncategory: dangerous\nprotocol: tcp\nmatchers: [{type: word, words: [x]}]", wantErr: true},
+		{name: "没有匹配器", src: "id: a\nprotocol: http", wantErr: true},
+		{name: "不支持的matchers-condition", src: "id: a\nprotocol: tcp\nmatchers-condition: xor\nmatchers: [{type: word, words: [x]}]", wantErr: true},
+		{name: "不支持的匹配器类型", src: "id: a\nprotocol: tcp\nmatchers: [{type: size}]", wantErr: true},
+		{name: "tcp模板不能用status", src: "id: a\nprotocol: tcp\nmatchers: [{type: status, status: [200]}]", wantErr: true},
+		{name: "tcp模板不能用header", src: "id: a\nprotocol: tcp\nmatchers: [{type: header, header: Server}]", wantErr: true},
+		{name: "tcp模板不能匹配响应头", src: "id: a\nprotocol: tcp\nmatchers: [{type: word, part: header, words: [x]}]", wantErr: true},
+		{name: "status为空", src: "id: a\nprotocol: http\nmatchers: [{type: status}]", wantErr: true},
+		{name: "words为空", src: "id: a\nprotocol: http\nmatchers: [{type: word}]", wantErr: true},
+		{name: "正则表达式无效", src: "id: a\nprotocol: http\nmatchers: [{type: regex, regex: ['(']}]", wantErr: true},
+		{name: "提取器分组不存在", src: "id: a\nprotocol: http\nmatchers: [{type: status, status: [200]}]\nextractors: [{type: regex, regex: ['a(b)'], group: 2}]", wantErr: true},
+		{name: "不支持的提取器类型", src: "id: a\nprotocol: http\nmatchers: [{type: status, status: [200]}]\nextractors: [{type: json}]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTemplate(t, tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadTemplate 错误 = %v，期望出错 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateDefaults(t *testing.T) {
+	p := mustTemplate(t, "id: a\nprotocol: http\nread-size: 99999999\nmatchers: [{type: status, status: [200]}]\nextractors: [{type: header, header: Server}]")
+	if p.Severity != "info" || p.Category != CategorySafe || p.Request.Method != http.MethodGet ||
+		p.Request.Path != "/" || p.ReadSize != maxReadSize || p.Extractors[0].Name != "header" {
+		t.Errorf("默认值不正确: %+v", p.Template)
+	}
+}
+
+// httpResponse 构造 http 模板的响应
+func httpResponse(status int, header http.Header, body string) *templateResponse {
+	var head strings.Builder
+	fmt.Fprintf(&head, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	header.Write(&head)
+	return &templateResponse{status: status, header: header, head: head.String(), body: []byte(body)}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	resp := httpResponse(200, http.Header{
+		"Server":     {"Apache/2.4.58"},
+		"Set-Cookie": {"a=1", "session=xyz; HttpOnly"},
+	}, "<title>Apache Server Status</title>\nServer uptime: 3 days")
+
+	tests := []struct {
+		name     string
+		matchers string
+		want     bool
+	}{
+		{name: "状态码命中", matchers: "matchers: [{type: status, status: [301, 200]}]", want: true},
+		{name: "状态码未命中", matchers: "matchers: [{type: status, status: [404]}]"},
+		{name: "关键字任一命中", matchers: "matchers: [{type: word, words: [nginx, uptime]}]", want: true},
+		{name: "关键字全部命中", matchers: "matchers: [{type: word, words: [Apache, uptime], condition: and}]", want: true},
+		{name: "关键字未全部命中", matchers: "matchers: [{type: word, words: [Apache, nginx], condition: and}]"},
+		{name: "区分大小写", matchers: "matchers: [{type: word, words: [APACHE SERVER]}]"},
+		{name: "不区分大小写", matchers: "matchers: [{type: word, words: [APACHE SERVER], case-insensitive: true}]", want: true},
+		{name: "正则表达式", matchers: `matchers: [{type: regex, regex: ['uptime: \d+ days']}]`, want: true},
+		{name: "正则表达式不区分大小写", matchers: `matchers: [{type: regex, regex: ['^<TITLE>'], case-insensitive: true}]`, want: true},
+		{name: "默认只匹配响应体", matchers: "matchers: [{type: word, words: [Apache/2.4]}]"},
+		{name: "匹配响应头", matchers: "matchers: [{type: word, part: header, words: [Apache/2.4]}]", want: true},
+		{name: "匹配全部响应", matchers: "matchers: [{type: word, part: all, words: ['200 OK', 'Server uptime'], condition: and}]", want: true},
+		{name: "取反", matchers: "matchers: [{type: word, words: [nginx], negative: true}]", want: true},
+		{name: "响应头存在", matchers: "matchers: [{type: header, header: server}]", want: true},
+		{name: "响应头不存在", matchers: "matchers: [{type: header, header: X-Powered-By}]"},
+		{name: "响应头关键字", matchers: "matchers: [{type: header, header: Set-Cookie, words: [HttpOnly]}]", want: true},
+		{name: "响应头正则", matchers: `matchers: [{type: header, header: Server, regex: ['^nginx']}]`},
+		{name: "缺少响应头时取反", matchers: "matchers: [{type: header, header: Strict-Transport-Security, negative: true}]", want: true},
+		{name: "匹配器默认为或", matchers: "matchers: [{type: status, status: [404]}, {type: word, words: [uptime]}]", want: true},
+		{name: "匹配器全部命中", matchers: "matchers-condition: and\nmatchers: [{type: status, status: [200]}, {type: word, words: [uptime]}]", want: true},
+		{name: "匹配器未全部命中", matchers: "matchers-condition: and\nmatchers: [{type: status, status: [200]}, {type: word, words: [nginx]}]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mustTemplate(t, "id: a\nprotocol: http\n"+tt.matchers)
+			if got := p.match(resp); got != tt.want {
+				t.Errorf("match = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateExtract(t *testing.T) {
+	resp := httpResponse(200, http.Header{"Server": {"Apache/2.4.58"}, "X-Backend": {"web1", "web2", "web1"}},
+		"url = https://git.example.com/a.git\n  url=https://git.example.com/b.git\nurl = https://git.example.com/a.git\n")
+
+	tests := []struct {
+		name      string
+		extractor string
+		want      []string
+	}{
+		{name: "整个匹配", extractor: `{type: regex, regex: ['example\.com/\w+']}`, want: []string{"example.com/a", "example.com/b"}},
+		{name: "分组并去重", extractor: `{type: regex, regex: ['url\s*=\s*(\S+)'], group: 1}`, want: []string{"https://git.example.com/a.git", "https://git.example.com/b.git"}},
+		{name: "多个正则", extractor: `{type: regex, regex: ['/(a)\.git', '/(b)\.git'], group: 1}`, want: []string{"a", "b"}},
+		{name: "从响应头提取", extractor: `{type: regex, part: header, regex: ['Server: (\S+)'], group: 1}`, want: []string{"Apache/2.4.58"}},
+		{name: "提取响应头的值", extractor: "{type: header, header: X-Backend}", want: []string{"web1", "web2"}},
+		{name: "没有匹配", extractor: `{type: regex, regex: ['password=(\S+)'], group: 1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mustTemplate(t, "id: a\nprotocol: http\nmatchers: [{type: status, status: [200]}]\nextractors: ["+tt.extractor+"]")
+			if got := p.Extractors[0].extract(resp); !slices.Equal(got, tt.want) {
+				t.Errorf("extract = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// loadShippedTemplate 加载随项目发布的模板
+func loadShippedTemplate(t *testing.T, id string) *TemplatePlugin {
+	t.Helper()
+	templates, err := LoadTemplates(filepath.Join("..", "..", "plugins"))
+	if err != nil {
+		t.Fatalf("加载模板目录失败: %v", err)
+	}
+	for _, p := range templates {
+		if p.ID == id {
+			return p
+		}
+	}
+	t.Fatalf("没有找到模板 %s", id)
+	return nil
+}
+
+// splitURL 拆分测试服务器地址
+func splitURL(t *testing.T, raw string) (string, int) {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return u.Hostname(), port
+}
+
+func TestTemplateScanHTTP(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/.git/config", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[core]\n\trepositoryformatversion = 0\n[remote \"origin\"]\n\turl = git@git.example.com:team/site.git\n")
+	})
+	handler.HandleFunc("/server-status", func(w http.ResponseWriter, r *http.Request) {
+		// 登录页同样返回 200，但没有状态页内容
+		fmt.Fprint(w, "<title>Login</title>")
+	})
+
+	tests := []struct {
+		name    string
+		tls     bool
+		id      string
+		want    bool
+		details string
+	}{
+		{name: "http暴露.git", id: "git-config-exposure", want: true, details: "remote=git@git.example.com:team/site.git"},
+		{name: "https暴露.git", tls: true, id: "git-config-exposure", want: true, details: "remote=git@git.example.com:team/site.git"},
+		{name: "状态码200但内容不符", id: "apache-server-status", details: "未命中模板"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var srv *httptest.Server
+			if tt.tls {
+				srv = httptest.NewTLSServer(handler)
+			} else {
+				srv = httptest.NewServer(handler)
+			}
+			t.Cleanup(srv.Close)
+			host, port := splitURL(t, srv.URL)
+
+			result, err := loadShippedTemplate(t, tt.id).ScanContext(context.Background(), host, port, 2*time.Second)
+			if err != nil {
+				t.Fatalf("ScanContext 出错: %v", err)
+			}
+			if got := len(result.Findings) > 0; got != tt.want {
+				t.Errorf("发现问题 = %v，期望 %v（%s）", got, tt.want, result.Details)
+			}
+			if !strings.Contains(result.Details, tt.details) {
+				t.Errorf("Details = %q，期望包含 %q", result.Details, tt.details)
+			}
+		})
+	}
+}
+
+func TestTemplateScanHTTPVariables(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.URL.RequestURI(), r.Host, r.Header.Get("X-Origin"))
+	}))
+	t.Cleanup(srv.Close)
+	host, port := splitURL(t, srv.URL)
+
+	p := mustTemplate(t, `id: echo
+protocol: http
+request:
+  method: POST
+  path: /probe?port={{Port}}
+  headers: {Host: vhost.example, X-Origin: '{{BaseURL}}'}
+matchers: [{type: word, words: ['POST /probe?port=`+strconv.Itoa(port)+` vhost.example `+srv.URL+`']}]`)
+	result, err := p.ScanContext(context.Background(), host, port, 2*time.Second)
+	if err != nil || len(result.Findings) != 1 {
+		t.Errorf("ScanContext = %+v, %v，期望替换变量并设置 Host", result, err)
+	}
+}
+
+// serveRsync 启动模拟的 rsync 服务，收到模块列表请求后回复 modules
+func serveRsync(t *testing.T, modules string) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("@RSYNCD: 31.0\n"))
+				r := bufio.NewReader(conn)
+				r.ReadString('\n') // 客户端版本
+				r.ReadString('\n') // 空行表示列出模块
+				conn.Write([]byte(modules + "@RSYNCD: EXIT\n"))
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestTemplateScanRsync(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules string
+		want    bool
+		details string
+	}{
+		{name: "列出模块", modules: "backup\tNightly backups\nwww         \tWeb root\n", want: true, details: "version=31.0; modules=backup,www"},
+		{name: "不列出模块", modules: ""},
+		{name: "只有提示信息", modules: "Welcome to the rsync daemon\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveRsync(t, tt.modules)
+			result, err := loadShippedTemplate(t, "rsync-module-list").ScanContext(context.Background(), "127.0.0.1", port, 2*time.Second)
+			if err != nil {
+				t.Fatalf("ScanContext 出错: %v", err)
+			}
+			if got := len(result.Findings) > 0; got != tt.want {
+				t.Errorf("发现问题 = %v，期望 %v（%s）", got, tt.want, result.Details)
+			}
+			if !strings.Contains(result.Details, tt.details) {
+				t.Errorf("Details = %q，期望包含 %q", result.Details, tt.details)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package reporter
+
+import (
+	"netscanner/internal/plugin"
+	"netscanner/internal/scanner"
+)
+
+// FromScanResult 转换扫描器的端口结果为报告格式
+func FromScanResult(result scanner.ScanResult) ScanResult {
+	return ScanResult{
+		Host:      result.Host,
+		Port:      result.Port,
+		Protocol:  result.Protocol,
+		State:     result.State,
+		Reason:    result.Reason,
+		Service:   result.Service,
+		Banner:    result.Banner,
+		IPVersion: result.IPVersion,
+		Product:   result.Product,
+		Version:   result.Version,
+		ExtraInfo: result.ExtraInfo,
+		CPE:       result.CPE,
+		TLS:       fromTLSInfo(result.TLS),
+	}
+}
+
+// fromTLSInfo 转换TLS信息为报告格式
+func fromTLSInfo(info *scanner.TLSInfo) *TLSDetails {
+	if info == nil {
+		return nil
+	}
+
+	details := &TLSDetails{
+		Version:          info.Version,
+		CipherSuite:      info.CipherSuite,
+		ALPN:             info.ALPN,
+		HostnameMismatch: !info.HostnameMatch,
+	}
+	for _, c := range info.Certificates {
+		details.Certificates = append(details.Certificates, Certificate{
+			Subject:            c.Subject,
+			Issuer:             c.Issuer,
+			SANs:               c.SANs,
+			NotBefore:          c.NotBefore,
+			NotAfter:           c.NotAfter,
+			KeyType:            c.KeyType,
+			KeyBits:            c.KeyBits,
+			SignatureAlgorithm: c.SignatureAlgorithm,
+			SelfSigned:         c.SelfSigned,
+			SHA256:             c.SHA256,
+		})
+	}
+	return details
+}
+
+// FromPluginResult 转换插件检测结果为报告格式
+// 插件返回了 Findings 时每个问题单独一条，否则整体结论作为一条
+func FromPluginResult(pluginName string, result plugin.Result) []Finding {
+	if len(result.Findings) == 0 {
+		return []Finding{{
+			Plugin:      pluginName,
+			Vulnerable:  result.Vulnerable,
+			Severity:    result.Severity,
+			Details:     result.Details,
+			Evidence:    result.Evidence,
+			Remediation: result.Remediation,
+		}}
+	}
+
+	findings := make([]Finding, 0, len(result.Findings))
+	for _, f := range result.Findings {
+		findings = append(findings, Finding{
+			Plugin:      pluginName,
+			ID:          f.ID,
+			Vulnerable:  f.Severity != "info",
+			Severity:    f.Severity,
+			CVSS:        f.CVSS,
+			Details:     f.Title,
+			Evidence:    f.Evidence,
+			Remediation: f.Remediation,
+		})
+	}
+	return findings
+}
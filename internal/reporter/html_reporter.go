@@ -3,44 +3,260 @@ package reporter
 import (
 	"fmt"
 	"html/template"
+	"io"
 	"net"
 	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
 // ScanReport 扫描报告
 type ScanReport struct {
-	Target      string
-	StartTime   time.Time
-	EndTime     time.Time
-	Duration    time.Duration
-	TotalPorts  int
-	OpenPorts   int
-	ClosedPorts int
-	IPv6Ports   int
-	Results     []ScanResult
-	HasIPv6     bool
+	Target            string
+	Mode              string // 扫描模式：port、security
+	ScanType          string // 扫描类型：tcp、udp
+	Ports             string // 端口范围表达式
+	StartTime         time.Time
+	EndTime           time.Time
+	Duration          time.Duration
+	TotalHosts        int
+	TotalPorts        int
+	OpenPorts         int
+	OpenFilteredPorts int // UDP无响应、无法区分开放和过滤的端口
+	FilteredPorts     int // 无响应或被防火墙拒绝的端口
+	UnreachablePorts  int // 主机或网络不可达的端口
+	ClosedPorts       int
+	IPv6Ports         int
+	Hosts             []HostResult    // 按主机分组的结果，HTML报告仅包含有开放端口的主机
+	Discovery         []HostDiscovery // 主机发现结果，跳过发现阶段时为空
+	HasIPv6           bool
+	Incomplete        bool // 扫描被中断，结果不完整
+}
+
+// PortCounts 按状态统计的端口数，命令行、API服务和报告导入共用同一口径
+type PortCounts struct {
+	Total        int
+	Open         int
+	OpenFiltered int
+	Filtered     int
+	Unreachable  int
+	Closed       int
+	IPv6         int // 开放（含 open|filtered）的IPv6端口
+}
+
+// Add 统计一个端口，只有 closed 状态计入关闭端口
+func (c *PortCounts) Add(state, ipVersion string) {
+	c.AddN(state, 1)
+	if ipVersion == "IPv6" && (state == "open" || state == "open|filtered") {
+		c.IPv6++
+	}
+}
+
+// AddN 统计 n 个同一状态的端口，如 nmap XML 中的 extraports
+func (c *PortCounts) AddN(state string, n int) {
+	c.Total += n
+	switch state {
+	case "open":
+		c.Open += n
+	case "open|filtered":
+		c.OpenFiltered += n
+	case "filtered":
+		c.Filtered += n
+	case "unreachable":
+		c.Unreachable += n
+	case "closed":
+		c.Closed += n
+	}
+}
+
+// SetPortCounts 把端口统计写入报告
+func (r *ScanReport) SetPortCounts(c PortCounts) {
+	r.TotalPorts = c.Total
+	r.OpenPorts = c.Open
+	r.OpenFilteredPorts = c.OpenFiltered
+	r.FilteredPorts = c.Filtered
+	r.UnreachablePorts = c.Unreachable
+	r.ClosedPorts = c.Closed
+	r.IPv6Ports = c.IPv6
+	r.HasIPv6 = c.IPv6 > 0
+}
+
+// PortCounts 报告中的端口统计
+func (r ScanReport) PortCounts() PortCounts {
+	return PortCounts{
+		Total:        r.TotalPorts,
+		Open:         r.OpenPorts,
+		OpenFiltered: r.OpenFilteredPorts,
+		Filtered:     r.FilteredPorts,
+		Unreachable:  r.UnreachablePorts,
+		Closed:       r.ClosedPorts,
+		IPv6:         r.IPv6Ports,
+	}
+}
+
+// HostDiscovery 主机发现结果
+type HostDiscovery struct {
+	Host    string
+	Alive   bool
+	Method  string
+	Latency time.Duration
+}
+
+// HostResult 单个主机的扫描结果
+type HostResult struct {
+	Host      string
+	OpenPorts int
+	Results   []ScanResult
 }
 
 // ScanResult 扫描结果
 type ScanResult struct {
+	Host      string
 	Port      int
+	Protocol  string
 	State     string
+	Reason    string
 	Service   string
 	Banner    string
 	IPVersion string
+	Product   string
+	Version   string
+	ExtraInfo string
+	CPE       []string
+	TLS       *TLSDetails // 非TLS服务为 nil
+	Findings  []Finding   // 安全扫描模式下的插件检测结果
 }
 
-// GenerateHTMLReport 生成HTML报告
-func GenerateHTMLReport(report ScanReport, outputFile string) error {
-	// HTML模板
-	htmlTemplate := `<!DOCTYPE html>
-<html lang="zh-CN">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>网络扫描报告 - {{.Target}}</title>
-    <style>
+// Finding 插件检测结果
+type Finding struct {
+	Plugin      string
+	ID          string // 插件给出的问题标识，旧插件为空
+	Vulnerable  bool
+	Severity    string // info, low, medium, high, critical
+	CVSS        float64
+	Details     string
+	Evidence    string // 支撑结论的原始数据
+	Remediation string // 修复建议
+}
+
+// FindingRow 带端口信息的插件检测结果，用于检测结果汇总表
+type FindingRow struct {
+	Host     string
+	Port     int
+	Protocol string
+	Service  string
+	Finding
+}
+
+// SeverityCount 某一风险等级的问题数
+type SeverityCount struct {
+	Severity string
+	Count    int
+}
+
+// severityLevels 风险等级，从高到低
+var severityLevels = []string{"critical", "high", "medium", "low", "info"}
+
+// SeverityRank 风险等级排序值，越大越严重，未知等级为 0
+func SeverityRank(severity string) int {
+	for i, s := range severityLevels {
+		if s == severity {
+			return len(severityLevels) - i
+		}
+	}
+	return 0
+}
+
+// TLSDetails TLS握手信息
+type TLSDetails struct {
+	Version          string
+	CipherSuite      string
+	ALPN             string
+	HostnameMismatch bool // 服务器证书与扫描目标不匹配
+	Certificates     []Certificate
+}
+
+// Certificate 证书信息，Certificates[0] 为服务器证书
+type Certificate struct {
+	Subject            string
+	Issuer             string
+	SANs               []string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	KeyType            string
+	KeyBits            int
+	SignatureAlgorithm string
+	SelfSigned         bool
+	SHA256             string
+}
+
+// CertExpiryWarning 证书剩余有效期少于该值时标记为即将过期
+const CertExpiryWarning = 30 * 24 * time.Hour
+
+// TLSResults 返回所有带TLS信息的结果，用于证书清单
+func (r ScanReport) TLSResults() []ScanResult {
+	var results []ScanResult
+	for _, h := range r.Hosts {
+		for _, res := range h.Results {
+			if res.TLS != nil && len(res.TLS.Certificates) > 0 {
+				results = append(results, res)
+			}
+		}
+	}
+	return results
+}
+
+// FindingRows 返回所有插件检测结果，存在风险的排在前面并按风险等级从高到低排列
+func (r ScanReport) FindingRows() []FindingRow {
+	var rows []FindingRow
+	for _, h := range r.Hosts {
+		for _, res := range h.Results {
+			for _, f := range res.Findings {
+				rows = append(rows, FindingRow{Host: res.Host, Port: res.Port, Protocol: res.Protocol, Service: res.Service, Finding: f})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Vulnerable != rows[j].Vulnerable {
+			return rows[i].Vulnerable
+		}
+		return SeverityRank(rows[i].Severity) > SeverityRank(rows[j].Severity)
+	})
+	return rows
+}
+
+// VulnerableCount 存在风险的检测结果数
+func (r ScanReport) VulnerableCount() int {
+	count := 0
+	for _, row := range r.FindingRows() {
+		if row.Vulnerable {
+			count++
+		}
+	}
+	return count
+}
+
+// SeverityCounts 按风险等级统计存在风险的检测结果，只返回数量不为 0 的等级
+func (r ScanReport) SeverityCounts() []SeverityCount {
+	counts := make(map[string]int)
+	for _, row := range r.FindingRows() {
+		if row.Vulnerable {
+			counts[row.Severity]++
+		}
+	}
+
+	var result []SeverityCount
+	for _, s := range severityLevels {
+		if counts[s] > 0 {
+			result = append(result, SeverityCount{Severity: s, Count: counts[s]})
+		}
+	}
+	return result
+}
+
+// StyleSheet 报告的公共样式，其他HTML报告（如扫描对比报告）复用它以保持外观一致
+const StyleSheet = `
         * {
             margin: 0;
             padding: 0;
@@ -96,10 +312,14 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
             transform: translateY(-5px);
         }
         
+        .card.hosts { border-top: 4px solid #34495e; }
         .card.total { border-top: 4px solid #3498db; }
         .card.open { border-top: 4px solid #2ecc71; }
         .card.closed { border-top: 4px solid #e74c3c; }
+        .card.filtered { border-top: 4px solid #f39c12; }
+        .card.unreachable { border-top: 4px solid #95a5a6; }
         .card.ipv6 { border-top: 4px solid #9b59b6; }
+        .card.findings { border-top: 4px solid #c0392b; }
         
         .card h3 {
             font-size: 14px;
@@ -121,6 +341,10 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
             overflow-x: auto;
         }
         
+        .scan-results.discovery {
+            margin-bottom: 20px;
+        }
+        
         .scan-results h2 {
             color: #2c3e50;
             margin-bottom: 20px;
@@ -171,6 +395,85 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
             font-weight: bold;
         }
         
+        .status-filtered {
+            display: inline-block;
+            padding: 4px 8px;
+            background: #f39c12;
+            color: white;
+            border-radius: 4px;
+            font-size: 12px;
+            font-weight: bold;
+        }
+        
+        .host-title {
+            color: #2c3e50;
+            margin: 20px 0 10px;
+        }
+        
+        .host-title small {
+            color: #7f8c8d;
+            font-weight: normal;
+        }
+        
+        .scan-results.certificates {
+            margin-top: 20px;
+        }
+        
+        .badge {
+            display: inline-block;
+            padding: 2px 6px;
+            margin: 2px 0;
+            color: white;
+            border-radius: 3px;
+            font-size: 11px;
+            font-weight: bold;
+        }
+        
+        .badge-critical { background: #c0392b; }
+        .badge-warning { background: #e67e22; }
+        .badge-ok { background: #27ae60; }
+        
+        .severity-critical { background: #8e1b10; }
+        .severity-high { background: #c0392b; }
+        .severity-medium { background: #e67e22; }
+        .severity-low { background: #f1c40f; color: #333; }
+        .severity-info { background: #3498db; }
+        
+        .scan-results.findings {
+            margin-top: 20px;
+        }
+        
+        .finding-detail {
+            white-space: pre-wrap;
+            word-break: break-word;
+            font-size: 12px;
+            background: #f8f9fa;
+            padding: 6px 8px;
+            border-radius: 4px;
+            margin-top: 4px;
+        }
+        
+        table.sortable th {
+            cursor: pointer;
+            user-select: none;
+        }
+        
+        table.sortable th::after {
+            content: " ↕";
+            color: #bdc3c7;
+            font-size: 12px;
+        }
+        
+        table.sortable th.sorted-asc::after {
+            content: " ↑";
+            color: #2c3e50;
+        }
+        
+        table.sortable th.sorted-desc::after {
+            content: " ↓";
+            color: #2c3e50;
+        }
+        
         .ipv6-badge {
             display: inline-block;
             padding: 2px 6px;
@@ -199,11 +502,86 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
             margin-top: 10px;
         }
         
+        .incomplete {
+            margin-top: 15px;
+            padding: 10px 15px;
+            background: #fdecea;
+            border-left: 4px solid #e74c3c;
+            color: #c0392b;
+            font-weight: bold;
+        }
+        
         .highlight {
             background: #fffacd;
             padding: 2px 4px;
             border-radius: 3px;
         }
+`
+
+// SortScript 使 class="sortable" 的表格可以点击表头排序
+const SortScript = `
+        // 点击表头排序，单元格有 data-sort 时按其值排序，数值列按数字比较
+        document.querySelectorAll("table.sortable").forEach(function (table) {
+            table.querySelectorAll("th").forEach(function (th, col) {
+                th.addEventListener("click", function () {
+                    var asc = !th.classList.contains("sorted-asc");
+                    table.querySelectorAll("th").forEach(function (h) { h.classList.remove("sorted-asc", "sorted-desc"); });
+                    th.classList.add(asc ? "sorted-asc" : "sorted-desc");
+
+                    var tbody = table.tBodies[0];
+                    var key = function (row) {
+                        var cell = row.cells[col];
+                        if (!cell) { return ""; }
+                        return cell.dataset.sort !== undefined ? cell.dataset.sort : cell.textContent.trim();
+                    };
+                    var rows = Array.prototype.slice.call(tbody.rows);
+                    rows.sort(function (a, b) {
+                        var x = key(a), y = key(b);
+                        var nx = parseFloat(x), ny = parseFloat(y);
+                        var cmp = (!isNaN(nx) && !isNaN(ny) && String(nx) === x && String(ny) === y) ? nx - ny : x.localeCompare(y, "zh-CN", {numeric: true});
+                        return asc ? cmp : -cmp;
+                    });
+                    rows.forEach(function (row) { tbody.appendChild(row); });
+                });
+            });
+        });
+`
+
+// TemplateFuncs HTML报告模板的公共函数
+// 用 <style>{{styleSheet}}</style>、<script>{{sortScript}}</script> 引入公共样式和排序脚本，
+// 用 severityLabel、severityRank 显示风险等级
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"styleSheet":    func() template.CSS { return template.CSS(StyleSheet) },
+		"sortScript":    func() template.JS { return template.JS(SortScript) },
+		"severityRank":  SeverityRank,
+		"severityLabel": SeverityLabel,
+	}
+}
+
+// GenerateHTMLReport 生成HTML报告
+func GenerateHTMLReport(report ScanReport, outputFile string) error {
+	// 创建输出文件
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+
+	return WriteHTMLReport(file, report)
+}
+
+// WriteHTMLReport 把HTML报告写到 w
+func WriteHTMLReport(w io.Writer, report ScanReport) error {
+	// HTML模板
+	htmlTemplate := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>网络扫描报告 - {{.Target}}</title>
+    <style>
+{{styleSheet}}
     </style>
 </head>
 <body>
@@ -216,9 +594,19 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
                 结束时间: {{.EndTime.Format "2006-01-02 15:04:05"}} |
                 耗时: {{printf "%.2f" .Duration.Seconds}}秒
             </p>
+            {{if .Incomplete}}
+            <p class="incomplete">⚠️ 扫描被中断，本报告仅包含中断前已完成的结果</p>
+            {{end}}
         </div>
         
         <div class="summary-cards">
+            {{if gt .TotalHosts 1}}
+            <div class="card hosts">
+                <h3>主机数</h3>
+                <div class="number">{{.TotalHosts}}</div>
+            </div>
+            {{end}}
+            
             <div class="card total">
                 <h3>总端口数</h3>
                 <div class="number">{{.TotalPorts}}</div>
@@ -229,11 +617,38 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
                 <div class="number">{{.OpenPorts}}</div>
             </div>
             
+            {{if .OpenFilteredPorts}}
+            <div class="card filtered">
+                <h3>开放|过滤</h3>
+                <div class="number">{{.OpenFilteredPorts}}</div>
+            </div>
+            {{end}}
+            
+            <div class="card filtered">
+                <h3>过滤端口</h3>
+                <div class="number">{{.FilteredPorts}}</div>
+            </div>
+            
+            {{if .UnreachablePorts}}
+            <div class="card unreachable">
+                <h3>不可达</h3>
+                <div class="number">{{.UnreachablePorts}}</div>
+            </div>
+            {{end}}
+            
             <div class="card closed">
                 <h3>关闭端口</h3>
                 <div class="number">{{.ClosedPorts}}</div>
             </div>
             
+            {{if .FindingRows}}
+            <div class="card findings">
+                <h3>安全问题</h3>
+                <div class="number">{{.VulnerableCount}}</div>
+                {{range .SeverityCounts}}<span class="badge severity-{{.Severity}}">{{severityLabel .Severity}} {{.Count}}</span> {{end}}
+            </div>
+            {{end}}
+            
             {{if .HasIPv6}}
             <div class="card ipv6">
                 <h3>IPv6端口</h3>
@@ -243,32 +658,75 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
             {{end}}
         </div>
         
+        {{if .Discovery}}
+        <div class="scan-results discovery">
+            <h2>🔎 主机发现</h2>
+            <table class="sortable">
+                <thead>
+                    <tr>
+                        <th>主机</th>
+                        <th>状态</th>
+                        <th>发现方式</th>
+                        <th>延迟</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Discovery}}
+                    <tr>
+                        <td><strong>{{.Host}}</strong></td>
+                        <td>
+                            {{if .Alive}}
+                            <span class="status-open">在线</span>
+                            {{else}}
+                            <span class="status-closed">离线</span>
+                            {{end}}
+                        </td>
+                        <td>{{if .Method}}<code>{{.Method}}</code>{{else}}-{{end}}</td>
+                        <td data-sort="{{if .Alive}}{{latencyMs .Latency}}{{else}}-1{{end}}">{{if .Alive}}{{printf "%.2f" (latencyMs .Latency)}} ms{{else}}-{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+        
         <div class="scan-results">
             <h2>📋 扫描结果详情</h2>
             
-            {{if .Results}}
-            <table>
+            {{range .Hosts}}
+            <h3 class="host-title">🖥️ {{.Host}} <small>（{{.OpenPorts}} 个开放端口）</small></h3>
+            <table class="sortable">
                 <thead>
                     <tr>
                         <th>端口</th>
                         <th>状态</th>
+                        <th>原因</th>
                         <th>服务</th>
+                        <th>产品/版本</th>
                         <th>IP版本</th>
                         <th>Banner信息</th>
+                        {{if $.FindingRows}}<th>安全检测</th>{{end}}
                     </tr>
                 </thead>
                 <tbody>
                     {{range .Results}}
                     <tr>
-                        <td><strong>{{.Port}}</strong></td>
+                        <td data-sort="{{.Port}}"><strong>{{.Port}}</strong>/{{.Protocol}}</td>
                         <td>
                             {{if eq .State "open"}}
                             <span class="status-open">开放</span>
+                            {{else if eq .State "open|filtered"}}
+                            <span class="status-filtered">开放|过滤</span>
                             {{else}}
                             <span class="status-closed">关闭</span>
                             {{end}}
                         </td>
+                        <td><code>{{.Reason}}</code></td>
                         <td>{{.Service}}</td>
+                        <td>
+                            {{.Product}} {{.Version}}
+                            {{if .ExtraInfo}}<br><small>{{.ExtraInfo}}</small>{{end}}
+                        </td>
                         <td>
                             {{.IPVersion}}
                             {{if eq .IPVersion "IPv6"}}
@@ -276,6 +734,14 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
                             {{end}}
                         </td>
                         <td><code>{{.Banner}}</code></td>
+                        {{if $.FindingRows}}
+                        <td data-sort="{{maxSeverity .Findings}}">
+                            {{range .Findings}}
+                            {{if .Vulnerable}}<span class="badge severity-{{.Severity}}" title="{{.Details}}">{{.Plugin}}: {{severityLabel .Severity}}</span>
+                            {{else}}<span class="badge badge-ok" title="{{.Details}}">{{.Plugin}}: 通过</span>{{end}}
+                            {{end}}
+                        </td>
+                        {{end}}
                     </tr>
                     {{end}}
                 </tbody>
@@ -287,29 +753,138 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
             {{end}}
         </div>
         
+        {{with .TLSResults}}
+        <div class="scan-results certificates">
+            <h2>🔒 TLS证书清单</h2>
+            <table class="sortable">
+                <thead>
+                    <tr>
+                        <th>端口</th>
+                        <th>协议</th>
+                        <th>主体 / SAN</th>
+                        <th>颁发者</th>
+                        <th>有效期</th>
+                        <th>密钥</th>
+                        <th>问题</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .}}
+                    {{$r := .}}
+                    {{with index .TLS.Certificates 0}}
+                    <tr>
+                        <td><strong>{{$r.Host}}:{{$r.Port}}</strong></td>
+                        <td>
+                            {{$r.TLS.Version}}<br>
+                            <small>{{$r.TLS.CipherSuite}}</small>
+                            {{if $r.TLS.ALPN}}<br><small>ALPN: {{$r.TLS.ALPN}}</small>{{end}}
+                        </td>
+                        <td>
+                            {{.Subject}}
+                            {{if .SANs}}<br><small>{{join .SANs ", "}}</small>{{end}}
+                        </td>
+                        <td>{{.Issuer}}</td>
+                        <td>
+                            {{.NotBefore.Format "2006-01-02"}} ~ {{.NotAfter.Format "2006-01-02"}}
+                        </td>
+                        <td>{{.KeyType}} {{if .KeyBits}}{{.KeyBits}}位{{end}}</td>
+                        <td>
+                            {{if certExpired .}}<span class="badge badge-critical">已过期</span>
+                            {{else if certExpiring .}}<span class="badge badge-warning">{{certDaysLeft .}} 天后过期</span>{{end}}
+                            {{if .SelfSigned}}<span class="badge badge-warning">自签名</span>{{end}}
+                            {{if $r.TLS.HostnameMismatch}}<span class="badge badge-warning">主机名不匹配</span>{{end}}
+                            {{if not (certProblem . $r.TLS)}}<span class="badge badge-ok">正常</span>{{end}}
+                        </td>
+                    </tr>
+                    {{end}}
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+        
+        {{with .FindingRows}}
+        <div class="scan-results findings">
+            <h2>🛡️ 安全检测结果</h2>
+            <table class="sortable">
+                <thead>
+                    <tr>
+                        <th>端口</th>
+                        <th>服务</th>
+                        <th>插件</th>
+                        <th>风险等级</th>
+                        <th>CVSS</th>
+                        <th>详情</th>
+                        <th>修复建议</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .}}
+                    <tr>
+                        <td data-sort="{{.Host}}:{{printf "%05d" .Port}}"><strong>{{.Host}}:{{.Port}}</strong>/{{.Protocol}}</td>
+                        <td>{{.Service}}</td>
+                        <td><code>{{.Plugin}}</code>{{if .ID}}<div class="finding-detail">{{.ID}}</div>{{end}}</td>
+                        {{if .Vulnerable}}
+                        <td data-sort="{{severityRank .Severity}}"><span class="badge severity-{{.Severity}}">{{severityLabel .Severity}}</span></td>
+                        {{else if .ID}}
+                        <td data-sort="0"><span class="badge severity-info">{{severityLabel "info"}}</span></td>
+                        {{else}}
+                        <td data-sort="-1"><span class="badge badge-ok">通过</span></td>
+                        {{end}}
+                        <td data-sort="{{.CVSS}}">{{if .CVSS}}{{printf "%.1f" .CVSS}}{{else}}-{{end}}</td>
+                        <td>
+                            {{.Details}}
+                            {{if .Evidence}}<div class="finding-detail">{{.Evidence}}</div>{{end}}
+                        </td>
+                        <td>{{if .Remediation}}<div class="finding-detail">{{.Remediation}}</div>{{else}}-{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+        
         <div class="footer">
             <p>报告由 <strong>NetSecScanner</strong> 生成 | {{.EndTime.Format "2006-01-02"}}</p>
             <p>仅供安全测试和教育目的使用</p>
         </div>
     </div>
+    <script>
+{{sortScript}}
+    </script>
 </body>
 </html>`
 
 	// 创建模板
-	tmpl, err := template.New("report").Parse(htmlTemplate)
-	if err != nil {
-		return fmt.Errorf("解析模板失败: %v", err)
-	}
+	// 证书有效期以扫描结束时间为准
+	expired := func(c Certificate) bool { return report.EndTime.After(c.NotAfter) }
+	expiring := func(c Certificate) bool { return c.NotAfter.Sub(report.EndTime) < CertExpiryWarning }
 
-	// 创建输出文件
-	file, err := os.Create(outputFile)
+	tmpl, err := template.New("report").Funcs(TemplateFuncs()).Funcs(template.FuncMap{
+		"latencyMs":    func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) },
+		"join":         strings.Join,
+		"certExpired":  expired,
+		"certExpiring": expiring,
+		"certDaysLeft": func(c Certificate) int { return int(c.NotAfter.Sub(report.EndTime).Hours() / 24) },
+		"certProblem": func(c Certificate, t *TLSDetails) bool {
+			return expiring(c) || c.SelfSigned || t.HostnameMismatch
+		},
+		"maxSeverity": func(findings []Finding) int {
+			rank := -1
+			for _, f := range findings {
+				if f.Vulnerable && SeverityRank(f.Severity) > rank {
+					rank = SeverityRank(f.Severity)
+				}
+			}
+			return rank
+		},
+	}).Parse(htmlTemplate)
 	if err != nil {
-		return fmt.Errorf("创建文件失败: %v", err)
+		return fmt.Errorf("解析模板失败: %v", err)
 	}
-	defer file.Close()
 
 	// 执行模板
-	err = tmpl.Execute(file, report)
+	err = tmpl.Execute(w, report)
 	if err != nil {
 		return fmt.Errorf("生成报告失败: %v", err)
 	}
@@ -317,6 +892,25 @@ func GenerateHTMLReport(report ScanReport, outputFile string) error {
 	return nil
 }
 
+// SeverityLabel 风险等级的中文名称
+func SeverityLabel(severity string) string {
+	switch severity {
+	case "critical":
+		return "严重"
+	case "high":
+		return "高危"
+	case "medium":
+		return "中危"
+	case "low":
+		return "低危"
+	case "info":
+		return "信息"
+	case "":
+		return "未知"
+	}
+	return severity
+}
+
 // IsIPv6 判断是否为IPv6地址
 func IsIPv6(address string) bool {
 	// 尝试解析地址
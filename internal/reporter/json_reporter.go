@@ -0,0 +1,450 @@
+package reporter
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSON报告结构的名称和版本
+// 版本号遵循 主版本.次版本：新增字段只增加次版本，删除或修改字段含义才增加主版本。
+// 完整定义见 schema/report-v1.schema.json，可通过 `netscanner schema` 输出
+const (
+	JSONSchemaName    = "netscanner-report"
+	JSONSchemaVersion = "1.3"
+)
+
+//go:embed schema/report-v1.schema.json
+var jsonSchema []byte
+
+// JSONSchema 返回JSON报告的 JSON Schema 定义
+func JSONSchema() []byte {
+	return jsonSchema
+}
+
+// JSONReport JSON报告文档
+type JSONReport struct {
+	Schema        string       `json:"schema"`
+	SchemaVersion string       `json:"schema_version"`
+	Scan          JSONScanInfo `json:"scan"`
+	Summary       JSONSummary  `json:"summary"`
+	Hosts         []JSONHost   `json:"hosts"`
+}
+
+// JSONScanInfo 扫描元数据
+type JSONScanInfo struct {
+	Target     string    `json:"target"`
+	Mode       string    `json:"mode,omitempty"`
+	ScanType   string    `json:"scan_type,omitempty"`
+	Ports      string    `json:"ports,omitempty"` // 1.3 新增
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time,omitzero"`
+	DurationMs int64     `json:"duration_ms"`
+	Incomplete bool      `json:"incomplete"`
+}
+
+// JSONSummary 统计信息
+type JSONSummary struct {
+	Hosts        int `json:"hosts"`
+	TotalPorts   int `json:"total_ports"`
+	Open         int `json:"open"`
+	OpenFiltered int `json:"open_filtered"` // 1.3 新增
+	Filtered     int `json:"filtered"`
+	Unreachable  int `json:"unreachable"`
+	Closed       int `json:"closed"`
+	IPv6Ports    int `json:"ipv6_ports"`
+	Findings     int `json:"findings"`
+}
+
+// JSONHost 单个主机
+type JSONHost struct {
+	Host      string         `json:"host"`
+	Discovery *JSONDiscovery `json:"discovery,omitempty"`
+	Ports     []JSONPort     `json:"ports"`
+}
+
+// JSONDiscovery 主机发现结果
+type JSONDiscovery struct {
+	Alive     bool    `json:"alive"`
+	Method    string  `json:"method,omitempty"`
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+}
+
+// JSONPort 单个端口结果
+type JSONPort struct {
+	Port      int           `json:"port"`
+	Protocol  string        `json:"protocol"`
+	State     string        `json:"state"`
+	Reason    string        `json:"reason,omitempty"`
+	Service   string        `json:"service,omitempty"`
+	Product   string        `json:"product,omitempty"`
+	Version   string        `json:"version,omitempty"`
+	ExtraInfo string        `json:"extra_info,omitempty"`
+	Banner    string        `json:"banner,omitempty"`
+	IPVersion string        `json:"ip_version,omitempty"`
+	TLS       *JSONTLS      `json:"tls,omitempty"`
+	Findings  []JSONFinding `json:"findings,omitempty"`
+}
+
+// JSONTLS TLS握手信息
+type JSONTLS struct {
+	Version          string            `json:"version"`
+	CipherSuite      string            `json:"cipher_suite"`
+	ALPN             string            `json:"alpn,omitempty"`
+	HostnameMismatch bool              `json:"hostname_mismatch"`
+	Certificates     []JSONCertificate `json:"certificates"`
+}
+
+// JSONCertificate 证书信息
+type JSONCertificate struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SANs               []string  `json:"sans,omitempty"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	KeyType            string    `json:"key_type"`
+	KeyBits            int       `json:"key_bits"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	SelfSigned         bool      `json:"self_signed"`
+	SHA256             string    `json:"sha256"`
+}
+
+// JSONFinding 插件检测结果
+type JSONFinding struct {
+	Plugin      string  `json:"plugin"`
+	ID          string  `json:"id,omitempty"` // 1.2 新增
+	Vulnerable  bool    `json:"vulnerable"`
+	Severity    string  `json:"severity,omitempty"`
+	CVSS        float64 `json:"cvss,omitempty"` // 1.2 新增
+	Details     string  `json:"details"`
+	Evidence    string  `json:"evidence,omitempty"`    // 1.1 新增
+	Remediation string  `json:"remediation,omitempty"` // 1.1 新增
+}
+
+// ToJSON 把扫描报告转换为JSON文档结构
+func ToJSON(report ScanReport) JSONReport {
+	doc := JSONReport{
+		Schema:        JSONSchemaName,
+		SchemaVersion: JSONSchemaVersion,
+		Scan:          jsonScanInfo(report),
+		Summary:       jsonSummary(report),
+		Hosts:         []JSONHost{},
+	}
+
+	// 主机顺序：先按主机发现的顺序，再补充未经过发现阶段的主机
+	index := make(map[string]int)
+	hostEntry := func(host string) *JSONHost {
+		if i, ok := index[host]; ok {
+			return &doc.Hosts[i]
+		}
+		index[host] = len(doc.Hosts)
+		doc.Hosts = append(doc.Hosts, JSONHost{Host: host, Ports: []JSONPort{}})
+		return &doc.Hosts[len(doc.Hosts)-1]
+	}
+
+	for _, d := range report.Discovery {
+		entry := hostEntry(d.Host)
+		entry.Discovery = &JSONDiscovery{
+			Alive:     d.Alive,
+			Method:    d.Method,
+			LatencyMs: float64(d.Latency) / float64(time.Millisecond),
+		}
+	}
+	for _, h := range report.Hosts {
+		entry := hostEntry(h.Host)
+		for _, r := range h.Results {
+			entry.Ports = append(entry.Ports, ToJSONPort(r))
+		}
+	}
+
+	return doc
+}
+
+// ToJSONPort 转换单个端口结果
+func ToJSONPort(r ScanResult) JSONPort {
+	port := JSONPort{
+		Port:      r.Port,
+		Protocol:  r.Protocol,
+		State:     r.State,
+		Reason:    r.Reason,
+		Service:   r.Service,
+		Product:   r.Product,
+		Version:   r.Version,
+		ExtraInfo: r.ExtraInfo,
+		Banner:    r.Banner,
+		IPVersion: r.IPVersion,
+	}
+
+	if r.TLS != nil {
+		port.TLS = &JSONTLS{
+			Version:          r.TLS.Version,
+			CipherSuite:      r.TLS.CipherSuite,
+			ALPN:             r.TLS.ALPN,
+			HostnameMismatch: r.TLS.HostnameMismatch,
+			Certificates:     []JSONCertificate{},
+		}
+		for _, c := range r.TLS.Certificates {
+			port.TLS.Certificates = append(port.TLS.Certificates, JSONCertificate(c))
+		}
+	}
+
+	for _, f := range r.Findings {
+		port.Findings = append(port.Findings, ToJSONFinding(f))
+	}
+	return port
+}
+
+// ToJSONFinding 转换插件检测结果
+func ToJSONFinding(f Finding) JSONFinding {
+	return JSONFinding(f)
+}
+
+// jsonScanInfo 提取扫描元数据
+func jsonScanInfo(report ScanReport) JSONScanInfo {
+	return JSONScanInfo{
+		Target:     report.Target,
+		Mode:       report.Mode,
+		ScanType:   report.ScanType,
+		Ports:      report.Ports,
+		StartTime:  report.StartTime,
+		EndTime:    report.EndTime,
+		DurationMs: report.Duration.Milliseconds(),
+		Incomplete: report.Incomplete,
+	}
+}
+
+// jsonSummary 提取统计信息
+func jsonSummary(report ScanReport) JSONSummary {
+	summary := JSONSummary{
+		Hosts:        report.TotalHosts,
+		TotalPorts:   report.TotalPorts,
+		Open:         report.OpenPorts,
+		OpenFiltered: report.OpenFilteredPorts,
+		Filtered:     report.FilteredPorts,
+		Unreachable:  report.UnreachablePorts,
+		Closed:       report.ClosedPorts,
+		IPv6Ports:    report.IPv6Ports,
+	}
+	for _, h := range report.Hosts {
+		for _, r := range h.Results {
+			summary.Findings += len(r.Findings)
+		}
+	}
+	return summary
+}
+
+// GenerateJSONReport 生成JSON报告
+func GenerateJSONReport(report ScanReport, outputFile string) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+
+	return WriteJSONReport(file, report)
+}
+
+// WriteJSONReport 把JSON报告写到 w
+func WriteJSONReport(w io.Writer, report ScanReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(ToJSON(report)); err != nil {
+		return fmt.Errorf("生成报告失败: %v", err)
+	}
+	return nil
+}
+
+// ReadJSONReport 读取 --format json 生成的报告
+func ReadJSONReport(path string) (ScanReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ScanReport{}, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	var doc JSONReport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return ScanReport{}, fmt.Errorf("解析JSON报告失败: %v", err)
+	}
+	if doc.Schema != JSONSchemaName {
+		return ScanReport{}, fmt.Errorf("不是netscanner的JSON报告: %s", path)
+	}
+	// 只要求主版本一致，次版本新增的字段会被忽略
+	if major, _, _ := strings.Cut(doc.SchemaVersion, "."); major != "1" {
+		return ScanReport{}, fmt.Errorf("不支持的报告版本: %s", doc.SchemaVersion)
+	}
+
+	return FromJSON(doc), nil
+}
+
+// ReadReport 读取保存的扫描结果，支持JSON报告和 nmap XML
+func ReadReport(path string) (ScanReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ScanReport{}, fmt.Errorf("读取文件失败: %v", err)
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "<") {
+		return ReadNmapXML(path)
+	}
+	return ReadJSONReport(path)
+}
+
+// FromJSON 把JSON文档转换回扫描报告
+func FromJSON(doc JSONReport) ScanReport {
+	report := ScanReport{
+		Target:            doc.Scan.Target,
+		Mode:              doc.Scan.Mode,
+		ScanType:          doc.Scan.ScanType,
+		Ports:             doc.Scan.Ports,
+		StartTime:         doc.Scan.StartTime,
+		EndTime:           doc.Scan.EndTime,
+		Duration:          time.Duration(doc.Scan.DurationMs) * time.Millisecond,
+		TotalHosts:        doc.Summary.Hosts,
+		TotalPorts:        doc.Summary.TotalPorts,
+		OpenPorts:         doc.Summary.Open,
+		OpenFilteredPorts: doc.Summary.OpenFiltered,
+		FilteredPorts:     doc.Summary.Filtered,
+		UnreachablePorts:  doc.Summary.Unreachable,
+		ClosedPorts:       doc.Summary.Closed,
+		IPv6Ports:         doc.Summary.IPv6Ports,
+		HasIPv6:           doc.Summary.IPv6Ports > 0,
+		Incomplete:        doc.Scan.Incomplete,
+	}
+
+	for _, h := range doc.Hosts {
+		if h.Discovery != nil {
+			report.Discovery = append(report.Discovery, HostDiscovery{
+				Host:    h.Host,
+				Alive:   h.Discovery.Alive,
+				Method:  h.Discovery.Method,
+				Latency: time.Duration(h.Discovery.LatencyMs * float64(time.Millisecond)),
+			})
+		}
+		if len(h.Ports) == 0 {
+			continue
+		}
+
+		hr := HostResult{Host: h.Host}
+		for _, p := range h.Ports {
+			r := ScanResult{
+				Host:      h.Host,
+				Port:      p.Port,
+				Protocol:  p.Protocol,
+				State:     p.State,
+				Reason:    p.Reason,
+				Service:   p.Service,
+				Banner:    p.Banner,
+				IPVersion: p.IPVersion,
+				Product:   p.Product,
+				Version:   p.Version,
+				ExtraInfo: p.ExtraInfo,
+			}
+			if p.TLS != nil {
+				r.TLS = &TLSDetails{
+					Version:          p.TLS.Version,
+					CipherSuite:      p.TLS.CipherSuite,
+					ALPN:             p.TLS.ALPN,
+					HostnameMismatch: p.TLS.HostnameMismatch,
+				}
+				for _, c := range p.TLS.Certificates {
+					r.TLS.Certificates = append(r.TLS.Certificates, Certificate(c))
+				}
+			}
+			for _, f := range p.Findings {
+				r.Findings = append(r.Findings, Finding(f))
+			}
+			if r.State == "open" {
+				hr.OpenPorts++
+			}
+			hr.Results = append(hr.Results, r)
+		}
+		report.Hosts = append(report.Hosts, hr)
+	}
+
+	return report
+}
+
+// JSONLRecord JSON Lines 流中的一行
+// type 依次为 scan_start、port（每个端口一行）、finding（每个插件结果一行）、scan_end
+type JSONLRecord struct {
+	Type          string        `json:"type"`
+	SchemaVersion string        `json:"schema_version"`
+	Time          time.Time     `json:"time"`
+	Scan          *JSONScanInfo `json:"scan,omitempty"`
+	Host          string        `json:"host,omitempty"`
+	Port          *JSONPort     `json:"port,omitempty"`
+	Finding       *JSONFinding  `json:"finding,omitempty"`
+	Summary       *JSONSummary  `json:"summary,omitempty"`
+}
+
+// JSONLWriter 以 JSON Lines 格式增量写出扫描结果，可在多个goroutine中使用
+type JSONLWriter struct {
+	mu      sync.Mutex
+	closer  io.Closer
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter 创建写到 w 的 JSON Lines 写入器
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{encoder: json.NewEncoder(w)}
+}
+
+// CreateJSONLReport 创建 JSON Lines 报告文件
+func CreateJSONLReport(outputFile string) (*JSONLWriter, error) {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件失败: %v", err)
+	}
+	jw := NewJSONLWriter(file)
+	jw.closer = file
+	return jw, nil
+}
+
+// WriteStart 写出扫描开始记录
+func (jw *JSONLWriter) WriteStart(report ScanReport) error {
+	info := jsonScanInfo(report)
+	return jw.write(JSONLRecord{Type: "scan_start", Scan: &info})
+}
+
+// WritePort 写出一个端口结果
+func (jw *JSONLWriter) WritePort(r ScanResult) error {
+	port := ToJSONPort(r)
+	port.Findings = nil
+	return jw.write(JSONLRecord{Type: "port", Host: r.Host, Port: &port})
+}
+
+// WriteFinding 写出一个插件检测结果
+func (jw *JSONLWriter) WriteFinding(r ScanResult, f Finding) error {
+	port := JSONPort{Port: r.Port, Protocol: r.Protocol, State: r.State, Service: r.Service}
+	finding := ToJSONFinding(f)
+	return jw.write(JSONLRecord{Type: "finding", Host: r.Host, Port: &port, Finding: &finding})
+}
+
+// WriteEnd 写出扫描结束记录和统计信息
+func (jw *JSONLWriter) WriteEnd(report ScanReport) error {
+	info := jsonScanInfo(report)
+	summary := jsonSummary(report)
+	return jw.write(JSONLRecord{Type: "scan_end", Scan: &info, Summary: &summary})
+}
+
+// Close 关闭底层文件
+func (jw *JSONLWriter) Close() error {
+	if jw.closer == nil {
+		return nil
+	}
+	return jw.closer.Close()
+}
+
+// write 写出一行
+func (jw *JSONLWriter) write(rec JSONLRecord) error {
+	rec.SchemaVersion = JSONSchemaVersion
+	rec.Time = time.Now()
+
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	return jw.encoder.Encode(rec)
+}
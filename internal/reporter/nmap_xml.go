@@ -0,0 +1,465 @@
+package reporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NmapRun nmap XML 输出的根元素（只包含 netscanner 读写的部分）
+type NmapRun struct {
+	XMLName          xml.Name       `xml:"nmaprun"`
+	Scanner          string         `xml:"scanner,attr"`
+	Args             string         `xml:"args,attr,omitempty"`
+	Start            int64          `xml:"start,attr"`
+	StartStr         string         `xml:"startstr,attr,omitempty"`
+	Version          string         `xml:"version,attr"`
+	XMLOutputVersion string         `xml:"xmloutputversion,attr"`
+	ScanInfo         []NmapScanInfo `xml:"scaninfo"`
+	Hosts            []NmapHost     `xml:"host"`
+	RunStats         *NmapRunStats  `xml:"runstats"`
+}
+
+// NmapScanInfo 扫描类型信息
+type NmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+// NmapHost 单个主机
+type NmapHost struct {
+	StartTime  int64            `xml:"starttime,attr,omitempty"`
+	EndTime    int64            `xml:"endtime,attr,omitempty"`
+	Status     NmapStatus       `xml:"status"`
+	Addresses  []NmapAddress    `xml:"address"`
+	Hostnames  []NmapHostname   `xml:"hostnames>hostname"`
+	ExtraPorts []NmapExtraPorts `xml:"ports>extraports"`
+	Ports      []NmapPort       `xml:"ports>port"`
+	Times      *NmapTimes       `xml:"times"`
+}
+
+// NmapStatus 主机状态
+type NmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+// NmapAddress 主机地址
+type NmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"` // ipv4、ipv6、mac
+}
+
+// NmapHostname 主机名
+type NmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr,omitempty"` // user、PTR
+}
+
+// NmapExtraPorts nmap 折叠显示的同一状态的端口，只有数量没有明细
+type NmapExtraPorts struct {
+	State string `xml:"state,attr"`
+	Count int    `xml:"count,attr"`
+}
+
+// NmapPort 端口
+type NmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    NmapState    `xml:"state"`
+	Service  *NmapService `xml:"service"`
+	Scripts  []NmapScript `xml:"script"`
+}
+
+// NmapState 端口状态
+type NmapState struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+// NmapService 服务识别结果
+type NmapService struct {
+	Name      string   `xml:"name,attr"`
+	Product   string   `xml:"product,attr,omitempty"`
+	Version   string   `xml:"version,attr,omitempty"`
+	ExtraInfo string   `xml:"extrainfo,attr,omitempty"`
+	Tunnel    string   `xml:"tunnel,attr,omitempty"` // ssl
+	Method    string   `xml:"method,attr"`           // probed、table
+	Conf      int      `xml:"conf,attr"`
+	CPE       []string `xml:"cpe"`
+}
+
+// NmapScript NSE脚本输出，netscanner 用它保存插件检测结果
+type NmapScript struct {
+	ID     string     `xml:"id,attr"`
+	Output string     `xml:"output,attr"`
+	Elems  []NmapElem `xml:"elem"`
+}
+
+// NmapElem 脚本的结构化输出
+type NmapElem struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// NmapTimes 主机的往返时间（微秒）
+type NmapTimes struct {
+	SRTT   int64 `xml:"srtt,attr"`
+	RTTVar int64 `xml:"rttvar,attr"`
+	To     int64 `xml:"to,attr"`
+}
+
+// NmapRunStats 扫描统计
+type NmapRunStats struct {
+	Finished NmapFinished  `xml:"finished"`
+	Hosts    NmapHostStats `xml:"hosts"`
+}
+
+// NmapFinished 扫描结束信息
+type NmapFinished struct {
+	Time    int64   `xml:"time,attr"`
+	TimeStr string  `xml:"timestr,attr,omitempty"`
+	Elapsed float64 `xml:"elapsed,attr"`
+	Summary string  `xml:"summary,attr,omitempty"`
+	Exit    string  `xml:"exit,attr"` // success、error
+}
+
+// NmapHostStats 主机统计
+type NmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// ToNmapXML 把扫描报告转换为 nmap XML 结构
+func ToNmapXML(report ScanReport) NmapRun {
+	run := NmapRun{
+		Scanner:          "netscanner",
+		Args:             "netscanner -H " + report.Target + " -p " + report.Ports,
+		Start:            report.StartTime.Unix(),
+		StartStr:         report.StartTime.Format(time.ANSIC),
+		Version:          JSONSchemaVersion, // 与JSON报告使用同一版本号
+		XMLOutputVersion: "1.05",
+	}
+	if report.ScanType != "" {
+		scanType := "connect"
+		if report.ScanType == "udp" {
+			scanType = "udp"
+		}
+		run.ScanInfo = append(run.ScanInfo, NmapScanInfo{
+			Type:        scanType,
+			Protocol:    report.ScanType,
+			NumServices: report.TotalPorts / max(report.TotalHosts, 1),
+			Services:    report.Ports,
+		})
+	}
+
+	// 主机顺序：先按主机发现的顺序，再补充未经过发现阶段的主机
+	index := make(map[string]int)
+	hostEntry := func(host string) *NmapHost {
+		if i, ok := index[host]; ok {
+			return &run.Hosts[i]
+		}
+		index[host] = len(run.Hosts)
+		run.Hosts = append(run.Hosts, nmapHost(host, report))
+		return &run.Hosts[len(run.Hosts)-1]
+	}
+
+	for _, d := range report.Discovery {
+		entry := hostEntry(d.Host)
+		entry.Status = NmapStatus{State: "down", Reason: "no-response"}
+		if d.Alive {
+			entry.Status = NmapStatus{State: "up", Reason: nmapHostReason(d.Method)}
+			srtt := d.Latency.Microseconds()
+			entry.Times = &NmapTimes{SRTT: srtt, RTTVar: srtt, To: max(srtt*4, 100000)}
+		}
+	}
+	for _, h := range report.Hosts {
+		entry := hostEntry(h.Host)
+		for _, r := range h.Results {
+			entry.Ports = append(entry.Ports, nmapPort(r))
+		}
+	}
+
+	up := 0
+	for _, h := range run.Hosts {
+		if h.Status.State == "up" {
+			up++
+		}
+	}
+	exit := "success"
+	summary := fmt.Sprintf("netscanner done: %d IP addresses (%d hosts up) scanned in %.2f seconds", len(run.Hosts), up, report.Duration.Seconds())
+	if report.Incomplete {
+		exit = "error"
+		summary += " (interrupted)"
+	}
+	run.RunStats = &NmapRunStats{
+		Finished: NmapFinished{
+			Time:    report.EndTime.Unix(),
+			TimeStr: report.EndTime.Format(time.ANSIC),
+			Elapsed: report.Duration.Seconds(),
+			Summary: summary,
+			Exit:    exit,
+		},
+		Hosts: NmapHostStats{Up: up, Down: len(run.Hosts) - up, Total: len(run.Hosts)},
+	}
+
+	return run
+}
+
+// nmapHost 创建主机元素，目标为主机名时同时输出地址和主机名
+func nmapHost(host string, report ScanReport) NmapHost {
+	entry := NmapHost{
+		StartTime: report.StartTime.Unix(),
+		EndTime:   report.EndTime.Unix(),
+		Status:    NmapStatus{State: "up", Reason: "user-set"},
+	}
+
+	addr := strings.Trim(host, "[]")
+	if ip := net.ParseIP(addr); ip != nil {
+		addrType := "ipv4"
+		if ip.To4() == nil {
+			addrType = "ipv6"
+		}
+		entry.Addresses = append(entry.Addresses, NmapAddress{Addr: addr, AddrType: addrType})
+	} else {
+		// 主机名只在地址无法确定时才解析，失败时仍保留主机名
+		if ips, err := net.LookupIP(addr); err == nil && len(ips) > 0 {
+			addrType := "ipv4"
+			if ips[0].To4() == nil {
+				addrType = "ipv6"
+			}
+			entry.Addresses = append(entry.Addresses, NmapAddress{Addr: ips[0].String(), AddrType: addrType})
+		}
+		entry.Hostnames = append(entry.Hostnames, NmapHostname{Name: addr, Type: "user"})
+	}
+	return entry
+}
+
+// nmapHostReason 把主机发现方式转换为 nmap 的 reason
+func nmapHostReason(method string) string {
+	switch {
+	case method == "icmp-echo":
+		return "echo-reply"
+	case strings.HasPrefix(method, "tcp-open"):
+		return "syn-ack"
+	case strings.HasPrefix(method, "tcp-reset"):
+		return "conn-refused"
+	}
+	return "user-set"
+}
+
+// nmapPort 转换单个端口结果
+func nmapPort(r ScanResult) NmapPort {
+	port := NmapPort{
+		Protocol: r.Protocol,
+		PortID:   r.Port,
+		State:    NmapState{State: r.State, Reason: r.Reason},
+	}
+	if port.State.Reason == "" {
+		port.State.Reason = "unknown"
+	}
+
+	if r.Service != "" && r.Service != "unknown" {
+		service := &NmapService{
+			Name:      r.Service,
+			Product:   r.Product,
+			Version:   r.Version,
+			ExtraInfo: r.ExtraInfo,
+			CPE:       r.CPE,
+			Method:    "table",
+			Conf:      3,
+		}
+		if r.Product != "" || r.Banner != "" {
+			service.Method, service.Conf = "probed", 10
+		}
+		// nmap 用 tunnel="ssl" 表示TLS封装的服务
+		if r.TLS != nil {
+			service.Tunnel = "ssl"
+			if service.Name == "https" {
+				service.Name = "http"
+			}
+		}
+		port.Service = service
+	}
+
+	if r.Banner != "" {
+		port.Scripts = append(port.Scripts, NmapScript{ID: "banner", Output: r.Banner})
+	}
+	for _, f := range r.Findings {
+		port.Scripts = append(port.Scripts, nmapScript(f))
+	}
+	return port
+}
+
+// nmapScript 把插件检测结果写为脚本输出，脚本ID为插件名
+func nmapScript(f Finding) NmapScript {
+	output := f.Details
+	if f.ID != "" {
+		output = fmt.Sprintf("[%s] %s", f.ID, f.Details)
+	}
+	if f.Vulnerable {
+		output = fmt.Sprintf("VULNERABLE (%s): %s", f.Severity, f.Details)
+	}
+	if f.Evidence != "" {
+		output += "\n  Evidence: " + strings.ReplaceAll(f.Evidence, "\n", "\n    ")
+	}
+	if f.Remediation != "" {
+		output += "\n  Remediation: " + strings.ReplaceAll(f.Remediation, "\n", "\n    ")
+	}
+
+	script := NmapScript{
+		ID:     f.Plugin,
+		Output: output,
+		Elems: []NmapElem{
+			{Key: "vulnerable", Value: strconv.FormatBool(f.Vulnerable)},
+			{Key: "severity", Value: f.Severity},
+			{Key: "details", Value: f.Details},
+		},
+	}
+	if f.ID != "" {
+		script.Elems = append(script.Elems, NmapElem{Key: "id", Value: f.ID})
+	}
+	if f.CVSS > 0 {
+		script.Elems = append(script.Elems, NmapElem{Key: "cvss", Value: strconv.FormatFloat(f.CVSS, 'f', 1, 64)})
+	}
+	if f.Evidence != "" {
+		script.Elems = append(script.Elems, NmapElem{Key: "evidence", Value: f.Evidence})
+	}
+	if f.Remediation != "" {
+		script.Elems = append(script.Elems, NmapElem{Key: "remediation", Value: f.Remediation})
+	}
+	return script
+}
+
+// GenerateNmapXML 生成 nmap XML 格式的报告
+func GenerateNmapXML(report ScanReport, outputFile string) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+
+	file.WriteString(xml.Header)
+	file.WriteString("<!DOCTYPE nmaprun>\n")
+
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(ToNmapXML(report)); err != nil {
+		return fmt.Errorf("生成报告失败: %v", err)
+	}
+	file.WriteString("\n")
+	return nil
+}
+
+// ReadNmapXML 读取 nmap XML 输出（nmap -oX），转换为扫描报告
+// 只导入状态为 up 的主机；每个主机优先使用IP地址，没有IP地址时使用主机名
+func ReadNmapXML(path string) (ScanReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ScanReport{}, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	var run NmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return ScanReport{}, fmt.Errorf("解析nmap XML失败: %v", err)
+	}
+
+	report := ScanReport{
+		Target:    path,
+		StartTime: time.Unix(run.Start, 0),
+		EndTime:   time.Unix(run.Start, 0),
+	}
+	if run.RunStats != nil && run.RunStats.Finished.Time > 0 {
+		report.EndTime = time.Unix(run.RunStats.Finished.Time, 0)
+		report.Duration = time.Duration(run.RunStats.Finished.Elapsed * float64(time.Second))
+	}
+	if len(run.ScanInfo) > 0 {
+		report.ScanType = run.ScanInfo[0].Protocol
+	}
+	// 同时扫描TCP和UDP时各有一个 scaninfo，端口范围无法用一个表达式表示
+	if len(run.ScanInfo) == 1 {
+		report.Ports = run.ScanInfo[0].Services
+	}
+
+	var counts PortCounts
+	for _, h := range run.Hosts {
+		if h.Status.State != "up" {
+			continue
+		}
+		host := nmapHostAddress(h)
+		if host == "" {
+			continue
+		}
+
+		hr := HostResult{Host: host}
+		for _, extra := range h.ExtraPorts {
+			if extra.Count > 0 {
+				counts.AddN(extra.State, extra.Count)
+			}
+		}
+		for _, p := range h.Ports {
+			r := ScanResult{
+				Host:     host,
+				Port:     p.PortID,
+				Protocol: p.Protocol,
+				State:    p.State.State,
+				Reason:   p.State.Reason,
+				Service:  "unknown",
+			}
+			if IsIPv6(host) {
+				r.IPVersion = "IPv6"
+			} else {
+				r.IPVersion = "IPv4"
+			}
+			if s := p.Service; s != nil {
+				r.Service = s.Name
+				r.Product = s.Product
+				r.Version = s.Version
+				r.ExtraInfo = s.ExtraInfo
+				r.CPE = s.CPE
+				// nmap 把 HTTPS 记为 tunnel="ssl" 的 http
+				if s.Tunnel == "ssl" && s.Name == "http" {
+					r.Service = "https"
+				}
+			}
+			for _, script := range p.Scripts {
+				if script.ID == "banner" {
+					r.Banner = script.Output
+				}
+			}
+
+			counts.Add(r.State, r.IPVersion)
+			if r.State == "open" {
+				hr.OpenPorts++
+			}
+			hr.Results = append(hr.Results, r)
+		}
+
+		report.TotalHosts++
+		report.Hosts = append(report.Hosts, hr)
+	}
+	report.SetPortCounts(counts)
+
+	return report, nil
+}
+
+// nmapHostAddress 选择主机的扫描地址
+func nmapHostAddress(h NmapHost) string {
+	for _, addr := range h.Addresses {
+		if addr.AddrType == "ipv4" || addr.AddrType == "ipv6" {
+			return addr.Addr
+		}
+	}
+	if len(h.Hostnames) > 0 {
+		return h.Hostnames[0].Name
+	}
+	return ""
+}
@@ -0,0 +1,251 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DefaultDiscoveryPorts 主机发现时TCP探测的常用端口
+var DefaultDiscoveryPorts = []int{80, 443, 22, 445, 3389}
+
+// HostStatus 主机发现结果
+type HostStatus struct {
+	Host    string
+	Alive   bool
+	Method  string        // icmp-echo、tcp-open:80、tcp-reset:443 或 assumed
+	Latency time.Duration // 首个有效响应的往返时间
+}
+
+// HostDiscoverer 主机发现器，在端口扫描前过滤掉不在线的主机
+type HostDiscoverer struct {
+	Timeout    time.Duration
+	MaxWorkers int
+	TCPPorts   []int
+	ICMP       bool // 是否尝试ICMP回显请求
+}
+
+// NewHostDiscoverer 创建主机发现器
+func NewHostDiscoverer(timeout time.Duration, maxWorkers int) *HostDiscoverer {
+	return &HostDiscoverer{
+		Timeout:    timeout,
+		MaxWorkers: maxWorkers,
+		TCPPorts:   DefaultDiscoveryPorts,
+		ICMP:       true,
+	}
+}
+
+// AssumeAlive 跳过发现阶段，将所有主机视为在线
+func AssumeAlive(hosts []string) []HostStatus {
+	statuses := make([]HostStatus, len(hosts))
+	for i, h := range hosts {
+		statuses[i] = HostStatus{Host: h, Alive: true, Method: "assumed"}
+	}
+	return statuses
+}
+
+// Discover 并发探测所有主机，返回结果与输入顺序一致
+func (d *HostDiscoverer) Discover(hosts []string) []HostStatus {
+	return d.DiscoverContext(context.Background(), hosts)
+}
+
+// DiscoverContext 并发探测所有主机，ctx 被取消后未探测的主机视为离线
+func (d *HostDiscoverer) DiscoverContext(ctx context.Context, hosts []string) []HostStatus {
+	statuses := make([]HostStatus, len(hosts))
+	var wg sync.WaitGroup
+
+	workers := d.MaxWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				statuses[idx] = d.ProbeContext(ctx, hosts[idx])
+			}
+		}()
+	}
+
+	for i, h := range hosts {
+		statuses[i] = HostStatus{Host: h}
+	}
+
+dispatch:
+	for i := range hosts {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return statuses
+}
+
+// Probe 探测单个主机
+func (d *HostDiscoverer) Probe(host string) HostStatus {
+	return d.ProbeContext(context.Background(), host)
+}
+
+// ProbeContext 探测单个主机，ICMP和TCP探测同时进行，取最先到达的有效响应
+func (d *HostDiscoverer) ProbeContext(ctx context.Context, host string) HostStatus {
+	// 任一探测成功后取消其余探测
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	status := HostStatus{Host: host}
+
+	probes := len(d.TCPPorts)
+	if d.ICMP {
+		probes++
+	}
+	replies := make(chan HostStatus, probes)
+
+	if d.ICMP {
+		go func() { replies <- d.pingICMP(ctx, host) }()
+	}
+	for _, port := range d.TCPPorts {
+		go func(port int) { replies <- d.pingTCP(ctx, host, port) }(port)
+	}
+
+	for i := 0; i < probes; i++ {
+		if reply := <-replies; reply.Alive {
+			return reply
+		}
+	}
+	return status
+}
+
+// pingTCP 通过TCP连接判断主机存活，连接成功或被重置都说明主机在线
+func (d *HostDiscoverer) pingTCP(ctx context.Context, host string, port int) HostStatus {
+	status := HostStatus{Host: host}
+
+	start := time.Now()
+	dialer := net.Dialer{Timeout: d.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	latency := time.Since(start)
+
+	switch {
+	case err == nil:
+		conn.Close()
+		status.Alive = true
+		status.Method = "tcp-open:" + strconv.Itoa(port)
+		status.Latency = latency
+	case errors.Is(err, syscall.ECONNREFUSED):
+		status.Alive = true
+		status.Method = "tcp-reset:" + strconv.Itoa(port)
+		status.Latency = latency
+	}
+	return status
+}
+
+// pingICMP 发送ICMP回显请求
+// 优先使用Linux的非特权数据报ICMP套接字，失败时再尝试原始套接字（需要root）
+func (d *HostDiscoverer) pingICMP(ctx context.Context, host string) HostStatus {
+	status := HostStatus{Host: host}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return status
+	}
+	return d.echo(ctx, status, &addrs[0])
+}
+
+// echo 向已解析的地址发送一次ICMP回显请求并等待应答
+func (d *HostDiscoverer) echo(ctx context.Context, status HostStatus, ipAddr *net.IPAddr) HostStatus {
+	isV4 := ipAddr.IP.To4() != nil
+	network, raw, listenAddr, proto := "udp6", "ip6:ipv6-icmp", "::", 58
+	var echoType icmp.Type = ipv6.ICMPTypeEchoRequest
+	if isV4 {
+		network, raw, listenAddr, proto = "udp4", "ip4:icmp", "0.0.0.0", 1
+		echoType = ipv4.ICMPTypeEcho
+	}
+
+	privileged := false
+	conn, err := icmp.ListenPacket(network, listenAddr)
+	if err != nil {
+		conn, err = icmp.ListenPacket(raw, listenAddr)
+		if err != nil {
+			return status
+		}
+		privileged = true
+	}
+	defer conn.Close()
+
+	// 取消时关闭套接字，打断等待中的读取
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	id := os.Getpid() & 0xffff
+	msg := icmp.Message{
+		Type: echoType,
+		Body: &icmp.Echo{ID: id, Seq: 1, Data: []byte("netscanner")},
+	}
+	payload, err := msg.Marshal(nil)
+	if err != nil {
+		return status
+	}
+
+	var dst net.Addr = &net.UDPAddr{IP: ipAddr.IP, Zone: ipAddr.Zone}
+	if privileged {
+		dst = ipAddr
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(payload, dst); err != nil {
+		return status
+	}
+
+	conn.SetReadDeadline(start.Add(d.Timeout))
+	buffer := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return status
+		}
+		if !sameIP(peer, ipAddr.IP) {
+			continue
+		}
+
+		reply, err := icmp.ParseMessage(proto, buffer[:n])
+		if err != nil {
+			continue
+		}
+		if reply.Type != ipv4.ICMPTypeEchoReply && reply.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		// 原始套接字会收到所有ICMP报文，需要按ID过滤；数据报套接字由内核完成
+		if echo, ok := reply.Body.(*icmp.Echo); ok && (!privileged || echo.ID == id) {
+			status.Alive = true
+			status.Method = "icmp-echo"
+			status.Latency = time.Since(start)
+			return status
+		}
+	}
+}
+
+// sameIP 判断响应来源是否为探测目标
+func sameIP(addr net.Addr, ip net.IP) bool {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.Equal(ip)
+	case *net.IPAddr:
+		return a.IP.Equal(ip)
+	}
+	return false
+}
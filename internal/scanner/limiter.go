@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// limiter 执行 Throttle 中的速率、单主机并发和延迟限制
+type limiter struct {
+	throttle Throttle
+	interval time.Duration // 两次探测之间的最小间隔
+
+	mu    sync.Mutex
+	next  time.Time                // 下一个可用的发送时刻
+	hosts map[string]chan struct{} // 每台主机的并发信号量
+}
+
+// newLimiter 创建限速器，未设置任何限制时返回 nil
+func newLimiter(t Throttle) *limiter {
+	if t.Rate <= 0 && t.MaxPerHost <= 0 && t.ScanDelay <= 0 && t.Jitter <= 0 {
+		return nil
+	}
+
+	l := &limiter{
+		throttle: t,
+		hosts:    make(map[string]chan struct{}),
+	}
+	if t.Rate > 0 {
+		l.interval = time.Second / time.Duration(t.Rate)
+	}
+	return l
+}
+
+// acquire 等待直到可以对 host 发起下一次探测，返回的 release 必须在探测结束后调用
+func (l *limiter) acquire(ctx context.Context, host string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	// 单主机并发
+	release = func() {}
+	if l.throttle.MaxPerHost > 0 {
+		sem := l.hostSlot(host)
+		select {
+		case sem <- struct{}{}:
+			release = func() { <-sem }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := l.pace(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// pace 等待固定延迟、随机抖动和全局速率分配的发送时刻，不占用单主机并发
+// 用于同一次探测中的后续连接（服务探测、TLS握手），这些连接已经持有 acquire 得到的主机名额
+func (l *limiter) pace(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	// 固定延迟 + 随机抖动
+	delay := l.throttle.ScanDelay
+	if l.throttle.Jitter > 0 {
+		delay += rand.N(l.throttle.Jitter)
+	}
+
+	// 全局速率：按固定间隔分配发送时刻
+	if l.interval > 0 {
+		l.mu.Lock()
+		now := time.Now()
+		slot := l.next
+		if slot.Before(now) {
+			slot = now
+		}
+		l.next = slot.Add(l.interval)
+		l.mu.Unlock()
+
+		if wait := time.Until(slot); wait > delay {
+			delay = wait
+		}
+	}
+
+	return sleepContext(ctx, delay)
+}
+
+// hostSlot 获取主机对应的信号量
+func (l *limiter) hostSlot(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.throttle.MaxPerHost)
+		l.hosts[host] = sem
+	}
+	return sem
+}
+
+// sleepContext 可被取消的等待
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+)
+
+// probeFunc 扫描单个 host:port 的函数，被取消时返回错误
+type probeFunc func(ctx context.Context, host string, port int) (ScanResult, error)
+
+// hostPort 扫描任务
+type hostPort struct {
+	host string
+	port int
+}
+
+// streamPool 用固定数量的worker扫描所有 host:port 组合，每完成一个探测就发送到返回的通道
+// 任务按端口优先的顺序分发，同一时刻的连接分散在不同主机上。
+// lim 不为 nil 时每次探测前都要经过限速器。
+// ctx 被取消后停止分发任务，被中断的探测结果会被丢弃；所有worker退出后通道关闭，
+// 调用方必须一直读取到通道关闭为止
+func streamPool(ctx context.Context, hosts []string, ports []int, maxWorkers int, lim *limiter, probe probeFunc) <-chan ScanResult {
+	var wg sync.WaitGroup
+
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+
+	// 创建worker池
+	jobs := make(chan hostPort, maxWorkers)
+	out := make(chan ScanResult, maxWorkers)
+
+	// 启动worker
+	for i := 0; i < maxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				release, err := lim.acquire(ctx, job.host)
+				if err != nil {
+					continue
+				}
+				result, err := probe(ctx, job.host, job.port)
+				release()
+				if err != nil {
+					continue
+				}
+				out <- result
+			}
+		}()
+	}
+
+	// 分发任务，全部完成后关闭结果通道
+	go func() {
+	dispatch:
+		for _, port := range ports {
+			for _, host := range hosts {
+				select {
+				case jobs <- hostPort{host: host, port: port}:
+				case <-ctx.Done():
+					break dispatch
+				}
+			}
+		}
+		close(jobs)
+
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// collect 读取结果通道中的全部结果
+func collect(stream <-chan ScanResult) []ScanResult {
+	var results []ScanResult
+	for result := range stream {
+		results = append(results, result)
+	}
+	return results
+}
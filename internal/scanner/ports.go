@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsePorts 解析端口字符串，如 80,443 或 1-1000，结果去重并排序
+func ParsePorts(portStr string) []int {
+	var ports []int
+	portMap := make(map[int]bool) // 使用map去重
+
+	// 分割逗号分隔的部分
+	parts := strings.Split(portStr, ",")
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		// 判断是否是范围
+		if strings.Contains(part, "-") {
+			rangeParts := strings.Split(part, "-")
+			if len(rangeParts) == 2 {
+				start, err1 := strconv.Atoi(strings.TrimSpace(rangeParts[0]))
+				end, err2 := strconv.Atoi(strings.TrimSpace(rangeParts[1]))
+
+				if err1 == nil && err2 == nil && start > 0 && end <= 65535 && start <= end {
+					for port := start; port <= end; port++ {
+						if port > 0 && port <= 65535 {
+							portMap[port] = true
+						}
+					}
+				}
+			}
+		} else {
+			// 单个端口
+			if port, err := strconv.Atoi(part); err == nil && port > 0 && port <= 65535 {
+				portMap[port] = true
+			}
+		}
+	}
+
+	// 将map转换为切片
+	for port := range portMap {
+		ports = append(ports, port)
+	}
+
+	// 排序（可选，便于阅读）
+	sort.Ints(ports)
+
+	return ports
+}
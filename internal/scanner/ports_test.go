@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []int
+	}{
+		{name: "单个端口", input: "80", want: []int{80}},
+		{name: "逗号列表排序", input: "443,22,80", want: []int{22, 80, 443}},
+		{name: "范围", input: "20-23", want: []int{20, 21, 22, 23}},
+		{name: "范围和列表去重", input: "21-23,22,23", want: []int{21, 22, 23}},
+		{name: "空白", input: " 22 , 80 - 81 ", want: []int{22, 80, 81}},
+		{name: "边界端口", input: "1,65535", want: []int{1, 65535}},
+		{name: "单端口范围", input: "8080-8080", want: []int{8080}},
+		{name: "空字符串", input: "", want: nil},
+		{name: "只有逗号", input: ",,,", want: nil},
+		{name: "端口0", input: "0", want: nil},
+		{name: "超出范围", input: "65536", want: nil},
+		{name: "负数", input: "-1", want: nil},
+		{name: "非数字", input: "http,ssh", want: nil},
+		{name: "反向范围", input: "100-90", want: nil},
+		{name: "范围起点为0", input: "0-2", want: nil},
+		{name: "范围终点越界", input: "65530-65536", want: nil},
+		{name: "范围缺少终点", input: "80-", want: nil},
+		{name: "多个连字符", input: "1-2-3", want: nil},
+		{name: "无效项不影响有效项", input: "22,abc,80-x,443", want: []int{22, 443}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParsePorts(tt.input)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParsePorts(%q) = %v，期望 %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePortsFullRange(t *testing.T) {
+	ports := ParsePorts("1-65535")
+	if len(ports) != 65535 || ports[0] != 1 || ports[len(ports)-1] != 65535 {
+		t.Errorf("ParsePorts(\"1-65535\") 得到 %d 个端口", len(ports))
+	}
+}
@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxProbeResponse 单个探测最多读取的响应字节数
+const maxProbeResponse = 64 * 1024
+
+// detectService 用服务探测库识别开放端口上的服务，识别成功时填充结果并返回 true
+// banner 是连接后服务主动发送的数据（NULL探测的响应）。每个探测新建连接，
+// 连接前经过 lim 的延迟和速率限制；探测累计耗时超过 ProbeBudget 后不再发送
+func (s *TCPScanner) detectService(ctx context.Context, lim *limiter, result *ScanResult, banner []byte) bool {
+	db := s.ServiceDB
+	if db == nil {
+		return false
+	}
+	budget := s.ProbeBudget
+	if budget <= 0 {
+		budget = 4 * s.Timeout
+	}
+	var spent time.Duration
+
+	var soft *ServiceInfo
+	if null := db.Probe("NULL"); null != nil && len(banner) > 0 {
+		if info, ok := db.Match(null, banner); ok {
+			if !info.Soft {
+				applyServiceInfo(result, info)
+				return true
+			}
+			soft = &info
+		}
+	}
+
+	for _, probe := range db.ProbesFor(result.Port, s.ProbeIntensity) {
+		if ctx.Err() != nil {
+			break
+		}
+		// 已经软匹配到服务类型时，只尝试还能给出该服务硬匹配的探测
+		if soft != nil && !probe.hasService(soft.Service) {
+			continue
+		}
+		if spent >= budget || lim.pace(ctx) != nil {
+			break
+		}
+
+		start := time.Now()
+		response := s.sendProbe(ctx, result.Host, result.Port, probe, min(s.Timeout, budget-spent))
+		spent += time.Since(start)
+		if len(response) == 0 {
+			continue
+		}
+		if result.Banner == "" {
+			result.Banner = firstLine(response)
+		}
+
+		if info, ok := db.Match(probe, response); ok {
+			if !info.Soft {
+				applyServiceInfo(result, info)
+				return true
+			}
+			if soft == nil {
+				soft = &info
+			}
+		}
+	}
+
+	if soft != nil {
+		applyServiceInfo(result, *soft)
+		return true
+	}
+	return false
+}
+
+// sendProbe 新建连接发送探测载荷并读取响应，timeout 限制连接、写入和等待响应的时间
+func (s *TCPScanner) sendProbe(ctx context.Context, host string, port int, probe *ServiceProbe, timeout time.Duration) []byte {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if len(probe.Payload) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := conn.Write(probe.Payload); err != nil {
+			return nil
+		}
+	}
+
+	wait := timeout
+	if probe.TotalWait > 0 && probe.TotalWait < wait {
+		wait = probe.TotalWait
+	}
+
+	// 等待首个响应，之后短暂等待剩余数据，直到对端关闭或没有更多数据
+	var response bytes.Buffer
+	buffer := make([]byte, 4096)
+	deadline := time.Now().Add(wait)
+	for response.Len() < maxProbeResponse {
+		conn.SetReadDeadline(deadline)
+		n, err := conn.Read(buffer)
+		response.Write(buffer[:n])
+		if err != nil {
+			// 对端关闭、超时或出错都结束读取
+			break
+		}
+		deadline = time.Now().Add(300 * time.Millisecond)
+	}
+
+	return response.Bytes()
+}
+
+// hasService 探测中是否有该服务的硬匹配规则
+func (p *ServiceProbe) hasService(service string) bool {
+	for _, m := range p.Matches {
+		if !m.Soft && m.Service == service {
+			return true
+		}
+	}
+	return false
+}
+
+// applyServiceInfo 把识别结果写入扫描结果
+func applyServiceInfo(result *ScanResult, info ServiceInfo) {
+	result.Service = info.Service
+	result.Product = info.Product
+	result.Version = info.Version
+	result.ExtraInfo = info.ExtraInfo
+	result.CPE = info.CPE
+}
+
+// firstLine 取响应的第一行可打印内容作为banner
+func firstLine(data []byte) string {
+	line, _, _ := strings.Cut(string(data), "\n")
+	return printableBanner([]byte(line))
+}
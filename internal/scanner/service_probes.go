@@ -0,0 +1,473 @@
+package scanner
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed service-probes.txt
+var defaultServiceProbes string
+
+// ServiceProbe 一个服务探测：发送的载荷以及用于识别响应的匹配规则
+type ServiceProbe struct {
+	Protocol  string // TCP 或 UDP
+	Name      string
+	Payload   []byte
+	Ports     map[int]bool // 优先使用该探测的端口
+	SSLPorts  map[int]bool // 通常跑在TLS之上的端口
+	Rarity    int          // 1-9，越大越少见，超过探测强度的探测只在端口匹配时使用
+	TotalWait time.Duration
+	Fallback  []string // 本探测没有匹配时继续尝试这些探测的规则
+	Matches   []*ServiceMatch
+}
+
+// ServiceMatch 一条 match 或 softmatch 规则
+type ServiceMatch struct {
+	Service  string
+	Pattern  *regexp.Regexp
+	Soft     bool              // softmatch 只确定服务类型，不提取版本
+	Template map[string]string // 版本信息模板，键为 p、v、i、h、o、d
+	CPE      []string
+}
+
+// ServiceInfo 服务识别结果
+type ServiceInfo struct {
+	Service    string
+	Product    string
+	Version    string
+	ExtraInfo  string
+	Hostname   string
+	OS         string
+	DeviceType string
+	CPE        []string
+	Probe      string // 命中的探测名称
+	Soft       bool
+}
+
+// ServiceDB 服务探测库
+type ServiceDB struct {
+	Probes  []*ServiceProbe
+	Skipped int // 因正则语法不兼容而跳过的规则数
+
+	byName map[string]*ServiceProbe
+}
+
+var (
+	defaultDBOnce sync.Once
+	defaultDB     *ServiceDB
+)
+
+// DefaultServiceDB 返回内置的服务探测库
+func DefaultServiceDB() *ServiceDB {
+	defaultDBOnce.Do(func() {
+		db, err := ParseServiceProbes(strings.NewReader(defaultServiceProbes))
+		if err != nil {
+			panic(fmt.Sprintf("内置服务探测库格式错误: %v", err))
+		}
+		defaultDB = db
+	})
+	return defaultDB
+}
+
+// LoadServiceDB 从文件加载 nmap-service-probes 格式的探测库
+func LoadServiceDB(path string) (*ServiceDB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开探测库失败: %v", err)
+	}
+	defer file.Close()
+
+	return ParseServiceProbes(file)
+}
+
+// ParseServiceProbes 解析 nmap-service-probes 格式的探测库
+func ParseServiceProbes(r io.Reader) (*ServiceDB, error) {
+	db := &ServiceDB{byName: make(map[string]*ServiceProbe)}
+	var current *ServiceProbe
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		if directive == "Probe" {
+			probe, err := parseProbeLine(rest)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行: %v", lineNo, err)
+			}
+			db.Probes = append(db.Probes, probe)
+			db.byName[probe.Name] = probe
+			current = probe
+			continue
+		}
+
+		if directive == "Exclude" {
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("第 %d 行: %s 出现在 Probe 之前", lineNo, directive)
+		}
+
+		switch directive {
+		case "match", "softmatch":
+			m, err := parseMatchLine(rest, directive == "softmatch")
+			if err != nil {
+				// 大多数错误是Go正则不支持的PCRE语法，跳过该规则即可
+				db.Skipped++
+				continue
+			}
+			current.Matches = append(current.Matches, m)
+		case "ports":
+			current.Ports = parsePortSet(rest)
+		case "sslports":
+			current.SSLPorts = parsePortSet(rest)
+		case "rarity":
+			current.Rarity, _ = strconv.Atoi(rest)
+		case "totalwaitms":
+			if ms, err := strconv.Atoi(rest); err == nil {
+				current.TotalWait = time.Duration(ms) * time.Millisecond
+			}
+		case "fallback":
+			current.Fallback = strings.Split(rest, ",")
+		case "tcpwrappedms":
+			// 未使用
+		default:
+			return nil, fmt.Errorf("第 %d 行: 未知指令 %s", lineNo, directive)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("读取探测库失败: %v", err)
+	}
+
+	return db, nil
+}
+
+// parseProbeLine 解析 "TCP GetRequest q|GET / HTTP/1.0\r\n\r\n| [no-payload]"
+func parseProbeLine(rest string) (*ServiceProbe, error) {
+	fields := strings.SplitN(rest, " ", 3)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "q") || len(fields[2]) < 3 {
+		return nil, fmt.Errorf("无效的 Probe 指令: %s", rest)
+	}
+
+	delim := fields[2][1]
+	end := strings.IndexByte(fields[2][2:], delim)
+	if end < 0 {
+		return nil, fmt.Errorf("Probe 载荷缺少结束分隔符: %s", rest)
+	}
+
+	return &ServiceProbe{
+		Protocol: strings.ToUpper(fields[0]),
+		Name:     fields[1],
+		Payload:  unescapeProbe(fields[2][2 : 2+end]),
+		Rarity:   1,
+	}, nil
+}
+
+// parseMatchLine 解析 "ssh m|^SSH-([\d.]+)|s p/OpenSSH/ v/$1/ cpe:/a:openbsd:openssh/"
+func parseMatchLine(rest string, soft bool) (*ServiceMatch, error) {
+	service, rest, ok := strings.Cut(rest, " ")
+	if !ok || len(rest) < 3 || rest[0] != 'm' {
+		return nil, fmt.Errorf("无效的 match 指令")
+	}
+
+	delim := rest[1]
+	end := strings.IndexByte(rest[2:], delim)
+	if end < 0 {
+		return nil, fmt.Errorf("match 正则缺少结束分隔符")
+	}
+	expr := rest[2 : 2+end]
+	rest = rest[3+end:]
+
+	// 正则后紧跟的标志
+	flags := ""
+	for len(rest) > 0 && (rest[0] == 's' || rest[0] == 'i') {
+		flags += rest[:1]
+		rest = rest[1:]
+	}
+
+	pattern, err := compileProbeRegexp(expr, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &ServiceMatch{
+		Service:  service,
+		Pattern:  pattern,
+		Soft:     soft,
+		Template: make(map[string]string),
+	}
+
+	// 版本信息字段：p/.../ v/.../ i/.../ h/.../ o/.../ d/.../ cpe:/.../[a]
+	rest = strings.TrimSpace(rest)
+	for rest != "" {
+		var key string
+		switch {
+		case strings.HasPrefix(rest, "cpe:"):
+			key, rest = "cpe", rest[4:]
+		default:
+			key, rest = rest[:1], rest[1:]
+		}
+		if rest == "" {
+			break
+		}
+		d := rest[0]
+		end := strings.IndexByte(rest[1:], d)
+		if end < 0 {
+			break
+		}
+		value := rest[1 : 1+end]
+		rest = strings.TrimLeft(rest[2+end:], "a")
+		rest = strings.TrimSpace(rest)
+
+		if key == "cpe" {
+			m.CPE = append(m.CPE, "cpe:/"+value)
+		} else {
+			m.Template[key] = value
+		}
+	}
+
+	return m, nil
+}
+
+// compileProbeRegexp 把 nmap 的PCRE正则转换为Go正则
+func compileProbeRegexp(expr, flags string) (*regexp.Regexp, error) {
+	// \Z 在PCRE中匹配结尾或结尾前的换行
+	expr = strings.ReplaceAll(expr, `\Z`, `\n?\z`)
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// parsePortSet 解析 "80,443,8000-8010"
+func parsePortSet(s string) map[int]bool {
+	ports := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil {
+				continue
+			}
+		}
+		for p := start; p <= end && p <= 65535; p++ {
+			ports[p] = true
+		}
+	}
+	return ports
+}
+
+// unescapeProbe 处理载荷中的C风格转义：\r \n \t \0 \xHH \\ 等
+func unescapeProbe(s string) []byte {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'r':
+			out = append(out, '\r')
+		case 'n':
+			out = append(out, '\n')
+		case 't':
+			out = append(out, '\t')
+		case '0':
+			out = append(out, 0)
+		case 'a':
+			out = append(out, '\a')
+		case 'f':
+			out = append(out, '\f')
+		case 'v':
+			out = append(out, '\v')
+		case 'x':
+			if i+2 < len(s) {
+				if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					out = append(out, byte(b))
+					i += 2
+					continue
+				}
+			}
+			out = append(out, 'x')
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return out
+}
+
+// Probe 按名称查找探测
+func (db *ServiceDB) Probe(name string) *ServiceProbe {
+	return db.byName[name]
+}
+
+// ProbesFor 返回对指定端口要发送的TCP探测（不含NULL探测）
+// 端口在探测 ports/sslports 列表中的优先，其余按稀有度排序，稀有度超过 intensity 的不发送
+func (db *ServiceDB) ProbesFor(port, intensity int) []*ServiceProbe {
+	var preferred, others []*ServiceProbe
+	for _, p := range db.Probes {
+		if p.Protocol != "TCP" || p.Name == "NULL" {
+			continue
+		}
+		switch {
+		case p.Ports[port] || p.SSLPorts[port]:
+			preferred = append(preferred, p)
+		case p.Rarity <= intensity:
+			others = append(others, p)
+		}
+	}
+	sort.SliceStable(others, func(i, j int) bool { return others[i].Rarity < others[j].Rarity })
+	return append(preferred, others...)
+}
+
+// Match 用探测（及其 fallback）的规则匹配响应，硬匹配优先于软匹配
+func (db *ServiceDB) Match(probe *ServiceProbe, response []byte) (ServiceInfo, bool) {
+	if len(response) == 0 {
+		return ServiceInfo{}, false
+	}
+
+	// 逐字节映射为字符，使正则中的 \xHH 能匹配任意原始字节
+	text := latin1(response)
+
+	candidates := []*ServiceProbe{probe}
+	for _, name := range probe.Fallback {
+		if fb := db.byName[strings.TrimSpace(name)]; fb != nil {
+			candidates = append(candidates, fb)
+		}
+	}
+	// NULL探测的规则对任何响应都有参考意义（有些服务对任意输入都回同样的banner）
+	if null := db.byName["NULL"]; null != nil && null != probe {
+		candidates = append(candidates, null)
+	}
+
+	var soft *ServiceInfo
+	for _, p := range candidates {
+		for _, m := range p.Matches {
+			groups := m.Pattern.FindStringSubmatch(text)
+			if groups == nil {
+				continue
+			}
+			info := m.apply(groups)
+			info.Probe = probe.Name
+			if !m.Soft {
+				return info, true
+			}
+			if soft == nil {
+				soft = &info
+			}
+		}
+	}
+
+	if soft != nil {
+		return *soft, true
+	}
+	return ServiceInfo{}, false
+}
+
+// apply 根据匹配到的分组填充版本信息
+func (m *ServiceMatch) apply(groups []string) ServiceInfo {
+	info := ServiceInfo{
+		Service:    m.Service,
+		Soft:       m.Soft,
+		Product:    substitute(m.Template["p"], groups),
+		Version:    substitute(m.Template["v"], groups),
+		ExtraInfo:  substitute(m.Template["i"], groups),
+		Hostname:   substitute(m.Template["h"], groups),
+		OS:         substitute(m.Template["o"], groups),
+		DeviceType: substitute(m.Template["d"], groups),
+	}
+	for _, cpe := range m.CPE {
+		info.CPE = append(info.CPE, substitute(cpe, groups))
+	}
+	return info
+}
+
+// substituteRe 匹配 $1、$P(1)、$SUBST(1,"a","b")、$I(1,">")
+var substituteRe = regexp.MustCompile(`\$(\d)|\$P\((\d)\)|\$SUBST\((\d),"([^"]*)","([^"]*)"\)|\$I\((\d),"([<>])"\)`)
+
+// substitute 把模板中的分组引用替换为匹配内容
+func substitute(tmpl string, groups []string) string {
+	if tmpl == "" {
+		return ""
+	}
+	group := func(s string) string {
+		n, _ := strconv.Atoi(s)
+		if n < len(groups) {
+			return groups[n]
+		}
+		return ""
+	}
+
+	out := substituteRe.ReplaceAllStringFunc(tmpl, func(ref string) string {
+		sub := substituteRe.FindStringSubmatch(ref)
+		switch {
+		case sub[1] != "":
+			return printableOnly(group(sub[1]))
+		case sub[2] != "":
+			return printableOnly(group(sub[2]))
+		case sub[3] != "":
+			return strings.ReplaceAll(printableOnly(group(sub[3])), sub[4], sub[5])
+		case sub[6] != "":
+			return strconv.FormatUint(unpackInt(group(sub[6]), sub[7] == "<"), 10)
+		}
+		return ""
+	})
+	return strings.TrimSpace(out)
+}
+
+// unpackInt 把匹配到的原始字节解释为无符号整数
+func unpackInt(s string, littleEndian bool) uint64 {
+	var n uint64
+	runes := []rune(s)
+	for i := range runes {
+		b := runes[i]
+		if littleEndian {
+			b = runes[len(runes)-1-i]
+		}
+		n = n<<8 | uint64(b&0xff)
+	}
+	return n
+}
+
+// latin1 逐字节转换为字符串
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// printableOnly 只保留可打印ASCII字符
+func printableOnly(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
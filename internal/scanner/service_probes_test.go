@@ -0,0 +1,426 @@
+package scanner
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProbes 覆盖各类指令的探测库片段
+const testProbes = `# 测试用探测库
+Exclude T:9100-9107
+
+Probe TCP NULL q||
+totalwaitms 6000
+match ssh m|^SSH-([\d.]+)-OpenSSH[_-]([\w.]+)[ -]*([^\r\n]*)\r?\n|s p/OpenSSH/ v/$2/ i/$3 protocol $1/ cpe:/a:openbsd:openssh:$2/
+match ftp m|^220 ([\w.-]+) FTP server ready\r\n|i p/Generic FTP/ h/$1/
+softmatch ftp m|^220[ -]|
+match bad m|(?<=x)y| p/不兼容的PCRE/
+
+Probe TCP GetRequest q|GET / HTTP/1.0\r\n\r\n|
+rarity 1
+ports 80,8000-8002
+sslports 443
+match http m|^HTTP/1\.[01] \d\d\d .*\r\nServer: nginx/([\d.]+)\r\n|s p/nginx/ v/$1/ cpe:/a:igor_sysoev:nginx:$1/
+softmatch http m|^HTTP/1\.[01] \d\d\d|
+
+Probe TCP Binary q|\x01\x02\0\\|
+rarity 8
+fallback GetRequest
+match bin m|^\x00\x01(..)| p/Binary/ v/$I(1,">")/ i/le=$I(1,"<")/
+match subst m|^SUB ([\w_]+)\Z| p/$SUBST(1,"_",".")/ v/$9/
+
+Probe UDP DNSStatus q|\0\0\x10\0\0\0\0\0\0\0\0\0|
+rarity 2
+`
+
+func mustParseProbes(t *testing.T, data string) *ServiceDB {
+	t.Helper()
+	db, err := ParseServiceProbes(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("解析探测库失败: %v", err)
+	}
+	return db
+}
+
+func TestParseServiceProbes(t *testing.T) {
+	db := mustParseProbes(t, testProbes)
+
+	if len(db.Probes) != 4 {
+		t.Fatalf("解析出 %d 个探测，期望 4", len(db.Probes))
+	}
+	if db.Skipped != 1 {
+		t.Errorf("跳过 %d 条规则，期望 1（不支持的后行断言）", db.Skipped)
+	}
+
+	null := db.Probe("NULL")
+	if null == nil || len(null.Payload) != 0 || null.TotalWait != 6*time.Second || len(null.Matches) != 3 {
+		t.Fatalf("NULL 探测解析错误: %+v", null)
+	}
+
+	get := db.Probe("GetRequest")
+	if get == nil {
+		t.Fatal("缺少 GetRequest 探测")
+	}
+	if string(get.Payload) != "GET / HTTP/1.0\r\n\r\n" {
+		t.Errorf("GetRequest 载荷 = %q", get.Payload)
+	}
+	for _, port := range []int{80, 8000, 8001, 8002} {
+		if !get.Ports[port] {
+			t.Errorf("GetRequest 缺少端口 %d", port)
+		}
+	}
+	if get.Ports[8003] || !get.SSLPorts[443] {
+		t.Errorf("GetRequest 端口解析错误: ports=%v sslports=%v", get.Ports, get.SSLPorts)
+	}
+
+	bin := db.Probe("Binary")
+	if !bytes.Equal(bin.Payload, []byte{1, 2, 0, '\\'}) {
+		t.Errorf("Binary 载荷 = %v", bin.Payload)
+	}
+	if bin.Rarity != 8 || !slices.Equal(bin.Fallback, []string{"GetRequest"}) {
+		t.Errorf("Binary 稀有度或 fallback 解析错误: %+v", bin)
+	}
+
+	if udp := db.Probe("DNSStatus"); udp == nil || udp.Protocol != "UDP" || len(udp.Payload) != 12 {
+		t.Errorf("UDP 探测解析错误: %+v", udp)
+	}
+}
+
+func TestParseServiceProbesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "match在Probe之前", data: "match ssh m|^SSH|\n"},
+		{name: "Probe缺少载荷", data: "Probe TCP NULL\n"},
+		{name: "Probe载荷未以q开头", data: "Probe TCP NULL x||\n"},
+		{name: "Probe载荷过短", data: "Probe TCP NULL q|\n"},
+		{name: "Probe载荷未结束", data: "Probe TCP GetRequest q|GET / \n"},
+		{name: "未知指令", data: "Probe TCP NULL q||\nbogus 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if db, err := ParseServiceProbes(strings.NewReader(tt.data)); err == nil {
+				t.Errorf("期望返回错误，得到 %d 个探测", len(db.Probes))
+			}
+		})
+	}
+}
+
+func TestParseServiceProbesMalformedMatches(t *testing.T) {
+	// 格式错误的 match 只跳过该规则，不能让解析失败或崩溃
+	lines := []string{
+		"match",
+		"match ssh",
+		"match ssh m",
+		"match ssh m|",
+		"match ssh x|^SSH|",
+		"match ssh m|^SSH",
+		"match ssh m|(unclosed|",
+		"softmatch ssh m|[z-a]|",
+	}
+	data := "Probe TCP NULL q||\n" + strings.Join(lines, "\n") + "\n"
+	db := mustParseProbes(t, data)
+	if got := len(db.Probe("NULL").Matches); got != 0 {
+		t.Errorf("格式错误的规则被接受了 %d 条", got)
+	}
+	if db.Skipped != len(lines) {
+		t.Errorf("跳过 %d 条规则，期望 %d", db.Skipped, len(lines))
+	}
+}
+
+func TestParseMatchLineVersionFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		template map[string]string
+		cpe      []string
+	}{
+		{
+			name:     "全部字段",
+			line:     `svc m|^x| p/Prod/ v/1.0/ i/extra/ h/host/ o/Linux/ d/router/ cpe:/a:v:p:1.0/a`,
+			template: map[string]string{"p": "Prod", "v": "1.0", "i": "extra", "h": "host", "o": "Linux", "d": "router"},
+			cpe:      []string{"cpe:/a:v:p:1.0"},
+		},
+		{
+			name:     "其他分隔符",
+			line:     `svc m=^x= p|a/b| v=2=`,
+			template: map[string]string{"p": "a/b", "v": "2"},
+		},
+		{
+			name:     "字段被截断",
+			line:     `svc m|^x| p/Prod/ v/1.`,
+			template: map[string]string{"p": "Prod"},
+		},
+		{
+			name:     "字段只有键",
+			line:     `svc m|^x| p`,
+			template: map[string]string{},
+		},
+		{
+			name:     "多个CPE",
+			line:     `svc m|^x| cpe:/a:x:y/ cpe:/o:linux:linux_kernel/a`,
+			template: map[string]string{},
+			cpe:      []string{"cpe:/a:x:y", "cpe:/o:linux:linux_kernel"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseMatchLine(tt.line, false)
+			if err != nil {
+				t.Fatalf("parseMatchLine(%q) 返回错误: %v", tt.line, err)
+			}
+			if len(m.Template) != len(tt.template) {
+				t.Errorf("模板 = %v，期望 %v", m.Template, tt.template)
+			}
+			for k, v := range tt.template {
+				if m.Template[k] != v {
+					t.Errorf("模板 %s = %q，期望 %q", k, m.Template[k], v)
+				}
+			}
+			if !slices.Equal(m.CPE, tt.cpe) {
+				t.Errorf("CPE = %v，期望 %v", m.CPE, tt.cpe)
+			}
+		})
+	}
+}
+
+func TestUnescapeProbe(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+	}{
+		{in: `GET / HTTP/1.0\r\n\r\n`, want: []byte("GET / HTTP/1.0\r\n\r\n")},
+		{in: `\0\x00\xff\t`, want: []byte{0, 0, 0xff, '\t'}},
+		{in: `\a\f\v\\`, want: []byte{'\a', '\f', '\v', '\\'}},
+		{in: `\x4`, want: []byte("x4")},
+		{in: `\xzz`, want: []byte("xzz")},
+		{in: `\x`, want: []byte("x")},
+		{in: `\q`, want: []byte("q")},
+		{in: `abc\`, want: []byte(`abc\`)},
+		{in: ``, want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := unescapeProbe(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("unescapeProbe(%q) = %q，期望 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePortSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{in: "22", want: []int{22}},
+		{in: "80, 443,8000-8002", want: []int{80, 443, 8000, 8001, 8002}},
+		{in: "65534-70000", want: []int{65534, 65535}},
+		{in: "10-5", want: nil},
+		{in: "x,1-,-3,,", want: nil},
+		{in: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		got := parsePortSet(tt.in)
+		var ports []int
+		for p := range got {
+			ports = append(ports, p)
+		}
+		slices.Sort(ports)
+		if !slices.Equal(ports, tt.want) {
+			t.Errorf("parsePortSet(%q) = %v，期望 %v", tt.in, ports, tt.want)
+		}
+	}
+}
+
+func TestProbesFor(t *testing.T) {
+	db := mustParseProbes(t, testProbes)
+
+	names := func(probes []*ServiceProbe) []string {
+		var out []string
+		for _, p := range probes {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		port      int
+		intensity int
+		want      []string
+	}{
+		{name: "默认强度", port: 22, intensity: 7, want: []string{"GetRequest"}},
+		{name: "最高强度按稀有度排序", port: 22, intensity: 9, want: []string{"GetRequest", "Binary"}},
+		{name: "端口匹配不受强度限制", port: 8001, intensity: 0, want: []string{"GetRequest"}},
+		{name: "TLS端口", port: 443, intensity: 0, want: []string{"GetRequest"}},
+		{name: "强度0且端口不匹配", port: 22, intensity: 0, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(db.ProbesFor(tt.port, tt.intensity))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ProbesFor(%d, %d) = %v，期望 %v", tt.port, tt.intensity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceDBMatch(t *testing.T) {
+	db := mustParseProbes(t, testProbes)
+
+	tests := []struct {
+		name     string
+		probe    string
+		response string
+		ok       bool
+		want     ServiceInfo
+	}{
+		{
+			name:     "硬匹配提取版本",
+			probe:    "NULL",
+			response: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1\r\n",
+			ok:       true,
+			want: ServiceInfo{Service: "ssh", Product: "OpenSSH", Version: "8.9p1", ExtraInfo: "Ubuntu-3ubuntu0.1 protocol 2.0",
+				CPE: []string{"cpe:/a:openbsd:openssh:8.9p1"}, Probe: "NULL"},
+		},
+		{
+			name:     "忽略大小写标志",
+			probe:    "NULL",
+			response: "220 ftp.example.com ftp SERVER READY\r\n",
+			ok:       true,
+			want:     ServiceInfo{Service: "ftp", Product: "Generic FTP", Hostname: "ftp.example.com", Probe: "NULL"},
+		},
+		{
+			name:     "只有软匹配",
+			probe:    "NULL",
+			response: "220-Welcome\r\n",
+			ok:       true,
+			want:     ServiceInfo{Service: "ftp", Soft: true, Probe: "NULL"},
+		},
+		{
+			name:     "硬匹配优先于先出现的软匹配",
+			probe:    "GetRequest",
+			response: "HTTP/1.1 200 OK\r\nServer: nginx/1.24.0\r\n\r\n",
+			ok:       true,
+			want:     ServiceInfo{Service: "http", Product: "nginx", Version: "1.24.0", CPE: []string{"cpe:/a:igor_sysoev:nginx:1.24.0"}, Probe: "GetRequest"},
+		},
+		{
+			name:     "fallback探测的规则",
+			probe:    "Binary",
+			response: "HTTP/1.0 404 Not Found\r\n\r\n",
+			ok:       true,
+			want:     ServiceInfo{Service: "http", Soft: true, Probe: "Binary"},
+		},
+		{
+			name:     "任意探测都参考NULL规则",
+			probe:    "GetRequest",
+			response: "SSH-1.99-OpenSSH_3.9p1\n",
+			ok:       true,
+			want:     ServiceInfo{Service: "ssh", Product: "OpenSSH", Version: "3.9p1", ExtraInfo: "protocol 1.99", CPE: []string{"cpe:/a:openbsd:openssh:3.9p1"}, Probe: "GetRequest"},
+		},
+		{
+			name:     "原始字节和整数解包",
+			probe:    "Binary",
+			response: "\x00\x01\x01\x02rest",
+			ok:       true,
+			want:     ServiceInfo{Service: "bin", Product: "Binary", Version: "258", ExtraInfo: "le=513", Probe: "Binary"},
+		},
+		{
+			name:     "SUBST和越界分组",
+			probe:    "Binary",
+			response: "SUB a_b_c\n",
+			ok:       true,
+			want:     ServiceInfo{Service: "subst", Product: "a.b.c", Probe: "Binary"},
+		},
+		{name: "空响应", probe: "NULL", response: "", ok: false},
+		{name: "不匹配", probe: "GetRequest", response: "\xff\xfe\x00garbage", ok: false},
+		{name: "截断的banner", probe: "NULL", response: "SSH-2.0-OpenSSH_8", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok := db.Match(db.Probe(tt.probe), []byte(tt.response))
+			if ok != tt.ok {
+				t.Fatalf("Match(%q) ok = %v，期望 %v（%+v）", tt.response, ok, tt.ok, info)
+			}
+			if !ok {
+				return
+			}
+			if info.Service != tt.want.Service || info.Product != tt.want.Product || info.Version != tt.want.Version ||
+				info.ExtraInfo != tt.want.ExtraInfo || info.Hostname != tt.want.Hostname || info.Soft != tt.want.Soft ||
+				info.Probe != tt.want.Probe || !slices.Equal(info.CPE, tt.want.CPE) {
+				t.Errorf("Match(%q) = %+v，期望 %+v", tt.response, info, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	groups := []string{"all", "ab\x01c", "x_y", "\x01\x00"}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{tmpl: "", want: ""},
+		{tmpl: "v$1", want: "vabc"},
+		{tmpl: "$P(1)", want: "abc"},
+		{tmpl: `$SUBST(2,"_",".")`, want: "x.y"},
+		{tmpl: `$I(3,">")`, want: "256"},
+		{tmpl: `$I(3,"<")`, want: "1"},
+		{tmpl: "$9 tail", want: "tail"},
+		{tmpl: "  $2  ", want: "x_y"},
+	}
+
+	for _, tt := range tests {
+		if got := substitute(tt.tmpl, groups); got != tt.want {
+			t.Errorf("substitute(%q) = %q，期望 %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultServiceDB(t *testing.T) {
+	db := DefaultServiceDB()
+	if db.Probe("NULL") == nil || db.Probe("GetRequest") == nil {
+		t.Fatal("内置探测库缺少 NULL 或 GetRequest 探测")
+	}
+
+	tests := []struct {
+		probe    string
+		response string
+		service  string
+		product  string
+		version  string
+	}{
+		{probe: "NULL", response: "SSH-2.0-OpenSSH_9.6\r\n", service: "ssh", product: "OpenSSH", version: "9.6"},
+		{probe: "NULL", response: "J\x00\x00\x00\x0a8.0.36\x00\x01\x00\x00\x00", service: "mysql", product: "MySQL", version: "8.0.36"},
+		{probe: "RedisPing", response: "+PONG\r\n", service: "redis", product: "Redis key-value store"},
+		{probe: "RedisPing", response: "-NOAUTH Authentication required.\r\n", service: "redis", product: "Redis key-value store"},
+	}
+	for _, tt := range tests {
+		info, ok := db.Match(db.Probe(tt.probe), []byte(tt.response))
+		if !ok || info.Service != tt.service || info.Product != tt.product || info.Version != tt.version {
+			t.Errorf("内置探测库识别 %q = %+v (%v)，期望 %s %s %s", tt.response, info, ok, tt.service, tt.product, tt.version)
+		}
+	}
+}
+
+func FuzzServiceDBMatch(f *testing.F) {
+	db := DefaultServiceDB()
+	f.Add([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nServer: nginx/1.24.0\r\n\r\n"))
+	f.Add([]byte("J\x00\x00\x00\x0a8.0.36\x00"))
+	f.Add([]byte{0xff, 0x00, 0x80})
+	f.Fuzz(func(t *testing.T, response []byte) {
+		for _, probe := range db.Probes {
+			db.Match(probe, response)
+		}
+	})
+}
@@ -1,38 +1,87 @@
 package scanner
 
 import (
+	"context"
+	"errors"
 	"net"
 	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 )
 
 // ScanResult 存储扫描结果
 type ScanResult struct {
+	Host      string
 	Port      int
-	State     string
+	Protocol  string // tcp 或 udp
+	State     string // open、closed、filtered、unreachable，UDP还有 open|filtered
+	Reason    string // 判定状态的依据，如 syn-ack、conn-refused、no-response
 	Service   string
 	Banner    string
-	IPVersion string // 添加IP版本信息
+	IPVersion string   // 添加IP版本信息
+	Product   string   // 服务探测识别出的产品名
+	Version   string   // 服务探测识别出的版本号
+	ExtraInfo string   // 服务探测提取的附加信息
+	CPE       []string // 通用平台枚举标识
+	TLS       *TLSInfo // TLS握手结果，非TLS服务为 nil
+}
+
+// PortScanner 端口扫描器接口，TCP和UDP扫描器都实现该接口
+type PortScanner interface {
+	ScanPorts(host string, ports []int) []ScanResult
+	ScanTargets(hosts []string, ports []int) []ScanResult
+	ScanTargetsContext(ctx context.Context, hosts []string, ports []int) []ScanResult
+	StreamTargets(ctx context.Context, hosts []string, ports []int) <-chan ScanResult
+	ApplyTiming(profile TimingProfile)
 }
 
 // TCPScanner TCP扫描器
 type TCPScanner struct {
 	Timeout    time.Duration
 	MaxWorkers int
+	Retries    int // 连接超时后的重试次数
+	Throttle       // 速率、单主机并发和延迟限制
+
+	ServiceDB      *ServiceDB    // 服务探测库，为 nil 时只根据banner和端口猜测服务
+	ProbeIntensity int           // 探测强度 0-9，稀有度不超过该值的探测才会发送
+	ProbeBudget    time.Duration // 单个端口服务探测的累计时长上限（不含节流等待），0 时为 Timeout 的 4 倍
+	DetectTLS      bool          // 是否对不主动发送banner的开放端口尝试TLS握手
 }
 
 // NewTCPScanner 创建新的TCP扫描器
 func NewTCPScanner(timeout time.Duration, maxWorkers int) *TCPScanner {
 	return &TCPScanner{
-		Timeout:    timeout,
-		MaxWorkers: maxWorkers,
+		Timeout:        timeout,
+		MaxWorkers:     maxWorkers,
+		ServiceDB:      DefaultServiceDB(),
+		ProbeIntensity: 7,
+		DetectTLS:      true,
 	}
 }
 
+// ApplyTiming 应用时序模板
+func (s *TCPScanner) ApplyTiming(profile TimingProfile) {
+	s.Timeout = profile.Timeout
+	s.MaxWorkers = profile.Workers
+	s.Retries = profile.Retries
+	s.Throttle = profile.Throttle
+}
+
 // ScanPort 扫描单个端口
 func (s *TCPScanner) ScanPort(host string, port int) ScanResult {
+	result, _ := s.ScanPortContext(context.Background(), host, port)
+	return result
+}
+
+// ScanPortContext 扫描单个端口，ctx 被取消时中断连接并返回 ctx.Err()
+// 首次连接不受节流限制，服务探测和TLS握手的后续连接按 Throttle 等待
+func (s *TCPScanner) ScanPortContext(ctx context.Context, host string, port int) (ScanResult, error) {
+	return s.scanPort(ctx, newLimiter(s.Throttle), host, port)
+}
+
+// scanPort 扫描单个端口，同一端口的后续连接在发起前都经过 lim.pace
+func (s *TCPScanner) scanPort(ctx context.Context, lim *limiter, host string, port int) (ScanResult, error) {
 	// 判断是否是IPv6地址
 	ipVersion := "IPv4"
 	if isIPv6(host) {
@@ -42,30 +91,93 @@ func (s *TCPScanner) ScanPort(host string, port int) ScanResult {
 	// 使用net.JoinHostPort自动处理IPv6地址
 	address := net.JoinHostPort(host, strconv.Itoa(port))
 
-	conn, err := net.DialTimeout("tcp", address, s.Timeout)
+	dialer := net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	for attempt := 0; attempt < s.Retries && isTimeout(err) && ctx.Err() == nil; attempt++ {
+		// 超时可能是丢包，按时序模板的延迟、抖动和速率等待后重试几次再下结论
+		if lim.pace(ctx) != nil {
+			break
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
 
 	result := ScanResult{
+		Host:      host,
 		Port:      port,
+		Protocol:  "tcp",
 		State:     "closed",
 		Service:   "unknown",
 		IPVersion: ipVersion,
 	}
 
+	if err != nil && ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	if err != nil {
+		result.State, result.Reason = classifyDialError(err)
+	}
+
 	if err == nil {
 		defer conn.Close()
 		result.State = "open"
+		result.Reason = "syn-ack"
+
+		// 取消时关闭连接，打断正在进行的banner读取
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		defer stop()
 
 		// 尝试获取banner
-		banner := s.getBanner(conn)
+		raw := s.getBanner(conn)
+		banner := strings.TrimSpace(string(raw))
 		if banner != "" {
 			result.Banner = banner
 		}
 
-		// 根据端口和banner识别服务
-		result.Service = s.identifyService(port, banner)
+		// 先用探测库主动识别，识别不出再根据端口和banner猜测
+		if !s.detectService(ctx, lim, &result, raw) {
+			result.Service = s.identifyService(port, banner)
+		}
+
+		// 主动发送banner的服务（SSH、FTP等）不会是直接TLS
+		if s.DetectTLS && len(raw) == 0 && lim.pace(ctx) == nil {
+			if info := s.probeTLS(ctx, host, port); info != nil {
+				applyTLS(&result, info)
+			}
+		}
 	}
 
-	return result
+	return result, nil
+}
+
+// classifyDialError 根据连接错误判断端口状态
+// 连接被拒绝说明主机在线但端口关闭；超时或ICMP禁止通常是防火墙丢弃了报文；
+// 主机/网络不可达说明探测根本没有到达目标
+func classifyDialError(err error) (state, reason string) {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "closed", "conn-refused"
+	case isTimeout(err):
+		return "filtered", "no-response"
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return "filtered", "admin-prohibited"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.EHOSTDOWN):
+		return "unreachable", "host-unreach"
+	case errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable", "net-unreach"
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "unreachable", "dns-error"
+	}
+	return "filtered", err.Error()
+}
+
+// isTimeout 判断是否为超时错误
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 // isIPv6 判断是否是IPv6地址
@@ -81,7 +193,7 @@ func isIPv6(host string) bool {
 }
 
 // getBanner 尝试获取服务banner
-func (s *TCPScanner) getBanner(conn net.Conn) string {
+func (s *TCPScanner) getBanner(conn net.Conn) []byte {
 	// 设置读取超时
 	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
 
@@ -89,10 +201,10 @@ func (s *TCPScanner) getBanner(conn net.Conn) string {
 	buffer := make([]byte, 1024)
 	n, err := conn.Read(buffer)
 	if err != nil {
-		return ""
+		return nil
 	}
 
-	return strings.TrimSpace(string(buffer[:n]))
+	return buffer[:n]
 }
 
 // identifyService 识别服务
@@ -147,38 +259,26 @@ func (s *TCPScanner) identifyService(port int, banner string) string {
 	return "unknown"
 }
 
-// ScanPorts 并发扫描多个端口
+// ScanPorts 并发扫描单个主机的多个TCP端口
 func (s *TCPScanner) ScanPorts(host string, ports []int) []ScanResult {
-	var results []ScanResult
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// 创建worker池
-	jobs := make(chan int, s.MaxWorkers)
-
-	// 启动worker
-	for i := 0; i < s.MaxWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for port := range jobs {
-				result := s.ScanPort(host, port)
-
-				mu.Lock()
-				results = append(results, result)
-				mu.Unlock()
-			}
-		}()
-	}
+	return s.ScanTargets([]string{host}, ports)
+}
 
-	// 分发任务
-	for _, port := range ports {
-		jobs <- port
-	}
-	close(jobs)
+// ScanTargets 并发扫描多个主机的多个TCP端口
+func (s *TCPScanner) ScanTargets(hosts []string, ports []int) []ScanResult {
+	return s.ScanTargetsContext(context.Background(), hosts, ports)
+}
 
-	// 等待所有worker完成
-	wg.Wait()
+// ScanTargetsContext 并发扫描多个主机的多个TCP端口，ctx 被取消时返回已完成的部分结果
+func (s *TCPScanner) ScanTargetsContext(ctx context.Context, hosts []string, ports []int) []ScanResult {
+	return collect(s.StreamTargets(ctx, hosts, ports))
+}
 
-	return results
+// StreamTargets 并发扫描多个主机的多个TCP端口，每完成一个端口就发送到返回的通道
+// 扫描结束或 ctx 被取消后通道关闭，调用方必须读取到通道关闭为止
+func (s *TCPScanner) StreamTargets(ctx context.Context, hosts []string, ports []int) <-chan ScanResult {
+	lim := newLimiter(s.Throttle)
+	return streamPool(ctx, hosts, ports, s.MaxWorkers, lim, func(ctx context.Context, host string, port int) (ScanResult, error) {
+		return s.scanPort(ctx, lim, host, port)
+	})
 }
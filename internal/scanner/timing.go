@@ -0,0 +1,77 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Throttle 扫描节流参数，由扫描器内部的限速器执行
+type Throttle struct {
+	Rate       int           // 每秒最多发起的连接数（含重试、服务探测和TLS握手），0 表示不限制
+	MaxPerHost int           // 单台主机的最大并发探测数，0 表示不限制
+	ScanDelay  time.Duration // 每次连接前的固定延迟，同一端口的重试、服务探测和TLS握手也要等待
+	Jitter     time.Duration // 在固定延迟之外附加的随机延迟上限
+}
+
+// TimingProfile 扫描时序模板，统一设置超时、并发、速率和重试
+type TimingProfile struct {
+	Name    string
+	Timeout time.Duration
+	Workers int
+	Retries int // 超时的探测最多重试次数
+	Throttle
+}
+
+// timingProfiles 预置的时序模板，从慢到快排列
+var timingProfiles = map[string]TimingProfile{
+	// paranoid 适合极其脆弱的网络或需要规避检测的场景
+	"paranoid": {
+		Name:     "paranoid",
+		Timeout:  5 * time.Second,
+		Workers:  1,
+		Retries:  2,
+		Throttle: Throttle{Rate: 1, MaxPerHost: 1, ScanDelay: 5 * time.Second, Jitter: 2 * time.Second},
+	},
+	// polite 适合生产网络，限制速率和单主机并发
+	"polite": {
+		Name:     "polite",
+		Timeout:  3 * time.Second,
+		Workers:  10,
+		Retries:  1,
+		Throttle: Throttle{Rate: 50, MaxPerHost: 5, ScanDelay: 100 * time.Millisecond, Jitter: 100 * time.Millisecond},
+	},
+	// normal 默认值，与未引入时序模板前的行为一致
+	"normal": {
+		Name:    "normal",
+		Timeout: 2 * time.Second,
+		Workers: 100,
+	},
+	// aggressive 适合可靠的局域网，缩短超时、提高并发
+	"aggressive": {
+		Name:    "aggressive",
+		Timeout: 1 * time.Second,
+		Workers: 500,
+	},
+}
+
+// GetTimingProfile 按名称获取时序模板
+func GetTimingProfile(name string) (TimingProfile, error) {
+	profile, ok := timingProfiles[name]
+	if !ok {
+		return TimingProfile{}, fmt.Errorf("未知的时序模板: %s（可选: %v）", name, TimingProfileNames())
+	}
+	return profile, nil
+}
+
+// TimingProfileNames 列出所有时序模板名称
+func TimingProfileNames() []string {
+	names := make([]string, 0, len(timingProfiles))
+	for name := range timingProfiles {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return timingProfiles[names[i]].Timeout > timingProfiles[names[j]].Timeout
+	})
+	return names
+}
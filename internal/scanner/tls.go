@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLSInfo TLS握手结果
+type TLSInfo struct {
+	Version       string // TLS1.2、TLS1.3 等
+	CipherSuite   string
+	ALPN          string // 协商的应用层协议，如 h2、http/1.1
+	ServerName    string // 握手时发送的SNI，目标为IP时为空
+	HostnameMatch bool   // 证书是否与扫描目标匹配
+	Certificates  []CertInfo
+}
+
+// CertInfo 证书信息，Certificates[0] 为服务器证书，其后为中间证书
+type CertInfo struct {
+	Subject            string
+	Issuer             string
+	SANs               []string
+	SerialNumber       string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	KeyType            string // RSA、ECDSA、Ed25519
+	KeyBits            int
+	SignatureAlgorithm string
+	SelfSigned         bool
+	SHA256             string // 证书DER的SHA-256指纹
+}
+
+// probeTLS 尝试TLS握手并收集协商参数和证书链，不是TLS服务时返回 nil
+// 只用于信息收集，不校验证书
+func (s *TCPScanner) probeTLS(ctx context.Context, host string, port int) *TLSInfo {
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		NextProtos:         []string{"h2", "http/1.1"},
+	}
+	// IP地址不能作为SNI发送
+	if net.ParseIP(strings.Trim(host, "[]")) == nil {
+		config.ServerName = host
+	}
+
+	dialer := tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: s.Timeout},
+		Config:    config,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  config.ServerName,
+	}
+
+	for _, cert := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, certInfo(cert))
+	}
+	if len(state.PeerCertificates) > 0 {
+		info.HostnameMatch = state.PeerCertificates[0].VerifyHostname(strings.Trim(host, "[]")) == nil
+	}
+
+	return info
+}
+
+// applyTLS 记录TLS信息，并修正明文探测识别不出的服务名
+func applyTLS(result *ScanResult, info *TLSInfo) {
+	result.TLS = info
+
+	switch result.Service {
+	case "http", "http-proxy":
+		result.Service = "https"
+	case "ssl", "unknown":
+		if info.ALPN == "h2" || info.ALPN == "http/1.1" {
+			result.Service = "https"
+		} else {
+			result.Service = "ssl"
+		}
+	}
+}
+
+// certInfo 提取证书的关键字段
+func certInfo(cert *x509.Certificate) CertInfo {
+	info := CertInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.Text(16),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+	}
+
+	info.SANs = append(info.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType, info.KeyBits = "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType, info.KeyBits = "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType, info.KeyBits = "Ed25519", 256
+	default:
+		info.KeyType = cert.PublicKeyAlgorithm.String()
+	}
+
+	// 自签名：颁发者与主体相同且能用自身公钥验证签名。不用 CheckSignatureFrom，
+	// 它要求颁发者是 CA，没有 basicConstraints 或 CA:FALSE 的自签名服务器证书会被漏掉
+	info.SelfSigned = cert.Subject.String() == cert.Issuer.String() &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+
+	sum := sha256.Sum256(cert.Raw)
+	info.SHA256 = hex.EncodeToString(sum[:])
+
+	return info
+}
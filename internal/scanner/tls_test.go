@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testCert 生成证书，parent 为 nil 时用自身密钥签名
+func testCert(t *testing.T, template *x509.Certificate, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template.SerialNumber = big.NewInt(1)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCertInfoSelfSigned(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	ca := testCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, caKey, nil, nil)
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		want    bool
+		keyType string
+	}{
+		{name: "自签名CA", cert: ca, want: true, keyType: "ECDSA"},
+		{
+			name: "CA:FALSE的自签名服务器证书",
+			cert: testCert(t, &x509.Certificate{
+				Subject:               pkix.Name{CommonName: "localhost"},
+				DNSNames:              []string{"localhost"},
+				BasicConstraintsValid: true,
+			}, leafKey, nil, nil),
+			want:    true,
+			keyType: "ECDSA",
+		},
+		{
+			name:    "没有basicConstraints的自签名证书",
+			cert:    testCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "router.local"}}, edKey, nil, nil),
+			want:    true,
+			keyType: "Ed25519",
+		},
+		{
+			name:    "CA签发的证书",
+			cert:    testCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "www.example.com"}}, leafKey, ca, caKey),
+			want:    false,
+			keyType: "ECDSA",
+		},
+		{
+			name: "主体相同但由其他密钥签发",
+			cert: testCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, leafKey,
+				&x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, otherKey),
+			want:    false,
+			keyType: "ECDSA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := certInfo(tt.cert)
+			if info.SelfSigned != tt.want {
+				t.Errorf("SelfSigned = %v，期望 %v（主体 %s，颁发者 %s）", info.SelfSigned, tt.want, info.Subject, info.Issuer)
+			}
+			if info.KeyType != tt.keyType {
+				t.Errorf("KeyType = %s，期望 %s", info.KeyType, tt.keyType)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// UDPScanner UDP扫描器
+type UDPScanner struct {
+	Timeout    time.Duration
+	MaxWorkers int
+	Retries    int // 超时未响应时的重发次数
+	Throttle       // 速率、单主机并发和延迟限制
+}
+
+// NewUDPScanner 创建新的UDP扫描器
+func NewUDPScanner(timeout time.Duration, maxWorkers int) *UDPScanner {
+	return &UDPScanner{
+		Timeout:    timeout,
+		MaxWorkers: maxWorkers,
+		Retries:    1,
+	}
+}
+
+// ApplyTiming 应用时序模板，UDP至少重发一次，因为大多数UDP服务对丢包不做重传
+func (s *UDPScanner) ApplyTiming(profile TimingProfile) {
+	s.Timeout = profile.Timeout
+	s.MaxWorkers = profile.Workers
+	s.Retries = max(profile.Retries, 1)
+	s.Throttle = profile.Throttle
+}
+
+// udpPayloads 常见UDP服务的探测载荷，空载荷的服务大多不会回应
+var udpPayloads = map[int][]byte{
+	// DNS: 查询 version.bind CHAOS TXT
+	53: []byte("\x12\x34\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00" +
+		"\x07version\x04bind\x00\x00\x10\x00\x03"),
+	// TFTP: 读取请求
+	69: []byte("\x00\x01netscanner\x00octet\x00"),
+	// NTP: 客户端模式版本请求（LI=3, VN=4, Mode=3）
+	123: append([]byte{0xe3}, make([]byte, 47)...),
+	// NetBIOS: NBSTAT 查询
+	137: []byte("\x80\xf0\x00\x10\x00\x01\x00\x00\x00\x00\x00\x00" +
+		"\x20CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\x00\x00\x21\x00\x01"),
+	// SNMP: v1 GetRequest sysDescr.0，团体名 public
+	161: []byte("\x30\x29\x02\x01\x00\x04\x06public\xa0\x1c\x02\x04\x71\xb4\xb5\x68" +
+		"\x02\x01\x00\x02\x01\x00\x30\x0e\x30\x0c\x06\x08\x2b\x06\x01\x02\x01\x01\x01\x00\x05\x00"),
+	// Syslog: 一条普通日志，通常无响应
+	514: []byte("<14>netscanner: udp probe"),
+	// SSDP: M-SEARCH 发现请求
+	1900: []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n"),
+	// mDNS: 查询 _services._dns-sd._udp.local PTR
+	5353: []byte("\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00" +
+		"\x09_services\x07_dns-sd\x04_udp\x05local\x00\x00\x0c\x00\x01"),
+	// Memcached: UDP帧头 + stats
+	11211: []byte("\x00\x01\x00\x00\x00\x01\x00\x00stats\r\n"),
+}
+
+// udpServiceMap UDP端口到服务名的映射
+var udpServiceMap = map[int]string{
+	53:    "dns",
+	67:    "dhcp",
+	69:    "tftp",
+	123:   "ntp",
+	137:   "netbios-ns",
+	161:   "snmp",
+	162:   "snmptrap",
+	500:   "isakmp",
+	514:   "syslog",
+	1900:  "ssdp",
+	5353:  "mdns",
+	11211: "memcache",
+}
+
+// ScanPort 扫描单个UDP端口
+// 收到响应为 open，收到ICMP端口不可达为 closed，其余ICMP不可达为 filtered，
+// 无任何响应时无法区分开放与过滤，标记为 open|filtered
+func (s *UDPScanner) ScanPort(host string, port int) ScanResult {
+	result, _ := s.ScanPortContext(context.Background(), host, port)
+	return result
+}
+
+// ScanPortContext 扫描单个UDP端口，ctx 被取消时返回 ctx.Err()
+func (s *UDPScanner) ScanPortContext(ctx context.Context, host string, port int) (ScanResult, error) {
+	ipVersion := "IPv4"
+	if isIPv6(host) {
+		ipVersion = "IPv6"
+	}
+
+	result := ScanResult{
+		Host:      host,
+		Port:      port,
+		Protocol:  "udp",
+		State:     "open|filtered",
+		Service:   "unknown",
+		IPVersion: ipVersion,
+	}
+	if service, ok := udpServiceMap[port]; ok {
+		result.Service = service
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.State, result.Reason = classifyDialError(err)
+		return result, nil
+	}
+	defer conn.Close()
+
+	// 取消时关闭套接字，打断等待中的读取
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	payload := udpPayloads[port]
+	buffer := make([]byte, 2048)
+
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+		if _, err := conn.Write(payload); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.State, result.Reason = classifyUDPError(err)
+			return result, nil
+		}
+
+		conn.SetReadDeadline(time.Now().Add(s.Timeout))
+		n, err := conn.Read(buffer)
+		if err == nil {
+			result.State = "open"
+			result.Reason = "udp-response"
+			result.Banner = printableBanner(buffer[:n])
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		if isTimeout(err) {
+			// 超时：重发一次，UDP丢包很常见
+			continue
+		}
+
+		result.State, result.Reason = classifyUDPError(err)
+		return result, nil
+	}
+
+	result.Reason = "no-response"
+	return result, nil
+}
+
+// classifyUDPError 根据ICMP错误判断端口状态
+func classifyUDPError(err error) (state, reason string) {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		// ICMP端口不可达
+		return "closed", "port-unreach"
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		// ICMP管理性禁止，通常是防火墙
+		return "filtered", "admin-prohibited"
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return "unreachable", "host-unreach"
+	case errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable", "net-unreach"
+	}
+	return "open|filtered", err.Error()
+}
+
+// printableBanner 提取响应中的可打印字符，二进制协议也能看到部分信息
+func printableBanner(data []byte) string {
+	var sb strings.Builder
+	gap := false
+	for _, b := range data {
+		if b > 0x20 && b < 0x7f {
+			if gap && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteByte(b)
+			gap = false
+		} else {
+			gap = true
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// ScanPorts 并发扫描单个主机的多个UDP端口
+func (s *UDPScanner) ScanPorts(host string, ports []int) []ScanResult {
+	return s.ScanTargets([]string{host}, ports)
+}
+
+// ScanTargets 并发扫描多个主机的多个UDP端口
+func (s *UDPScanner) ScanTargets(hosts []string, ports []int) []ScanResult {
+	return s.ScanTargetsContext(context.Background(), hosts, ports)
+}
+
+// ScanTargetsContext 并发扫描多个主机的多个UDP端口，ctx 被取消时返回已完成的部分结果
+func (s *UDPScanner) ScanTargetsContext(ctx context.Context, hosts []string, ports []int) []ScanResult {
+	return collect(s.StreamTargets(ctx, hosts, ports))
+}
+
+// StreamTargets 并发扫描多个主机的多个UDP端口，每完成一个端口就发送到返回的通道
+// 扫描结束或 ctx 被取消后通道关闭，调用方必须读取到通道关闭为止
+func (s *UDPScanner) StreamTargets(ctx context.Context, hosts []string, ports []int) <-chan ScanResult {
+	return streamPool(ctx, hosts, ports, s.MaxWorkers, newLimiter(s.Throttle), s.ScanPortContext)
+}
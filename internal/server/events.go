@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"netscanner/internal/reporter"
+)
+
+// eventInterval 检查任务变化的间隔
+const eventInterval = 300 * time.Millisecond
+
+// portEvent port 事件的数据
+type portEvent struct {
+	Host string            `json:"host"`
+	Port reporter.JSONPort `json:"port"`
+}
+
+// handleEvents 以 Server-Sent Events 推送任务进度
+// 事件类型：progress（任务状态有变化时的快照）、port（新发现的开放端口）、done（任务结束，数据为最终状态）
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "不支持流式响应")
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, _, ok := s.snapshot(id); !ok {
+		writeError(w, http.StatusNotFound, "任务不存在")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(eventInterval)
+	defer ticker.Stop()
+
+	sent := 0
+	var last []byte
+	for {
+		job, found, ok := s.progress(id, sent)
+		if !ok {
+			return
+		}
+		for _, res := range found {
+			writeEvent(w, "port", portEvent{Host: res.Host, Port: reporter.ToJSONPort(res)})
+		}
+		sent += len(found)
+
+		data, _ := json.Marshal(job)
+		if !bytes.Equal(data, last) {
+			writeEventData(w, "progress", data)
+			last = data
+		}
+		if job.Finished != nil {
+			writeEventData(w, "done", data)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// progress 返回任务快照和第 from 个之后新发现的开放端口
+func (s *Server) progress(id string, from int) (Job, []reporter.ScanResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	found := append([]reporter.ScanResult(nil), job.found[min(from, len(job.found)):]...)
+	return *job, found, true
+}
+
+// writeEvent 写出一个JSON事件
+func writeEvent(w http.ResponseWriter, event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	writeEventData(w, event, data)
+}
+
+// writeEventData 写出一个事件，data 为单行JSON
+func writeEventData(w http.ResponseWriter, event string, data []byte) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"netscanner/internal/plugin"
+	"netscanner/internal/reporter"
+	"netscanner/internal/scanner"
+	"netscanner/internal/target"
+)
+
+// 任务状态
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// JobRequest 提交扫描任务的参数
+type JobRequest struct {
+	Targets       []string `json:"targets"`                  // 写法同 --host：主机名、IP、CIDR、范围
+	Exclude       []string `json:"exclude,omitempty"`        // 排除的目标
+	Ports         string   `json:"ports"`                    // 如 "80,443" 或 "1-1000"，默认 1-1000
+	Mode          string   `json:"mode,omitempty"`           // normal 或 security，默认 normal
+	ScanType      string   `json:"scan_type,omitempty"`      // tcp 或 udp，默认 tcp
+	Plugins       []string `json:"plugins,omitempty"`        // security 模式下只运行这些插件，为空时按服务自动选择
+	Categories    []string `json:"categories,omitempty"`     // 按服务自动选择时只运行这些类别的插件，默认只有 safe；指定了 plugins 时不限制
+	Timing        string   `json:"timing,omitempty"`         // 时序模板，默认 normal
+	SkipDiscovery bool     `json:"skip_discovery,omitempty"` // 跳过主机发现
+}
+
+// Progress 任务进度
+type Progress struct {
+	Phase     string `json:"phase"` // discovery、scanning、plugins、done
+	Done      int    `json:"done"`
+	Total     int    `json:"total"`
+	OpenPorts int    `json:"open_ports"`
+	Findings  int    `json:"findings"`
+}
+
+// Job 扫描任务
+type Job struct {
+	ID        string     `json:"id"`
+	Request   JobRequest `json:"request"`
+	Status    string     `json:"status"`
+	Progress  Progress   `json:"progress"`
+	Error     string     `json:"error,omitempty"`
+	Created   time.Time  `json:"created"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	hosts     []string
+	ports     []int
+	found     []reporter.ScanResult // 扫描过程中发现的开放端口，用于实时推送
+	report    *reporter.ScanReport
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// validate 检查并补全请求参数，返回展开后的主机和端口
+func (req *JobRequest) validate(pm *plugin.PluginManager) ([]string, []int, error) {
+	if len(req.Targets) == 0 {
+		return nil, nil, fmt.Errorf("targets 不能为空")
+	}
+	if req.Ports == "" {
+		req.Ports = "1-1000"
+	}
+	if req.Mode == "" {
+		req.Mode = "normal"
+	}
+	if req.ScanType == "" {
+		req.ScanType = "tcp"
+	}
+	if req.Timing == "" {
+		req.Timing = "normal"
+	}
+
+	if req.Mode != "normal" && req.Mode != "security" {
+		return nil, nil, fmt.Errorf("不支持的扫描模式: %s", req.Mode)
+	}
+	if req.ScanType != "tcp" && req.ScanType != "udp" {
+		return nil, nil, fmt.Errorf("不支持的扫描类型: %s", req.ScanType)
+	}
+	if _, err := scanner.GetTimingProfile(req.Timing); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range req.Plugins {
+		if _, ok := pm.GetPlugin(name); !ok {
+			return nil, nil, fmt.Errorf("插件不存在: %s", name)
+		}
+	}
+	categories, err := plugin.ParseCategories(strings.Join(req.Categories, ","))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Categories = categories
+
+	hosts, err := target.Expand(req.Targets, req.Exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, nil, fmt.Errorf("没有有效的扫描目标")
+	}
+	ports := scanner.ParsePorts(req.Ports)
+	if len(ports) == 0 {
+		return nil, nil, fmt.Errorf("没有有效的端口可扫描")
+	}
+	return hosts, ports, nil
+}
+
+// jobRunner 执行扫描任务
+type jobRunner struct {
+	pm *plugin.PluginManager
+	db *scanner.ServiceDB
+
+	mu  *sync.Mutex // 保护 job 的可变字段，与 Server 共用
+	job *Job
+}
+
+// update 在锁内修改任务
+func (r *jobRunner) update(fn func(job *Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r.job)
+}
+
+// run 执行扫描，被取消时保留已完成的结果
+func (r *jobRunner) run(ctx context.Context) {
+	req := r.job.Request
+	hosts, ports := r.job.hosts, r.job.ports
+
+	timing, _ := scanner.GetTimingProfile(req.Timing)
+	var portScanner scanner.PortScanner
+	if req.ScanType == "udp" {
+		portScanner = scanner.NewUDPScanner(timing.Timeout, timing.Workers)
+	} else {
+		tcpScanner := scanner.NewTCPScanner(timing.Timeout, timing.Workers)
+		tcpScanner.ServiceDB = r.db
+		portScanner = tcpScanner
+	}
+	portScanner.ApplyTiming(timing)
+
+	start := time.Now()
+	report := reporter.ScanReport{
+		Target:    joinTargets(req.Targets),
+		Mode:      req.Mode,
+		ScanType:  req.ScanType,
+		Ports:     req.Ports,
+		StartTime: start,
+	}
+
+	// 主机发现
+	statuses := scanner.AssumeAlive(hosts)
+	if !req.SkipDiscovery {
+		r.update(func(job *Job) { job.Progress = Progress{Phase: "discovery", Total: len(hosts)} })
+		statuses = scanner.NewHostDiscoverer(timing.Timeout, timing.Workers).DiscoverContext(ctx, hosts)
+		for _, st := range statuses {
+			report.Discovery = append(report.Discovery, reporter.HostDiscovery{
+				Host:    st.Host,
+				Alive:   st.Alive,
+				Method:  st.Method,
+				Latency: st.Latency,
+			})
+		}
+	}
+	var live []string
+	for _, st := range statuses {
+		if st.Alive {
+			live = append(live, st.Host)
+		}
+	}
+	report.TotalHosts = len(hosts)
+
+	// 端口扫描，只保留开放的端口
+	r.update(func(job *Job) { job.Progress = Progress{Phase: "scanning", Total: len(live) * len(ports)} })
+	var results []reporter.ScanResult
+	var counts reporter.PortCounts
+	if len(live) > 0 {
+		for result := range portScanner.StreamTargets(ctx, live, ports) {
+			counts.Add(result.State, result.IPVersion)
+			if result.State != "open" && result.State != "open|filtered" {
+				r.update(func(job *Job) { job.Progress.Done++ })
+				continue
+			}
+			converted := reporter.FromScanResult(result)
+			results = append(results, converted)
+			r.update(func(job *Job) {
+				job.Progress.Done++
+				job.Progress.OpenPorts++
+				job.found = append(job.found, converted)
+			})
+		}
+	}
+
+	// 安全扫描模式下对开放的TCP端口运行插件
+	if req.Mode == "security" && ctx.Err() == nil {
+		r.update(func(job *Job) {
+			job.Progress.Phase = "plugins"
+			job.Progress.Done, job.Progress.Total = 0, len(results)
+		})
+		for i := range results {
+			if ctx.Err() != nil {
+				break
+			}
+			res := &results[i]
+			if res.Protocol == "tcp" {
+				res.Findings = r.runPlugins(ctx, res.Host, res.Port, res.Service, timing.Timeout)
+			}
+			found := 0
+			for _, f := range res.Findings {
+				if f.Vulnerable {
+					found++
+				}
+			}
+			r.update(func(job *Job) {
+				job.Progress.Done++
+				job.Progress.Findings += found
+			})
+		}
+	}
+
+	report.EndTime = time.Now()
+	report.Duration = report.EndTime.Sub(start)
+	report.Incomplete = ctx.Err() != nil
+	report.SetPortCounts(counts)
+	report.Hosts = groupByHost(live, results)
+
+	r.update(func(job *Job) {
+		job.report = &report
+		job.Progress.Phase = "done"
+	})
+}
+
+// runPlugins 对一个端口运行插件，请求指定了插件时只运行指定的插件（显式指定即视为允许其类别），
+// 否则只运行请求中允许的类别
+func (r *jobRunner) runPlugins(ctx context.Context, host string, port int, service string, timeout time.Duration) []reporter.Finding {
+	categories := r.job.Request.Categories
+	if len(r.job.Request.Plugins) > 0 {
+		categories = plugin.Categories
+	}
+
+	var findings []reporter.Finding
+	for _, p := range r.pm.ForService(service, port, categories) {
+		if len(r.job.Request.Plugins) > 0 && !slices.Contains(r.job.Request.Plugins, p.Name()) {
+			continue
+		}
+		result, err := plugin.ScanContext(ctx, p, host, port, timeout)
+		if err != nil {
+			continue
+		}
+		findings = append(findings, reporter.FromPluginResult(p.Name(), result)...)
+	}
+	return findings
+}
+
+// groupByHost 按目标顺序把结果分组到主机
+func groupByHost(hosts []string, results []reporter.ScanResult) []reporter.HostResult {
+	order := make(map[string]int, len(hosts))
+	for i, h := range hosts {
+		order[h] = i
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return order[results[i].Host] < order[results[j].Host]
+		}
+		return results[i].Port < results[j].Port
+	})
+
+	var grouped []reporter.HostResult
+	for _, res := range results {
+		if len(grouped) == 0 || grouped[len(grouped)-1].Host != res.Host {
+			grouped = append(grouped, reporter.HostResult{Host: res.Host})
+		}
+		hr := &grouped[len(grouped)-1]
+		if res.State == "open" {
+			hr.OpenPorts++
+		}
+		hr.Results = append(hr.Results, res)
+	}
+	return grouped
+}
+
+// joinTargets 把目标列表合并为报告中的目标描述
+func joinTargets(targets []string) string {
+	if len(targets) == 1 {
+		return targets[0]
+	}
+	return fmt.Sprintf("%s 等 %d 个目标", targets[0], len(targets))
+}
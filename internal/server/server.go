@@ -0,0 +1,405 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"netscanner/internal/plugin"
+	"netscanner/internal/reporter"
+	"netscanner/internal/scanner"
+)
+
+// ErrQueueFull 任务队列已满
+var ErrQueueFull = errors.New("任务队列已满，请稍后重试")
+
+// Config 服务配置
+type Config struct {
+	Token       string // API令牌，为空时不校验（仅建议在本机调试时使用）
+	QueueSize   int    // 排队任务的上限
+	Concurrency int    // 同时执行的任务数
+	MaxJobs     int    // 内存中保留的任务数，超出时删除最早结束的任务
+}
+
+// Server 扫描任务服务，提供HTTP JSON API
+type Server struct {
+	config Config
+	pm     *plugin.PluginManager
+	db     *scanner.ServiceDB
+
+	queue chan *Job
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string // 按提交顺序排列的任务ID
+}
+
+// New 创建服务
+func New(config Config, pm *plugin.PluginManager, db *scanner.ServiceDB) *Server {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 16
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.MaxJobs <= 0 {
+		config.MaxJobs = 200
+	}
+	if db == nil {
+		db = scanner.DefaultServiceDB()
+	}
+
+	return &Server{
+		config: config,
+		pm:     pm,
+		db:     db,
+		queue:  make(chan *Job, config.QueueSize),
+		jobs:   make(map[string]*Job),
+	}
+}
+
+// GenerateToken 生成随机的API令牌
+func GenerateToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Run 启动任务执行器，ctx 取消时停止接收新任务并取消正在执行的任务
+func (s *Server) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.queue:
+					s.execute(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Submit 提交扫描任务，队列满时返回 ErrQueueFull
+func (s *Server) Submit(req JobRequest) (*Job, error) {
+	hosts, ports, err := req.validate(s.pm)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:      GenerateToken()[:12],
+		Request: req,
+		Status:  StatusQueued,
+		Created: time.Now(),
+		hosts:   hosts,
+		ports:   ports,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.queue <- job:
+	default:
+		return nil, ErrQueueFull
+	}
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	s.prune()
+	return job, nil
+}
+
+// Cancel 取消排队中或执行中的任务
+func (s *Server) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return false
+	}
+	switch job.Status {
+	case StatusQueued:
+		job.cancelled = true
+		job.Status = StatusCancelled
+		now := time.Now()
+		job.Finished = &now
+	case StatusRunning:
+		job.cancelled = true
+		job.cancel()
+	}
+	return true
+}
+
+// execute 执行一个任务
+func (s *Server) execute(ctx context.Context, job *Job) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	if job.cancelled {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.Status = StatusRunning
+	job.Started = &now
+	job.cancel = cancel
+	s.mu.Unlock()
+
+	runner := &jobRunner{pm: s.pm, db: s.db, mu: &s.mu, job: job}
+	func() {
+		// 插件崩溃不应影响服务
+		defer func() {
+			if p := recover(); p != nil {
+				runner.update(func(job *Job) { job.Error = fmt.Sprintf("扫描失败: %v", p) })
+			}
+		}()
+		runner.run(ctx)
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finished := time.Now()
+	job.Finished = &finished
+	switch {
+	case job.Error != "":
+		job.Status = StatusFailed
+	case job.cancelled || ctx.Err() != nil:
+		job.Status = StatusCancelled
+	default:
+		job.Status = StatusCompleted
+	}
+}
+
+// prune 删除最早结束的任务，调用时需持有锁
+func (s *Server) prune() {
+	for len(s.order) > s.config.MaxJobs {
+		removed := false
+		for i, id := range s.order {
+			if job := s.jobs[id]; job.Finished != nil {
+				delete(s.jobs, id)
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			return
+		}
+	}
+}
+
+// snapshot 复制任务的公开字段，避免在锁外读取正在更新的任务
+func (s *Server) snapshot(id string) (Job, *reporter.ScanReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	return *job, job.report, true
+}
+
+// Handler 返回HTTP处理器，包括 /api/v1 下的API和 / 下的Web控制台
+//
+//	POST   /api/v1/scans                   提交任务
+//	GET    /api/v1/scans                   任务列表
+//	GET    /api/v1/scans/{id}              任务状态和进度
+//	DELETE /api/v1/scans/{id}              取消任务
+//	GET    /api/v1/scans/{id}/events       以 Server-Sent Events 推送进度
+//	GET    /api/v1/scans/{id}/results      JSON报告（与 --format json 相同）
+//	GET    /api/v1/scans/{id}/report.html  HTML报告
+//	GET    /api/v1/plugins                 可用插件
+func (s *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("POST /api/v1/scans", s.handleSubmit)
+	api.HandleFunc("GET /api/v1/scans", s.handleList)
+	api.HandleFunc("GET /api/v1/scans/{id}", s.handleStatus)
+	api.HandleFunc("DELETE /api/v1/scans/{id}", s.handleCancel)
+	api.HandleFunc("GET /api/v1/scans/{id}/events", s.handleEvents)
+	api.HandleFunc("GET /api/v1/scans/{id}/results", s.handleResults)
+	api.HandleFunc("GET /api/v1/scans/{id}/report.html", s.handleReport)
+	api.HandleFunc("GET /api/v1/plugins", s.handlePlugins)
+
+	// 控制台的静态资源不含扫描数据，无需令牌
+	mux := http.NewServeMux()
+	mux.Handle("/api/", s.authenticate(api))
+	mux.Handle("/", webHandler())
+	return mux
+}
+
+// authenticate 校验 Authorization: Bearer <token>、X-API-Token 头或 token 查询参数
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.Token != "" && !s.validToken(requestToken(r)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="netscanner"`)
+			writeError(w, http.StatusUnauthorized, "缺少或无效的API令牌")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validToken 以固定时间比较令牌
+func (s *Server) validToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) == 1
+}
+
+// requestToken 从请求中取出令牌
+// 浏览器的 EventSource 和下载链接无法设置请求头，GET 请求也可以用 ?token= 传递
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if token := r.Header.Get("X-API-Token"); token != "" {
+		return token
+	}
+	if r.Method == http.MethodGet {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// handleSubmit 提交任务
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+
+	job, err := s.Submit(req)
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	snapshot, _, _ := s.snapshot(job.ID)
+	w.Header().Set("Location", "/api/v1/scans/"+job.ID)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// handleList 任务列表，最新的在前
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := make([]Job, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, *s.jobs[s.order[i]])
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+// handleStatus 任务状态
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	job, _, ok := s.snapshot(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "任务不存在")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleCancel 取消任务
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.Cancel(id) {
+		writeError(w, http.StatusNotFound, "任务不存在")
+		return
+	}
+	job, _, _ := s.snapshot(id)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleResults 返回JSON报告
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.finishedReport(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	reporter.WriteJSONReport(w, *report)
+}
+
+// handleReport 下载HTML报告
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	report, ok := s.finishedReport(w, id)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="netscanner-%s.html"`, id))
+	reporter.WriteHTMLReport(w, *report)
+}
+
+// finishedReport 取出已结束任务的报告，任务未结束或不存在时写出错误响应
+func (s *Server) finishedReport(w http.ResponseWriter, id string) (*reporter.ScanReport, bool) {
+	job, report, ok := s.snapshot(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "任务不存在")
+		return nil, false
+	}
+	if report == nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("任务尚未完成（状态: %s）", job.Status))
+		return nil, false
+	}
+	return report, true
+}
+
+// handlePlugins 可用插件列表
+func (s *Server) handlePlugins(w http.ResponseWriter, r *http.Request) {
+	type pluginInfo struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		plugin.Metadata
+	}
+
+	names := s.pm.ListPlugins()
+	sort.Strings(names)
+	plugins := []pluginInfo{}
+	for _, name := range names {
+		if p, ok := s.pm.GetPlugin(name); ok {
+			plugins = append(plugins, pluginInfo{Name: p.Name(), Description: p.Description(), Metadata: p.Metadata()})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"plugins": plugins})
+}
+
+// writeJSON 写出JSON响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeError 写出错误响应
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"netscanner/internal/reporter"
+)
+
+// webFiles Web控制台的静态文件
+//
+//go:embed web
+var webFiles embed.FS
+
+// webHandler 返回Web控制台的处理器
+// 样式和排序脚本直接使用HTML报告中的版本，控制台与报告外观一致
+func webHandler() http.Handler {
+	static, _ := fs.Sub(webFiles, "web")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /{$}", http.FileServerFS(static))
+	mux.Handle("GET /assets/", http.StripPrefix("/assets/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /assets/report.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write([]byte(reporter.StyleSheet))
+	})
+	mux.HandleFunc("GET /assets/sort.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Write([]byte(reporter.SortScript))
+	})
+	return mux
+}
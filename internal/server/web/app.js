@@ -0,0 +1,329 @@
+// NetScanner 控制台：提交扫描、实时查看进度、浏览和筛选结果
+(function () {
+    "use strict";
+
+    var API = "/api/v1";
+    var TOKEN_KEY = "netscanner-token";
+
+    var SEVERITY_LABELS = {critical: "严重", high: "高危", medium: "中危", low: "低危", info: "信息"};
+    var SEVERITY_RANKS = {critical: 5, high: 4, medium: 3, low: 2, info: 1};
+    var STATUS_LABELS = {queued: "排队中", running: "执行中", completed: "已完成", failed: "失败", cancelled: "已取消"};
+    var PHASE_LABELS = {discovery: "主机发现", scanning: "端口扫描", plugins: "安全检测", done: "结束"};
+
+    var token = localStorage.getItem(TOKEN_KEY) || "";
+    var selected = null;   // 当前查看的任务ID
+    var events = null;     // 当前任务的 EventSource
+    var findings = [];     // 当前任务的检测结果，用于筛选
+
+    function $(id) { return document.getElementById(id); }
+
+    // api 调用API，令牌无效时重新询问
+    function api(method, path, body) {
+        var options = {method: method, headers: {"Authorization": "Bearer " + token}};
+        if (body !== undefined) {
+            options.headers["Content-Type"] = "application/json";
+            options.body = JSON.stringify(body);
+        }
+        return fetch(API + path, options).then(function (resp) {
+            if (resp.status === 401) {
+                askToken();
+                throw new Error("缺少或无效的API令牌");
+            }
+            return resp.json().then(function (data) {
+                if (!resp.ok) { throw new Error(data.error || resp.statusText); }
+                return data;
+            });
+        });
+    }
+
+    // withToken 给不能设置请求头的链接附加令牌
+    function withToken(path) {
+        return API + path + "?token=" + encodeURIComponent(token);
+    }
+
+    function askToken() {
+        var value = prompt("请输入API令牌（启动 serve 时输出，或 NETSCANNER_TOKEN 环境变量）", token);
+        if (value !== null) {
+            token = value.trim();
+            localStorage.setItem(TOKEN_KEY, token);
+        }
+    }
+
+    function cell(row, text, sortKey) {
+        var td = row.insertCell();
+        td.textContent = text === undefined || text === null ? "" : text;
+        if (sortKey !== undefined) { td.dataset.sort = sortKey; }
+        return td;
+    }
+
+    function badge(className, text) {
+        var span = document.createElement("span");
+        span.className = "badge " + className;
+        span.textContent = text;
+        return span;
+    }
+
+    function percent(progress) {
+        return progress.total > 0 ? Math.min(100, Math.round(progress.done * 100 / progress.total)) : 0;
+    }
+
+    function formatTime(value) {
+        return value ? new Date(value).toLocaleString("zh-CN") : "";
+    }
+
+    // loadPlugins 加载插件复选框
+    function loadPlugins() {
+        return api("GET", "/plugins").then(function (data) {
+            var list = $("plugin-list");
+            data.plugins.forEach(function (p) {
+                var label = document.createElement("label");
+                label.className = "inline";
+                label.title = p.description + "（" + p.category + "）";
+                var input = document.createElement("input");
+                input.type = "checkbox";
+                input.name = "plugins";
+                input.value = p.name;
+                label.appendChild(input);
+                label.appendChild(document.createTextNode(" " + p.name));
+                list.appendChild(label);
+            });
+        });
+    }
+
+    function splitList(value) {
+        return value.split(/[\s,]+/).filter(function (s) { return s !== ""; });
+    }
+
+    // submitScan 提交表单中的扫描任务
+    function submitScan(event) {
+        event.preventDefault();
+        var form = event.target;
+        var request = {
+            targets: splitList(form.targets.value),
+            exclude: splitList(form.exclude.value),
+            ports: form.ports.value.trim(),
+            mode: form.mode.value,
+            scan_type: form.scan_type.value,
+            timing: form.timing.value,
+            skip_discovery: form.skip_discovery.checked,
+            plugins: Array.prototype.map.call(form.querySelectorAll("input[name=plugins]:checked"), function (el) { return el.value; }),
+            categories: Array.prototype.map.call(form.querySelectorAll("input[name=categories]:checked"), function (el) { return el.value; })
+        };
+
+        $("form-error").textContent = "";
+        api("POST", "/scans", request).then(function (job) {
+            refreshJobs();
+            showJob(job.id);
+        }).catch(function (err) {
+            $("form-error").textContent = "❌ " + err.message;
+        });
+    }
+
+    // refreshJobs 刷新任务列表
+    function refreshJobs() {
+        return api("GET", "/scans").then(function (data) {
+            $("connection").textContent = "✅ 已连接";
+            var tbody = $("jobs").tBodies[0];
+            tbody.innerHTML = "";
+            data.jobs.forEach(function (job) {
+                var row = tbody.insertRow();
+                row.dataset.id = job.id;
+                if (job.id === selected) { row.className = "selected"; }
+                cell(row, job.id);
+                cell(row, job.request.targets.join(", "));
+                cell(row, job.request.mode + "/" + job.request.scan_type);
+                cell(row, "", job.status).appendChild(badge("status-" + job.status, STATUS_LABELS[job.status] || job.status));
+                var bar = document.createElement("div");
+                bar.className = "progress small";
+                bar.appendChild(document.createElement("div")).style.width = percent(job.progress) + "%";
+                cell(row, "", percent(job.progress)).appendChild(bar);
+                cell(row, job.progress.open_ports);
+                cell(row, job.progress.findings);
+                cell(row, formatTime(job.created), job.created);
+                row.addEventListener("click", function () { showJob(job.id); });
+            });
+            $("jobs-empty").hidden = data.jobs.length > 0;
+        }).catch(function (err) {
+            $("connection").textContent = "❌ " + err.message;
+        });
+    }
+
+    // showJob 查看任务，执行中的任务通过 SSE 实时更新
+    function showJob(id) {
+        if (events) { events.close(); events = null; }
+        selected = id;
+        findings = [];
+        $("detail").hidden = false;
+        $("detail-id").textContent = id;
+        $("ports").tBodies[0].innerHTML = "";
+        $("findings").tBodies[0].innerHTML = "";
+        ["count-hosts", "count-open", "count-findings"].forEach(function (c) { $(c).textContent = "0"; });
+        $("detail-report").hidden = true;
+        $("detail-json").hidden = true;
+        $("detail-incomplete").hidden = true;
+        $("detail-error").hidden = true;
+        Array.prototype.forEach.call($("jobs").tBodies[0].rows, function (row) {
+            row.className = row.dataset.id === id ? "selected" : "";
+        });
+
+        var hosts = {};
+        events = new EventSource(withToken("/scans/" + id + "/events"));
+        events.addEventListener("port", function (e) {
+            var data = JSON.parse(e.data);
+            hosts[data.host] = true;
+            $("count-hosts").textContent = Object.keys(hosts).length;
+            addPort(data.host, data.port, true);
+        });
+        events.addEventListener("progress", function (e) {
+            updateStatus(JSON.parse(e.data));
+        });
+        events.addEventListener("done", function (e) {
+            events.close();
+            events = null;
+            var job = JSON.parse(e.data);
+            updateStatus(job);
+            refreshJobs();
+            loadResults(job);
+        });
+        events.onerror = function () {
+            // 连接断开时由浏览器自动重连；任务已被删除时停止
+            if (events && events.readyState === EventSource.CLOSED) { events = null; }
+        };
+    }
+
+    // updateStatus 更新任务状态栏
+    function updateStatus(job) {
+        var status = $("detail-status");
+        status.className = "badge status-" + job.status;
+        status.textContent = STATUS_LABELS[job.status] || job.status;
+        var phase = PHASE_LABELS[job.progress.phase] || "";
+        if (job.progress.total > 0) {
+            phase += " " + job.progress.done + "/" + job.progress.total;
+        }
+        $("detail-phase").textContent = phase;
+        $("detail-progress").style.width = (job.finished ? 100 : percent(job.progress)) + "%";
+        $("detail-cancel").hidden = job.status !== "queued" && job.status !== "running";
+        $("count-open").textContent = job.progress.open_ports;
+        $("count-findings").textContent = job.progress.findings;
+        if (job.error) {
+            $("detail-error").textContent = "❌ " + job.error;
+            $("detail-error").hidden = false;
+        }
+    }
+
+    // loadResults 任务结束后加载完整结果，替换实时推送的端口
+    function loadResults(job) {
+        if (job.status === "failed" && !job.progress.open_ports) { return; }
+        api("GET", "/scans/" + job.id + "/results").then(function (doc) {
+            if (selected !== job.id) { return; }
+            $("ports").tBodies[0].innerHTML = "";
+            findings = [];
+            doc.hosts.forEach(function (h) {
+                h.ports.forEach(function (p) {
+                    addPort(h.host, p, false);
+                    (p.findings || []).forEach(function (f) {
+                        findings.push({host: h.host, port: p.port, protocol: p.protocol, service: p.service || "", finding: f});
+                    });
+                });
+            });
+            $("count-hosts").textContent = doc.hosts.length;
+            $("count-open").textContent = doc.summary.open;
+            $("count-findings").textContent = doc.summary.findings;
+            $("detail-incomplete").hidden = !doc.scan.incomplete;
+            $("detail-report").href = withToken("/scans/" + job.id + "/report.html");
+            $("detail-report").hidden = false;
+            $("detail-json").href = withToken("/scans/" + job.id + "/results");
+            $("detail-json").hidden = false;
+            updateFilters();
+            renderFindings();
+        }).catch(function () {});
+    }
+
+    // addPort 在端口表中添加一行
+    function addPort(host, p, highlight) {
+        var row = $("ports").tBodies[0].insertRow();
+        if (highlight) { row.className = "new"; }
+        cell(row, host);
+        cell(row, p.port);
+        cell(row, p.protocol);
+        cell(row, p.service);
+        cell(row, [p.product, p.version].filter(Boolean).join(" "));
+        cell(row, p.banner);
+    }
+
+    // updateFilters 用结果中出现的服务和主机填充筛选框
+    function updateFilters() {
+        [["filter-service", "service"], ["filter-host", "host"]].forEach(function (pair) {
+            var select = $(pair[0]);
+            var current = select.value;
+            var values = {};
+            findings.forEach(function (f) { if (f[pair[1]]) { values[f[pair[1]]] = true; } });
+            select.length = 1;
+            Object.keys(values).sort().forEach(function (v) { select.add(new Option(v, v)); });
+            select.value = values[current] ? current : "";
+        });
+    }
+
+    // renderFindings 按筛选条件显示检测结果，风险高的在前
+    function renderFindings() {
+        var severity = $("filter-severity").value;
+        var service = $("filter-service").value;
+        var host = $("filter-host").value;
+        var onlyVulnerable = $("filter-vulnerable").checked;
+
+        var rows = findings.filter(function (f) {
+            return (!onlyVulnerable || f.finding.vulnerable) &&
+                (!severity || f.finding.severity === severity) &&
+                (!service || f.service === service) &&
+                (!host || f.host === host);
+        });
+        rows.sort(function (a, b) {
+            return (SEVERITY_RANKS[b.finding.severity] || 0) - (SEVERITY_RANKS[a.finding.severity] || 0);
+        });
+
+        var tbody = $("findings").tBodies[0];
+        tbody.innerHTML = "";
+        rows.forEach(function (f) {
+            var row = tbody.insertRow();
+            // 带问题标识的 info 结果是收集到的信息，不显示为“通过”
+            var info = !f.finding.vulnerable && f.finding.id && f.finding.severity === "info";
+            var sev = f.finding.vulnerable || info ? f.finding.severity : "";
+            var label = f.finding.vulnerable || info ? (SEVERITY_LABELS[sev] || "未知") : "通过";
+            cell(row, "", SEVERITY_RANKS[sev] || 0).appendChild(badge(sev ? "severity-" + sev : "badge-ok", label));
+            cell(row, f.host);
+            cell(row, f.port + "/" + f.protocol, f.port);
+            cell(row, f.service);
+            cell(row, f.finding.id ? f.finding.plugin + "/" + f.finding.id : f.finding.plugin);
+            var details = cell(row, f.finding.details);
+            [f.finding.evidence, f.finding.remediation].forEach(function (text) {
+                if (!text) { return; }
+                var div = document.createElement("div");
+                div.className = "finding-detail";
+                div.textContent = text;
+                details.appendChild(div);
+            });
+        });
+        $("findings-empty").hidden = rows.length > 0;
+    }
+
+    function cancelJob() {
+        if (selected) {
+            api("DELETE", "/scans/" + selected).then(refreshJobs).catch(function () {});
+        }
+    }
+
+    $("scan-form").addEventListener("submit", submitScan);
+    $("detail-cancel").addEventListener("click", cancelJob);
+    $("change-token").addEventListener("click", function () {
+        askToken();
+        refreshJobs();
+    });
+    ["filter-severity", "filter-service", "filter-host", "filter-vulnerable"].forEach(function (id) {
+        $(id).addEventListener("change", renderFindings);
+    });
+
+    if (!token) { askToken(); }
+    loadPlugins().catch(function () {});
+    refreshJobs();
+    setInterval(refreshJobs, 3000);
+})();
@@ -0,0 +1,201 @@
+package target
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MaxHostsPerExpression 单个表达式最多展开的主机数，防止误写 /8 或IPv6大网段
+const MaxHostsPerExpression = 1 << 16
+
+// Expand 展开目标表达式并剔除排除项，结果按首次出现的顺序去重
+// 支持的写法：单个IP、主机名、CIDR（IPv4/IPv6）、
+// 短范围 10.0.0.1-50、完整范围 10.0.0.1-10.0.0.50，以及逗号分隔的列表
+func Expand(targets, excludes []string) ([]string, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+
+	excluded, err := expandAll(excludes)
+	if err != nil {
+		return nil, fmt.Errorf("解析排除列表失败: %v", err)
+	}
+	skip := make(map[string]bool, len(excluded))
+	for _, h := range excluded {
+		skip[h] = true
+	}
+
+	included, err := expandAll(targets)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range included {
+		if seen[h] || skip[h] {
+			continue
+		}
+		seen[h] = true
+		hosts = append(hosts, h)
+	}
+
+	return hosts, nil
+}
+
+// ReadFile 读取目标文件（类似nmap的 -iL）
+// 每行可包含多个以空白或逗号分隔的表达式，# 之后为注释
+func ReadFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开目标文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var specs []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		specs = append(specs, fields...)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("读取目标文件失败: %v", err)
+	}
+
+	return specs, nil
+}
+
+// expandAll 展开多个表达式，每个表达式本身也可以是逗号列表
+func expandAll(specs []string) ([]string, error) {
+	var hosts []string
+	for _, spec := range specs {
+		for _, part := range strings.Split(spec, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			expanded, err := expandOne(part)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, expanded...)
+		}
+	}
+	return hosts, nil
+}
+
+// expandOne 展开单个表达式
+func expandOne(spec string) ([]string, error) {
+	// 去掉IPv6地址的方括号
+	if strings.HasPrefix(spec, "[") && strings.HasSuffix(spec, "]") {
+		spec = strings.Trim(spec, "[]")
+	}
+
+	if strings.Contains(spec, "/") {
+		return expandCIDR(spec)
+	}
+
+	if addr, err := netip.ParseAddr(spec); err == nil {
+		return []string{addr.String()}, nil
+	}
+
+	if i := strings.LastIndex(spec, "-"); i > 0 {
+		if start, err := netip.ParseAddr(spec[:i]); err == nil {
+			return expandRange(start, spec[i+1:], spec)
+		}
+	}
+
+	// 其余情况视为主机名，交给DNS解析
+	if !isHostname(spec) {
+		return nil, fmt.Errorf("无效的目标: %s", spec)
+	}
+	return []string{strings.ToLower(spec)}, nil
+}
+
+// expandCIDR 展开CIDR网段
+func expandCIDR(spec string) ([]string, error) {
+	prefix, err := netip.ParsePrefix(spec)
+	if err != nil {
+		return nil, fmt.Errorf("无效的CIDR: %s", spec)
+	}
+	prefix = prefix.Masked()
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("网段过大: %s（最多 %d 个地址）", spec, MaxHostsPerExpression)
+	}
+
+	var hosts []string
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr.String())
+	}
+	return hosts, nil
+}
+
+// expandRange 展开地址范围，结束部分可以是完整地址或最后一段的数字
+func expandRange(start netip.Addr, endSpec, spec string) ([]string, error) {
+	end, err := netip.ParseAddr(endSpec)
+	if err != nil {
+		end, err = rangeEnd(start, endSpec)
+		if err != nil {
+			return nil, fmt.Errorf("无效的地址范围: %s", spec)
+		}
+	}
+
+	if start.Is4() != end.Is4() || end.Less(start) {
+		return nil, fmt.Errorf("无效的地址范围: %s", spec)
+	}
+
+	var hosts []string
+	for addr := start; addr.IsValid() && !end.Less(addr); addr = addr.Next() {
+		if len(hosts) >= MaxHostsPerExpression {
+			return nil, fmt.Errorf("地址范围过大: %s（最多 %d 个地址）", spec, MaxHostsPerExpression)
+		}
+		hosts = append(hosts, addr.String())
+	}
+	return hosts, nil
+}
+
+// rangeEnd 根据短写法（如 10.0.0.1-50 或 fe80::1-ff）计算范围结束地址
+func rangeEnd(start netip.Addr, last string) (netip.Addr, error) {
+	if start.Is4() {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 || n > 255 {
+			return netip.Addr{}, fmt.Errorf("无效的范围结束值: %s", last)
+		}
+		b := start.As4()
+		b[3] = byte(n)
+		return netip.AddrFrom4(b), nil
+	}
+
+	n, err := strconv.ParseUint(last, 16, 16)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("无效的范围结束值: %s", last)
+	}
+	b := start.As16()
+	b[14] = byte(n >> 8)
+	b[15] = byte(n)
+	return netip.AddrFrom16(b).WithZone(start.Zone()), nil
+}
+
+// isHostname 粗略校验主机名字符
+func isHostname(s string) bool {
+	if len(s) == 0 || len(s) > 253 {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
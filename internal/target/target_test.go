@@ -0,0 +1,97 @@
+package target
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name     string
+		targets  []string
+		excludes []string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "单个IPv4", targets: []string{"10.0.0.1"}, want: []string{"10.0.0.1"}},
+		{name: "IPv6方括号", targets: []string{"[::1]"}, want: []string{"::1"}},
+		{name: "主机名转小写", targets: []string{"Example.COM"}, want: []string{"example.com"}},
+		{name: "CIDR", targets: []string{"192.168.1.0/30"}, want: []string{"192.168.1.0", "192.168.1.1", "192.168.1.2", "192.168.1.3"}},
+		{name: "CIDR未对齐", targets: []string{"192.168.1.2/31"}, want: []string{"192.168.1.2", "192.168.1.3"}},
+		{name: "IPv6 CIDR", targets: []string{"fe80::/127"}, want: []string{"fe80::", "fe80::1"}},
+		{name: "短范围", targets: []string{"10.0.0.1-3"}, want: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{name: "完整范围跨网段", targets: []string{"10.0.0.254-10.0.1.1"}, want: []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{name: "IPv6短范围", targets: []string{"fe80::1-3"}, want: []string{"fe80::1", "fe80::2", "fe80::3"}},
+		{name: "逗号列表去重", targets: []string{"10.0.0.1, 10.0.0.2", "10.0.0.1"}, want: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "空项忽略", targets: []string{",, ,10.0.0.1,"}, want: []string{"10.0.0.1"}},
+		{name: "排除", targets: []string{"10.0.0.0/30"}, excludes: []string{"10.0.0.0,10.0.0.3"}, want: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "全部排除", targets: []string{"10.0.0.1"}, excludes: []string{"10.0.0.0/24"}, want: nil},
+		{name: "带连字符的主机名", targets: []string{"web-01.local"}, want: []string{"web-01.local"}},
+		{name: "无效字符", targets: []string{"host name"}, wantErr: true},
+		{name: "无效CIDR", targets: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "CIDR缺少前缀", targets: []string{"10.0.0.0/"}, wantErr: true},
+		{name: "网段过大", targets: []string{"10.0.0.0/8"}, wantErr: true},
+		{name: "IPv6网段过大", targets: []string{"fe80::/64"}, wantErr: true},
+		{name: "范围反向", targets: []string{"10.0.0.50-10"}, wantErr: true},
+		{name: "范围结束值越界", targets: []string{"10.0.0.1-256"}, wantErr: true},
+		{name: "范围混用地址族", targets: []string{"10.0.0.1-::1"}, wantErr: true},
+		{name: "范围结束值为空", targets: []string{"10.0.0.1-"}, wantErr: true},
+		{name: "IPv6范围结束值无效", targets: []string{"fe80::1-xyz"}, wantErr: true},
+		{name: "排除列表无效", targets: []string{"10.0.0.1"}, excludes: []string{"10.0.0.0/99"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.targets, tt.excludes)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expand(%q, %q) = %v，期望返回错误", tt.targets, tt.excludes, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand(%q, %q) 返回错误: %v", tt.targets, tt.excludes, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expand(%q, %q) = %v，期望 %v", tt.targets, tt.excludes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandRangeLimit(t *testing.T) {
+	hosts, err := Expand([]string{"10.0.0.0-10.0.255.255"}, nil)
+	if err != nil {
+		t.Fatalf("恰好 %d 个地址的范围返回错误: %v", MaxHostsPerExpression, err)
+	}
+	if len(hosts) != MaxHostsPerExpression {
+		t.Errorf("展开了 %d 个地址，期望 %d", len(hosts), MaxHostsPerExpression)
+	}
+
+	if _, err := Expand([]string{"10.0.0.0-10.1.0.0"}, nil); err == nil {
+		t.Errorf("超过 %d 个地址的范围没有返回错误", MaxHostsPerExpression)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	content := "# 办公网\n10.0.0.1, 10.0.0.2\tweb.local # 注释\n\n   \n[::1]\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile 返回错误: %v", err)
+	}
+	want := []string{"10.0.0.1", "10.0.0.2", "web.local", "[::1]"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ReadFile = %q，期望 %q", got, want)
+	}
+
+	if _, err := ReadFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("读取不存在的文件没有返回错误")
+	}
+}